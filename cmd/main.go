@@ -1,15 +1,21 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/flotio-dev/project-service/configs"
 	"github.com/flotio-dev/project-service/pkg/api"
 	"github.com/flotio-dev/project-service/pkg/auth"
 	"github.com/flotio-dev/project-service/pkg/db"
+	"github.com/flotio-dev/project-service/pkg/events"
+	"github.com/flotio-dev/project-service/pkg/ratelimit"
+	"github.com/flotio-dev/project-service/pkg/storage"
 )
 
 func main() {
@@ -27,18 +33,116 @@ func main() {
 	}
 	log.Println("automigrate completed")
 
-	// JWKS provider pour Keycloak
-	jwksURL := cfg.JWKSURL()
-	issuer := cfg.IssuerURL()
-	var jwksProv *auth.JWKSProvider
-	if jwksURL != "" {
-		jwksProv = auth.NewJWKSProvider(jwksURL, issuer)
+	// JWKS provider pour Keycloak et les issuers OIDC de confiance
+	if err := cfg.ValidateAuth(); err != nil {
+		log.Fatalf("auth configuration: %v", err)
 	}
+	jwksProv := auth.NewJWKSProvider(cfg.Issuers())
+	var devIssuer *auth.DevIssuer
+	if cfg.AuthDevMode {
+		var err error
+		if devIssuer, err = auth.NewDevIssuer(cfg.DevIssuerURL()); err != nil {
+			log.Fatalf("dev issuer: %v", err)
+		}
+		jwksProv.TrustDevIssuer(devIssuer)
+		log.Printf("WARNING: AUTH_DEV_MODE is enabled, anyone can mint tokens with POST /dev/token (issuer %s). Never use it in production.", devIssuer.URL)
+	}
+	go jwksProv.Run(context.Background())
+	groupMapping, err := cfg.GroupMapping()
+	if err != nil {
+		log.Fatalf("auth configuration: %v", err)
+	}
+	var introspector *auth.Introspector
+	if endpoint := cfg.IntrospectionEndpoint(); endpoint != "" {
+		introspector = auth.NewIntrospector(endpoint, cfg.IntrospectionClientID, cfg.IntrospectionClientSecret, cfg.IntrospectionCacheTTL)
+		log.Printf("token introspection enabled for sensitive operations: %s", endpoint)
+	}
+	log.Printf("trusted token issuers: %s", strings.Join(jwksProv.Issuers(), ", "))
 
-	apiSrv := &api.API{DB: gdb, JWKS: jwksProv}
+	// Stockage des artefacts
+	var store storage.Store
+	switch cfg.ArtifactStorage {
+	case "s3":
+		if cfg.S3Endpoint == "" || cfg.S3Bucket == "" {
+			log.Fatal("S3_ENDPOINT and S3_BUCKET are required when ARTIFACT_STORAGE=s3")
+		}
+		store = storage.NewS3(cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3AccessKeyID, cfg.S3SecretAccessKey, cfg.S3PathStyle)
+		log.Printf("artifact storage: s3 bucket %s at %s", cfg.S3Bucket, cfg.S3Endpoint)
+	case "", "local":
+		local, err := storage.NewLocal(cfg.ArtifactDir)
+		if err != nil {
+			log.Fatalf("artifact storage: %v", err)
+		}
+		store = local
+		log.Printf("artifact storage: local directory %s", cfg.ArtifactDir)
+	default:
+		log.Fatalf("unknown ARTIFACT_STORAGE %q (expected local or s3)", cfg.ArtifactStorage)
+	}
+	signingKey := []byte(cfg.ArtifactSigningKey)
+	if len(signingKey) == 0 {
+		log.Println("warning: ARTIFACT_SIGNING_KEY is empty, using a random key (download URLs won't survive a restart)")
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			log.Fatalf("cannot generate signing key: %v", err)
+		}
+	}
+
+	// Limitation de débit
+	var limiter ratelimit.Limiter
+	if cfg.RateLimitBackend != "off" {
+		if limiter, err = ratelimit.New(cfg.RateLimitBackend, gdb); err != nil {
+			log.Fatalf("rate limit: %v", err)
+		}
+		log.Printf("rate limiting: %s backend, %d requests/min, burst %d", cfg.RateLimitBackend, cfg.RateLimitPerMinute, cfg.RateLimitBurst)
+	} else {
+		log.Println("warning: RATE_LIMIT_BACKEND=off, the API is not rate limited")
+	}
+
+	apiSrv := &api.API{
+		DB:                  gdb,
+		JWKS:                jwksProv,
+		DevIssuer:           devIssuer,
+		GroupMapping:        groupMapping,
+		Introspector:        introspector,
+		RateLimiter:         limiter,
+		RateLimit:           ratelimit.PerMinute(cfg.RateLimitPerMinute, cfg.RateLimitBurst),
+		RateLimitTrustProxy: cfg.RateLimitTrustProxy,
+		TokenPolicy:         cfg.TokenPolicy(),
+		WorkerClientIDs:     cfg.WorkerClientIDs,
+		GithubWebhookSecret: cfg.GithubWebhookSecret,
+		Storage:             store,
+		Signer:              storage.URLSigner{Key: signingKey},
+		ArtifactURLTTL:      cfg.ArtifactURLTTL,
+		PublicBaseURL:       cfg.PublicBaseURL,
+	}
 	r := apiSrv.Router()
 	log.Println("router constructed")
 
+	if cfg.SchedulerInterval > 0 {
+		go apiSrv.RunScheduler(context.Background(), cfg.SchedulerInterval)
+		log.Printf("scheduler started (every %s)", cfg.SchedulerInterval)
+	}
+	if cfg.WebhookDispatchInterval > 0 {
+		go apiSrv.RunWebhookDispatcher(context.Background(), cfg.WebhookDispatchInterval)
+		log.Printf("webhook dispatcher started (every %s)", cfg.WebhookDispatchInterval)
+	}
+	if cfg.EventBroker != "" {
+		broker, err := events.New(cfg.EventBroker, events.Options{
+			NATSURL:       cfg.NATSURL,
+			SubjectPrefix: cfg.NATSSubjectPrefix,
+			KafkaBrokers:  cfg.KafkaBrokers,
+			KafkaTopic:    cfg.KafkaTopic,
+		})
+		if err != nil {
+			log.Fatalf("event broker: %v", err)
+		}
+		relay := &events.Relay{DB: gdb, Broker: broker}
+		go relay.Run(context.Background(), cfg.OutboxRelayInterval)
+		log.Printf("outbox relay started (%s broker)", cfg.EventBroker)
+	} else {
+		log.Println("warning: EVENT_BROKER is empty, domain events stay in the outbox")
+	}
+
 	srv := &http.Server{
 		Addr:              fmt.Sprintf(":%d", cfg.HTTPPort),
 		Handler:           r,
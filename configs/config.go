@@ -1,9 +1,15 @@
 package configs
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flotio-dev/project-service/pkg/auth"
 )
 
 // Config regroupe la configuration de l'application.
@@ -17,6 +23,72 @@ type Config struct {
 	// Keycloak / OpenID Connect
 	KeycloakBaseURL string // ex: https://auth.example.com
 	KeycloakRealm   string // ex: my-realm
+
+	// Validation des JWT : audiences et clients (azp) acceptés, tolérance d'horloge,
+	// claims requises ("claim" ou "claim=valeur", ex: email_verified=true)
+	TokenAudiences         []string
+	TokenAuthorizedParties []string
+	TokenClockSkew         time.Duration
+	TokenRequiredClaims    string
+
+	// Rôle sur les projets d'un groupe pour ses membres (viewer, developer, admin) et
+	// sous-groupes de rôle ("admins=admin,viewers=viewer" : /org/team/admins est admin de /org/team)
+	GroupDefaultRole string
+	GroupRoleMap     string
+
+	// Introspection RFC 7662 des opérations sensibles (révélation de secrets, suppression,
+	// transfert de projet). Sans URL explicite, l'endpoint Keycloak est utilisé si un client est défini.
+	IntrospectionURL          string
+	IntrospectionClientID     string
+	IntrospectionClientSecret string
+	IntrospectionCacheTTL     time.Duration
+
+	// Issuers OIDC de confiance supplémentaires : "issuer" (découverte) ou "issuer=jwks_url"
+	OIDCIssuers []string
+
+	// Mode développement : émetteur de tokens intégré (/dev/token), jamais en production
+	AuthDevMode bool
+
+	// Clients Keycloak (azp) dont les tokens client credentials identifient un worker de build
+	WorkerClientIDs []string
+
+	// GitHub
+	GithubWebhookSecret string // secret partagé des webhooks GitHub (X-Hub-Signature-256)
+
+	// URL publique du service, préfixe des liens générés (ex: https://api.example.com)
+	PublicBaseURL string
+
+	// Stockage des artefacts
+	ArtifactStorage    string        // local (défaut) ou s3
+	ArtifactDir        string        // dossier du backend local
+	ArtifactSigningKey string        // clé HMAC des URLs de téléchargement
+	ArtifactURLTTL     time.Duration // durée de validité des URLs signées
+	S3Endpoint         string        // ex: http://localhost:9000
+	S3Region           string
+	S3Bucket           string
+	S3AccessKeyID      string
+	S3SecretAccessKey  string
+	S3PathStyle        bool // requis par MinIO et la plupart des implémentations auto-hébergées
+
+	// Builds planifiés : intervalle d'évaluation des crons (0 = désactivé sur cette instance)
+	SchedulerInterval time.Duration
+
+	// Webhooks sortants : intervalle de relève des livraisons (0 = désactivé sur cette instance)
+	WebhookDispatchInterval time.Duration
+
+	// Limitation de débit : backend memory (défaut, par réplica), postgres (partagé) ou off
+	RateLimitBackend    string
+	RateLimitPerMinute  int
+	RateLimitBurst      int
+	RateLimitTrustProxy bool // X-Forwarded-For fourni par un reverse proxy de confiance
+
+	// Évènements de domaine (outbox) : broker nats, kafka ou memory (vide = relais désactivé)
+	EventBroker         string
+	NATSURL             string
+	NATSSubjectPrefix   string
+	KafkaBrokers        string // ex: kafka-1:9092,kafka-2:9092
+	KafkaTopic          string
+	OutboxRelayInterval time.Duration
 }
 
 // JWKSURL retourne l'URL JWKS de Keycloak.
@@ -35,6 +107,117 @@ func (c Config) IssuerURL() string {
 	return fmt.Sprintf("%s/realms/%s", c.KeycloakBaseURL, c.KeycloakRealm)
 }
 
+// Issuers retourne les issuers de confiance : le realm Keycloak (certs explicites)
+// puis ceux de OIDC_ISSUERS.
+func (c Config) Issuers() []auth.Issuer {
+	var out []auth.Issuer
+	if iss := c.IssuerURL(); iss != "" {
+		out = append(out, auth.Issuer{URL: iss, JWKSURL: c.JWKSURL()})
+	}
+	for _, e := range c.OIDCIssuers {
+		iss, jwksURL, _ := strings.Cut(e, "=")
+		out = append(out, auth.Issuer{URL: strings.TrimSpace(iss), JWKSURL: strings.TrimSpace(jwksURL)})
+	}
+	return out
+}
+
+// GroupMapping retourne la conversion des groupes en rôles sur les projets.
+func (c Config) GroupMapping() (auth.GroupMapping, error) {
+	def, err := auth.ParseRole(c.GroupDefaultRole)
+	if err != nil {
+		return auth.GroupMapping{}, fmt.Errorf("GROUP_DEFAULT_ROLE: %w", err)
+	}
+	sub, err := auth.ParseGroupRoles(c.GroupRoleMap)
+	if err != nil {
+		return auth.GroupMapping{}, fmt.Errorf("GROUP_ROLE_MAP: %w", err)
+	}
+	return auth.GroupMapping{SubRoles: sub, DefaultRole: def}, nil
+}
+
+// IntrospectionEndpoint retourne l'endpoint d'introspection, vide si désactivée.
+func (c Config) IntrospectionEndpoint() string {
+	if c.IntrospectionURL != "" {
+		return c.IntrospectionURL
+	}
+	if c.IntrospectionClientID != "" && c.IssuerURL() != "" {
+		return c.IssuerURL() + "/protocol/openid-connect/token/introspect"
+	}
+	return ""
+}
+
+// DevIssuerURL retourne l'issuer de l'émetteur de développement.
+func (c Config) DevIssuerURL() string {
+	base := c.PublicBaseURL
+	if base == "" {
+		base = fmt.Sprintf("http://localhost:%d", c.HTTPPort)
+	}
+	return base + "/dev"
+}
+
+// ValidateAuth vérifie la configuration des issuers. Sans issuer, le service refuse de
+// démarrer sauf en mode développement.
+func (c Config) ValidateAuth() error {
+	if (c.KeycloakBaseURL == "") != (c.KeycloakRealm == "") {
+		return errors.New("KEYCLOAK_BASE_URL and KEYCLOAK_REALM must be set together")
+	}
+	issuers := c.Issuers()
+	for _, iss := range issuers {
+		if err := checkHTTPURL(iss.URL); err != nil {
+			return fmt.Errorf("invalid issuer %q: %w", iss.URL, err)
+		}
+		if iss.JWKSURL != "" {
+			if err := checkHTTPURL(iss.JWKSURL); err != nil {
+				return fmt.Errorf("invalid JWKS URL %q: %w", iss.JWKSURL, err)
+			}
+		}
+	}
+	if c.IntrospectionURL != "" {
+		if err := checkHTTPURL(c.IntrospectionURL); err != nil {
+			return fmt.Errorf("invalid INTROSPECTION_URL: %w", err)
+		}
+	}
+	if len(issuers) == 0 && !c.AuthDevMode {
+		return errors.New("no token issuer configured: set KEYCLOAK_BASE_URL and KEYCLOAK_REALM or OIDC_ISSUERS (AUTH_DEV_MODE=true for local development only)")
+	}
+	// l'émetteur de développement signe n'importe quel sub : jamais à côté d'un vrai fournisseur
+	if c.AuthDevMode && len(issuers) > 0 {
+		return errors.New("AUTH_DEV_MODE cannot be combined with KEYCLOAK_BASE_URL/KEYCLOAK_REALM or OIDC_ISSUERS")
+	}
+	return nil
+}
+
+func checkHTTPURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("expected an absolute http(s) URL")
+	}
+	return nil
+}
+
+// TokenPolicy retourne la politique de validation des JWT utilisateurs.
+func (c Config) TokenPolicy() auth.TokenPolicy {
+	return auth.TokenPolicy{
+		Audiences:         c.TokenAudiences,
+		AuthorizedParties: c.TokenAuthorizedParties,
+		ClockSkew:         c.TokenClockSkew,
+		RequiredClaims:    auth.ParseRequiredClaims(c.TokenRequiredClaims),
+	}
+}
+
+// splitList découpe une liste séparée par des virgules en ignorant les entrées vides.
+func splitList(s string) []string {
+	var out []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
 // FromEnv charge la configuration depuis les variables d'environnement.
 func FromEnv() (Config, error) {
 	port := 8080
@@ -44,10 +227,116 @@ func FromEnv() (Config, error) {
 		}
 	}
 
+	urlTTL := 15 * time.Minute
+	if v := os.Getenv("ARTIFACT_URL_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			urlTTL = d
+		}
+	}
+	artifactDir := os.Getenv("ARTIFACT_DIR")
+	if artifactDir == "" {
+		artifactDir = "data/artifacts"
+	}
+	pathStyle, _ := strconv.ParseBool(os.Getenv("S3_PATH_STYLE"))
+	devMode, _ := strconv.ParseBool(os.Getenv("AUTH_DEV_MODE"))
+	schedulerInterval := 30 * time.Second
+	if v := os.Getenv("SCHEDULER_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			schedulerInterval = d
+		}
+	}
+	relayInterval := time.Second
+	if v := os.Getenv("OUTBOX_RELAY_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			relayInterval = d
+		}
+	}
+	webhookInterval := 5 * time.Second
+	if v := os.Getenv("WEBHOOK_DISPATCH_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			webhookInterval = d
+		}
+	}
+
+	rateBackend := os.Getenv("RATE_LIMIT_BACKEND")
+	if rateBackend == "" {
+		rateBackend = "memory"
+	}
+	ratePerMinute, rateBurst := 600, 100
+	if v, err := strconv.Atoi(os.Getenv("RATE_LIMIT_PER_MINUTE")); err == nil && v > 0 {
+		ratePerMinute = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("RATE_LIMIT_BURST")); err == nil && v > 0 {
+		rateBurst = v
+	}
+	trustProxy, _ := strconv.ParseBool(os.Getenv("RATE_LIMIT_TRUST_PROXY"))
+	groupRole := os.Getenv("GROUP_DEFAULT_ROLE")
+	if groupRole == "" {
+		groupRole = "admin"
+	}
+	introspectionTTL := 30 * time.Second
+	if v := os.Getenv("INTROSPECTION_CACHE_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			introspectionTTL = d
+		}
+	}
+	clockSkew := 30 * time.Second
+	if v := os.Getenv("TOKEN_CLOCK_SKEW"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			clockSkew = d
+		}
+	}
+
 	return Config{
 		HTTPPort:        port,
 		DatabaseURL:     os.Getenv("DATABASE_URL"),
 		KeycloakBaseURL: os.Getenv("KEYCLOAK_BASE_URL"),
 		KeycloakRealm:   os.Getenv("KEYCLOAK_REALM"),
+		OIDCIssuers:     splitList(os.Getenv("OIDC_ISSUERS")),
+		AuthDevMode:     devMode,
+		WorkerClientIDs: splitList(os.Getenv("WORKER_CLIENT_IDS")),
+
+		TokenAudiences:         splitList(os.Getenv("TOKEN_AUDIENCES")),
+		TokenAuthorizedParties: splitList(os.Getenv("TOKEN_AUTHORIZED_PARTIES")),
+		TokenClockSkew:         clockSkew,
+		TokenRequiredClaims:    os.Getenv("TOKEN_REQUIRED_CLAIMS"),
+
+		GroupDefaultRole: groupRole,
+		GroupRoleMap:     os.Getenv("GROUP_ROLE_MAP"),
+
+		IntrospectionURL:          os.Getenv("INTROSPECTION_URL"),
+		IntrospectionClientID:     os.Getenv("INTROSPECTION_CLIENT_ID"),
+		IntrospectionClientSecret: os.Getenv("INTROSPECTION_CLIENT_SECRET"),
+		IntrospectionCacheTTL:     introspectionTTL,
+
+		GithubWebhookSecret: os.Getenv("GITHUB_WEBHOOK_SECRET"),
+
+		PublicBaseURL: strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/"),
+
+		ArtifactStorage:    os.Getenv("ARTIFACT_STORAGE"),
+		ArtifactDir:        artifactDir,
+		ArtifactSigningKey: os.Getenv("ARTIFACT_SIGNING_KEY"),
+		ArtifactURLTTL:     urlTTL,
+		S3Endpoint:         os.Getenv("S3_ENDPOINT"),
+		S3Region:           os.Getenv("S3_REGION"),
+		S3Bucket:           os.Getenv("S3_BUCKET"),
+		S3AccessKeyID:      os.Getenv("S3_ACCESS_KEY_ID"),
+		S3SecretAccessKey:  os.Getenv("S3_SECRET_ACCESS_KEY"),
+		S3PathStyle:        pathStyle,
+
+		SchedulerInterval:       schedulerInterval,
+		WebhookDispatchInterval: webhookInterval,
+
+		RateLimitBackend:    rateBackend,
+		RateLimitPerMinute:  ratePerMinute,
+		RateLimitBurst:      rateBurst,
+		RateLimitTrustProxy: trustProxy,
+
+		EventBroker:         os.Getenv("EVENT_BROKER"),
+		NATSURL:             os.Getenv("NATS_URL"),
+		NATSSubjectPrefix:   os.Getenv("NATS_SUBJECT_PREFIX"),
+		KafkaBrokers:        os.Getenv("KAFKA_BROKERS"),
+		KafkaTopic:          os.Getenv("KAFKA_TOPIC"),
+		OutboxRelayInterval: relayInterval,
 	}, nil
 }
@@ -0,0 +1,29 @@
+package configs
+
+import "testing"
+
+func TestValidateAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"keycloak", Config{KeycloakBaseURL: "http://kc:8080/auth", KeycloakRealm: "flotio"}, false},
+		{"oidc issuers", Config{OIDCIssuers: []string{"https://accounts.example.com", "https://idp.example=https://idp.example/keys"}}, false},
+		{"dev mode alone", Config{AuthDevMode: true}, false},
+		{"no issuer", Config{}, true},
+		{"keycloak half configured", Config{KeycloakBaseURL: "http://kc:8080/auth", AuthDevMode: true}, true},
+		{"dev mode with keycloak", Config{KeycloakBaseURL: "http://kc:8080/auth", KeycloakRealm: "flotio", AuthDevMode: true}, true},
+		{"dev mode with oidc issuers", Config{OIDCIssuers: []string{"https://accounts.example.com"}, AuthDevMode: true}, true},
+		{"invalid issuer", Config{OIDCIssuers: []string{"accounts.example.com"}}, true},
+		{"invalid jwks url", Config{OIDCIssuers: []string{"https://idp.example=/keys"}}, true},
+		{"invalid introspection url", Config{AuthDevMode: true, IntrospectionURL: "introspect"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.ValidateAuth(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateAuth() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
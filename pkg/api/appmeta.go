@@ -0,0 +1,137 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/flotio-dev/project-service/pkg/appmeta"
+	"github.com/flotio-dev/project-service/pkg/auth"
+	"github.com/flotio-dev/project-service/pkg/db"
+	"github.com/flotio-dev/project-service/pkg/httpx"
+	"github.com/flotio-dev/project-service/pkg/middleware"
+	"github.com/gorilla/mux"
+	"golang.org/x/sync/singleflight"
+)
+
+func (a *API) mountAppMetadata(api *mux.Router) {
+	// GET /api/builds/{buildID}/icon
+	api.HandleFunc("/builds/{buildID}/icon", middleware.RequireScope(auth.ScopeBuildsRead, func(w http.ResponseWriter, r *http.Request) {
+		b, ok := a.ownedBuild(w, r, auth.RoleViewer)
+		if !ok {
+			return
+		}
+		if b.IconKey == nil {
+			httpx.NotFound(w, "build has no icon")
+			return
+		}
+		rc, _, err := a.Storage.Open(r.Context(), *b.IconKey)
+		if err != nil {
+			httpx.NotFound(w, "icon not found")
+			return
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(io.LimitReader(rc, 4<<20))
+		if err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "private, max-age=3600")
+		http.ServeContent(w, r, "icon.png", b.UpdatedAt, bytes.NewReader(data))
+	})).Methods(http.MethodGet)
+}
+
+// extractMetadataAsync lance l'extraction des métadonnées d'un paquet applicatif après son upload.
+func (a *API) extractMetadataAsync(art db.BuildArtifact) {
+	if art.Kind != "ipa" && art.Kind != "apk" && art.Kind != "aab" {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		defer cancel()
+		if err := a.extractMetadata(ctx, art); err != nil {
+			log.Printf("appmeta: artifact %s: %v", art.ID, err)
+		}
+	}()
+}
+
+// extractMetadata lit le paquet et enregistre ses métadonnées et son icône sur le build.
+// Un AAB ne remplace pas les métadonnées déjà extraites d'un APK ou d'une IPA du même build.
+func (a *API) extractMetadata(ctx context.Context, art db.BuildArtifact) error {
+	f, size, err := a.artifactTempFile(ctx, art)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	meta, err := appmeta.Parse(art.Kind, f, size)
+	if err != nil {
+		return err
+	}
+
+	var b db.Build
+	if err := a.DB.WithContext(ctx).First(&b, "id = ?", art.BuildID).Error; err != nil {
+		return err
+	}
+	if art.Kind == "aab" && b.AppID != nil {
+		return nil
+	}
+	updates := map[string]any{
+		"app_id":         meta.BundleID,
+		"app_name":       nullable(meta.Name),
+		"version_name":   nullable(meta.VersionName),
+		"version_code":   nullable(meta.VersionCode),
+		"min_os_version": nullable(meta.MinOSVersion),
+	}
+	if meta.Icon != nil {
+		key := "builds/" + b.ID + "/icon.png"
+		if err := a.Storage.Put(ctx, key, bytes.NewReader(meta.Icon), int64(len(meta.Icon)), "image/png"); err != nil {
+			return err
+		}
+		updates["icon_key"] = key
+	}
+	return a.DB.WithContext(ctx).Model(&b).Updates(updates).Error
+}
+
+// buildAppMetadata retourne les métadonnées enregistrées du build. Si l'extraction après l'upload
+// n'est pas encore terminée, elle est faite ici (une fois par artefact via extracting) et enregistrée.
+func (a *API) buildAppMetadata(ctx context.Context, extracting *singleflight.Group, b db.Build, art db.BuildArtifact) (appmeta.Metadata, error) {
+	if b.AppID == nil {
+		_, err, _ := extracting.Do(art.ID, func() (any, error) {
+			return nil, a.extractMetadata(ctx, art)
+		})
+		if err != nil {
+			return appmeta.Metadata{}, err
+		}
+		if err := a.DB.WithContext(ctx).First(&b, "id = ?", b.ID).Error; err != nil {
+			return appmeta.Metadata{}, err
+		}
+	}
+	if b.AppID != nil {
+		m := appmeta.Metadata{BundleID: *b.AppID}
+		if b.AppName != nil {
+			m.Name = *b.AppName
+		}
+		if b.VersionName != nil {
+			m.VersionName = *b.VersionName
+		}
+		if b.VersionCode != nil {
+			m.VersionCode = *b.VersionCode
+		}
+		return m, nil
+	}
+	return appmeta.Metadata{}, errors.New("no bundle id in " + art.Filename)
+}
+
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
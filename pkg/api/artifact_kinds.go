@@ -0,0 +1,68 @@
+package api
+
+import (
+	"mime"
+	"strings"
+)
+
+// artifactKinds associe chaque type d'artefact à son content-type par défaut.
+var artifactKinds = map[string]string{
+	"apk":      "application/vnd.android.package-archive",
+	"aab":      "application/octet-stream",
+	"ipa":      "application/octet-stream",
+	"dmg":      "application/x-apple-diskimage",
+	"msi":      "application/x-msi",
+	"appimage": "application/x-executable",
+	"symbols":  "application/zip",
+	"report":   "text/html",
+}
+
+// inferArtifactKind devine le type d'un artefact à partir de son nom de fichier.
+func inferArtifactKind(filename string) string {
+	name := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(name, ".apk"):
+		return "apk"
+	case strings.HasSuffix(name, ".aab"):
+		return "aab"
+	case strings.HasSuffix(name, ".ipa"):
+		return "ipa"
+	case strings.HasSuffix(name, ".dmg"):
+		return "dmg"
+	case strings.HasSuffix(name, ".msi"):
+		return "msi"
+	case strings.HasSuffix(name, ".appimage"):
+		return "appimage"
+	case strings.Contains(name, ".dsym"), strings.HasSuffix(name, "mapping.txt"), strings.HasSuffix(name, ".sym"),
+		strings.Contains(name, "symbols"):
+		return "symbols"
+	case strings.HasSuffix(name, ".html"), strings.HasSuffix(name, ".xml"), strings.HasSuffix(name, ".json"):
+		return "report"
+	}
+	return ""
+}
+
+// downloadTypes sont les content-types renvoyés tels quels au téléchargement : des paquets et
+// archives qu'un navigateur ne sait pas interpréter comme un document actif.
+var downloadTypes = map[string]bool{
+	"application/vnd.android.package-archive": true,
+	"application/octet-stream":                true,
+	"application/x-apple-diskimage":           true,
+	"application/x-msi":                       true,
+	"application/x-msdownload":                true,
+	"application/x-executable":                true,
+	"application/zip":                         true,
+	"application/gzip":                        true,
+	"application/json":                        true,
+	"text/plain":                              true,
+}
+
+// downloadContentType retourne le content-type servi pour un artefact : celui choisi à l'upload
+// s'il est sûr, application/octet-stream sinon (ex: text/html, image/svg+xml).
+func downloadContentType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !downloadTypes[mt] {
+		return "application/octet-stream"
+	}
+	return mt
+}
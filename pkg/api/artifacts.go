@@ -0,0 +1,413 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/flotio-dev/project-service/pkg/auth"
+	"github.com/flotio-dev/project-service/pkg/db"
+	"github.com/flotio-dev/project-service/pkg/httpx"
+	"github.com/flotio-dev/project-service/pkg/middleware"
+	"github.com/flotio-dev/project-service/pkg/storage"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// maxPartSize borne la taille d'une partie d'upload (S3 accepte jusqu'à 5 Gio).
+const maxPartSize = 512 << 20
+
+func (a *API) mountArtifacts(api *mux.Router) {
+	// POST /api/builds/{buildID}/artifacts : démarre un upload multipart
+	api.HandleFunc("/builds/{buildID}/artifacts", middleware.RequireScope(auth.ScopeBuildsWrite, func(w http.ResponseWriter, r *http.Request) {
+		b, ok := a.ownedBuild(w, r, auth.RoleDeveloper)
+		if !ok {
+			return
+		}
+		var in struct {
+			Kind        string `json:"kind"`
+			Filename    string `json:"filename"`
+			ContentType string `json:"content_type"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			httpx.BadRequest(w, "invalid json")
+			return
+		}
+		in.Filename = path.Base(path.Clean("/" + in.Filename))
+		if in.Filename == "/" || in.Filename == "." {
+			httpx.BadRequest(w, "filename required")
+			return
+		}
+		if in.Kind == "" {
+			in.Kind = inferArtifactKind(in.Filename)
+		}
+		defaultType, ok := artifactKinds[in.Kind]
+		if !ok {
+			httpx.BadRequest(w, "kind must be one of apk, aab, ipa, dmg, msi, appimage, symbols, report")
+			return
+		}
+		if in.ContentType == "" {
+			in.ContentType = mime.TypeByExtension(path.Ext(in.Filename))
+		}
+		if in.ContentType == "" {
+			in.ContentType = defaultType
+		}
+
+		// la ligne est créée d'abord pour obtenir l'id de la clé de stockage ; sans upload_id,
+		// elle n'accepte aucune partie. L'appel au stockage se fait hors transaction.
+		art := db.BuildArtifact{BuildID: b.ID, Kind: in.Kind, Filename: in.Filename, ContentType: in.ContentType, Status: "uploading"}
+		if err := a.DB.Create(&art).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		art.StorageKey = fmt.Sprintf("builds/%s/%s/%s", b.ID, art.ID, art.Filename)
+		uploadID, err := a.Storage.CreateMultipart(r.Context(), art.StorageKey, art.ContentType)
+		if err != nil {
+			if err := a.DB.Delete(&art).Error; err != nil {
+				log.Printf("artifact %s: %v", art.ID, err)
+			}
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		art.UploadID = &uploadID
+		err = a.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&art).Updates(map[string]any{"storage_key": art.StorageKey, "upload_id": uploadID}).Error; err != nil {
+				return err
+			}
+			return emitArtifactEvent(tx, eventArtifactCreated, b.ProjectID, art)
+		})
+		if err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.Created(w, art)
+	})).Methods(http.MethodPost)
+
+	// GET /api/builds/{buildID}/artifacts
+	api.HandleFunc("/builds/{buildID}/artifacts", middleware.RequireScope(auth.ScopeBuildsRead, func(w http.ResponseWriter, r *http.Request) {
+		b, ok := a.ownedBuild(w, r, auth.RoleViewer)
+		if !ok {
+			return
+		}
+		q := a.DB.Where("build_id = ?", b.ID)
+		if kind := r.URL.Query().Get("kind"); kind != "" {
+			q = q.Where("kind = ?", kind)
+		}
+		var arts []db.BuildArtifact
+		if err := q.Order("created_at ASC").Find(&arts).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.OK(w, arts)
+	})).Methods(http.MethodGet)
+
+	// GET /api/builds/{buildID}/artifacts/{artifactID}
+	api.HandleFunc("/builds/{buildID}/artifacts/{artifactID}", middleware.RequireScope(auth.ScopeBuildsRead, func(w http.ResponseWriter, r *http.Request) {
+		b, ok := a.ownedBuild(w, r, auth.RoleViewer)
+		if !ok {
+			return
+		}
+		var art db.BuildArtifact
+		if !a.findArtifact(w, &art, b.ID, mux.Vars(r)["artifactID"]) {
+			return
+		}
+		httpx.OK(w, art)
+	})).Methods(http.MethodGet)
+
+	// GET /api/builds/{buildID}/artifacts/{artifactID}/download : redirige vers une URL signée courte
+	api.HandleFunc("/builds/{buildID}/artifacts/{artifactID}/download", middleware.RequireScope(auth.ScopeBuildsRead, func(w http.ResponseWriter, r *http.Request) {
+		b, ok := a.ownedBuild(w, r, auth.RoleViewer)
+		if !ok {
+			return
+		}
+		var art db.BuildArtifact
+		if !a.findArtifact(w, &art, b.ID, mux.Vars(r)["artifactID"]) {
+			return
+		}
+		if art.Status != "ready" {
+			httpx.Conflict(w, "artifact upload not completed")
+			return
+		}
+		u, _ := a.signedArtifactURL(art.ID, time.Minute)
+		http.Redirect(w, r, u, http.StatusFound)
+	})).Methods(http.MethodGet)
+
+	// PUT /api/builds/{buildID}/artifacts/{artifactID}/parts/{number} : corps brut de la partie
+	api.HandleFunc("/builds/{buildID}/artifacts/{artifactID}/parts/{number:[0-9]+}", middleware.RequireScope(auth.ScopeBuildsWrite, func(w http.ResponseWriter, r *http.Request) {
+		_, art, ok := a.uploadingArtifact(w, r, auth.RoleDeveloper)
+		if !ok {
+			return
+		}
+		number, err := strconv.Atoi(mux.Vars(r)["number"])
+		if err != nil || number < 1 || number > 10000 {
+			httpx.BadRequest(w, "part number must be between 1 and 10000")
+			return
+		}
+		if r.ContentLength < 0 {
+			httpx.BadRequest(w, "Content-Length required")
+			return
+		}
+		if r.ContentLength > maxPartSize {
+			httpx.BadRequest(w, fmt.Sprintf("part too large (max %d bytes)", maxPartSize))
+			return
+		}
+		// les parties volumineuses dépassent les ReadTimeout et WriteTimeout du serveur : sans
+		// délai d'écriture prolongé, la réponse portant l'ETag serait perdue
+		rc := http.NewResponseController(w)
+		_ = rc.SetReadDeadline(time.Now().Add(30 * time.Minute))
+		_ = rc.SetWriteDeadline(time.Now().Add(30 * time.Minute))
+		etag, err := a.Storage.UploadPart(r.Context(), art.StorageKey, *art.UploadID, number, http.MaxBytesReader(w, r.Body, maxPartSize), r.ContentLength)
+		if err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.OK(w, storage.Part{Number: number, ETag: etag})
+	})).Methods(http.MethodPut)
+
+	// POST /api/builds/{buildID}/artifacts/{artifactID}/complete
+	api.HandleFunc("/builds/{buildID}/artifacts/{artifactID}/complete", middleware.RequireScope(auth.ScopeBuildsWrite, func(w http.ResponseWriter, r *http.Request) {
+		b, art, ok := a.uploadingArtifact(w, r, auth.RoleDeveloper)
+		if !ok {
+			return
+		}
+		var in struct {
+			Parts []storage.Part `json:"parts"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || len(in.Parts) == 0 {
+			httpx.BadRequest(w, "invalid payload (parts required)")
+			return
+		}
+		for i, p := range in.Parts {
+			if i > 0 && p.Number <= in.Parts[i-1].Number {
+				httpx.BadRequest(w, "parts must be sorted by ascending number")
+				return
+			}
+		}
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(30 * time.Minute))
+		if err := a.Storage.CompleteMultipart(r.Context(), art.StorageKey, *art.UploadID, in.Parts); err != nil {
+			httpx.BadRequest(w, err.Error())
+			return
+		}
+		size, sum, err := a.checksum(r.Context(), art.StorageKey)
+		if err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		err = a.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&art).Updates(map[string]any{"status": "ready", "size": size, "sha256": sum, "upload_id": nil}).Error; err != nil {
+				return err
+			}
+			return emitArtifactEvent(tx, eventArtifactReady, b.ProjectID, art)
+		})
+		if err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		a.extractMetadataAsync(art)
+		httpx.OK(w, art)
+	})).Methods(http.MethodPost)
+
+	// DELETE /api/builds/{buildID}/artifacts/{artifactID}
+	api.HandleFunc("/builds/{buildID}/artifacts/{artifactID}", middleware.RequireScope(auth.ScopeBuildsWrite, func(w http.ResponseWriter, r *http.Request) {
+		b, ok := a.ownedBuild(w, r, auth.RoleDeveloper)
+		if !ok {
+			return
+		}
+		var art db.BuildArtifact
+		if !a.findArtifact(w, &art, b.ID, mux.Vars(r)["artifactID"]) {
+			return
+		}
+		if err := a.deleteArtifactObjects(r.Context(), []db.BuildArtifact{art}); err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		err := a.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Delete(&art).Error; err != nil {
+				return err
+			}
+			return emitArtifactEvent(tx, eventArtifactDeleted, b.ProjectID, art)
+		})
+		if err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.NoContent(w)
+	})).Methods(http.MethodDelete)
+
+	// GET /api/builds/{buildID}/artifacts/{artifactID}/url : URL de téléchargement signée
+	api.HandleFunc("/builds/{buildID}/artifacts/{artifactID}/url", middleware.RequireScope(auth.ScopeBuildsRead, func(w http.ResponseWriter, r *http.Request) {
+		b, ok := a.ownedBuild(w, r, auth.RoleViewer)
+		if !ok {
+			return
+		}
+		var art db.BuildArtifact
+		if !a.findArtifact(w, &art, b.ID, mux.Vars(r)["artifactID"]) {
+			return
+		}
+		if art.Status != "ready" {
+			httpx.Conflict(w, "artifact upload not completed")
+			return
+		}
+		u, exp := a.signedArtifactURL(art.ID, a.ArtifactURLTTL)
+		httpx.OK(w, map[string]any{"url": u, "expires_at": exp})
+	})).Methods(http.MethodGet)
+}
+
+// mountArtifactDownloads monte le téléchargement public, protégé par signature.
+func (a *API) mountArtifactDownloads(r *mux.Router) {
+	// GET /artifacts/{artifactID}?expires=<unix>&signature=<hex>
+	r.HandleFunc("/artifacts/{artifactID}", func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["artifactID"]
+		exp, _ := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+		if !a.Signer.Verify("artifact:"+id, exp, r.URL.Query().Get("signature"), time.Now()) {
+			httpx.Forbidden(w, "invalid or expired signature")
+			return
+		}
+		var art db.BuildArtifact
+		if err := a.DB.First(&art, "id = ? AND status = ?", id, "ready").Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				httpx.NotFound(w, "artifact not found")
+				return
+			}
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		a.serveArtifact(w, r, art)
+	}).Methods(http.MethodGet)
+}
+
+// serveArtifact envoie le contenu d'un artefact avec ses en-têtes de métadonnées.
+func (a *API) serveArtifact(w http.ResponseWriter, r *http.Request, art db.BuildArtifact) {
+	rc, size, err := a.Storage.Open(r.Context(), art.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			httpx.NotFound(w, "artifact content not found")
+			return
+		}
+		httpx.InternalError(w, err.Error())
+		return
+	}
+	defer rc.Close()
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(time.Hour))
+	// URL publique signée : le contenu, fourni par le build, ne doit jamais être rendu par le navigateur
+	w.Header().Set("Content-Type", downloadContentType(art.ContentType))
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": art.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	if art.SHA256 != "" {
+		w.Header().Set("X-Checksum-Sha256", art.SHA256)
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, rc); err != nil {
+		log.Printf("artifacts: download %s interrupted: %v", art.ID, err)
+	}
+}
+
+// signedArtifactURL construit l'URL publique signée d'un artefact.
+func (a *API) signedArtifactURL(artifactID string, ttl time.Duration) (string, time.Time) {
+	exp := time.Now().Add(ttl).Truncate(time.Second)
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(exp.Unix(), 10))
+	q.Set("signature", a.Signer.Sign("artifact:"+artifactID, exp))
+	return a.PublicBaseURL + "/artifacts/" + artifactID + "?" + q.Encode(), exp
+}
+
+// checksum relit un objet pour calculer sa taille et son SHA-256.
+func (a *API) checksum(ctx context.Context, key string) (int64, string, error) {
+	rc, _, err := a.Storage.Open(ctx, key)
+	if err != nil {
+		return 0, "", err
+	}
+	defer rc.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, rc)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// deleteArtifactObjects supprime du stockage le contenu (ou l'upload en cours) des artefacts.
+func (a *API) deleteArtifactObjects(ctx context.Context, arts []db.BuildArtifact) error {
+	for _, art := range arts {
+		if art.StorageKey == "" {
+			continue
+		}
+		if art.UploadID != nil {
+			if err := a.Storage.AbortMultipart(ctx, art.StorageKey, *art.UploadID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+				return err
+			}
+			continue
+		}
+		if err := a.Storage.Delete(ctx, art.StorageKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ownedBuild charge le build {buildID} et vérifie que l'appelant a au moins le rôle min sur
+// son projet ; un worker n'accède qu'aux builds qu'il a réclamés.
+func (a *API) ownedBuild(w http.ResponseWriter, r *http.Request, min auth.Role) (db.Build, bool) {
+	var b db.Build
+	if err := a.DB.First(&b, "id = ?", mux.Vars(r)["buildID"]).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httpx.NotFound(w, "build not found")
+			return b, false
+		}
+		httpx.InternalError(w, err.Error())
+		return b, false
+	}
+	if wid, ok := middleware.WorkerID(r); ok {
+		if b.WorkerID == nil || *b.WorkerID != wid {
+			httpx.Forbidden(w, "build not claimed by this worker")
+			return b, false
+		}
+		return b, true
+	}
+	var p db.Project
+	if err := a.DB.Select("id, user_id, group_id").First(&p, "id = ?", b.ProjectID).Error; err != nil {
+		httpx.InternalError(w, err.Error())
+		return b, false
+	}
+	return b, requireProjectRole(w, a.identity(r), p, min)
+}
+
+// findArtifact charge un artefact du build.
+func (a *API) findArtifact(w http.ResponseWriter, art *db.BuildArtifact, buildID, artifactID string) bool {
+	if err := a.DB.First(art, "id = ? AND build_id = ?", artifactID, buildID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httpx.NotFound(w, "artifact not found")
+			return false
+		}
+		httpx.InternalError(w, err.Error())
+		return false
+	}
+	return true
+}
+
+// uploadingArtifact charge un artefact dont l'upload multipart est en cours, avec son build.
+func (a *API) uploadingArtifact(w http.ResponseWriter, r *http.Request, min auth.Role) (db.Build, db.BuildArtifact, bool) {
+	var art db.BuildArtifact
+	b, ok := a.ownedBuild(w, r, min)
+	if !ok || !a.findArtifact(w, &art, b.ID, mux.Vars(r)["artifactID"]) {
+		return b, art, false
+	}
+	if art.Status != "uploading" || art.UploadID == nil {
+		httpx.Conflict(w, "artifact upload already completed")
+		return b, art, false
+	}
+	return b, art, true
+}
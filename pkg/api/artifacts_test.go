@@ -0,0 +1,61 @@
+package api
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flotio-dev/project-service/pkg/db"
+	"github.com/flotio-dev/project-service/pkg/storage"
+)
+
+func TestDownloadContentType(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"application/vnd.android.package-archive", "application/vnd.android.package-archive"},
+		{"application/zip", "application/zip"},
+		{"text/plain; charset=utf-8", "text/plain"},
+		{"text/html", "application/octet-stream"},
+		{"image/svg+xml", "application/octet-stream"},
+		{"application/xhtml+xml", "application/octet-stream"},
+		{"", "application/octet-stream"},
+		{"not a type", "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		if got := downloadContentType(tt.in); got != tt.want {
+			t.Errorf("downloadContentType(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestServeArtifactHeaders(t *testing.T) {
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const page = "<script>alert(1)</script>"
+	if err := store.Put(context.Background(), "report.html", strings.NewReader(page), int64(len(page)), "text/html"); err != nil {
+		t.Fatal(err)
+	}
+	a := &API{Storage: store}
+	rec := httptest.NewRecorder()
+	a.serveArtifact(rec, httptest.NewRequest("GET", "/artifacts/a1", nil),
+		db.BuildArtifact{ID: "a1", StorageKey: "report.html", Filename: "report.html", ContentType: "text/html"})
+
+	want := map[string]string{
+		"Content-Type":            "application/octet-stream",
+		"Content-Disposition":     `attachment; filename=report.html`,
+		"X-Content-Type-Options":  "nosniff",
+		"Content-Security-Policy": "sandbox",
+	}
+	for k, v := range want {
+		if got := rec.Header().Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+	if rec.Body.String() != page {
+		t.Errorf("body = %q", rec.Body.String())
+	}
+}
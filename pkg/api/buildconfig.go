@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/flotio-dev/project-service/pkg/auth"
+	"github.com/flotio-dev/project-service/pkg/buildconfig"
+	"github.com/flotio-dev/project-service/pkg/db"
+	"github.com/flotio-dev/project-service/pkg/github"
+	"github.com/flotio-dev/project-service/pkg/httpx"
+	"github.com/flotio-dev/project-service/pkg/middleware"
+	"github.com/gorilla/mux"
+)
+
+func (a *API) mountBuildConfig(api *mux.Router) {
+	// POST /api/buildconfig/validate
+	// Corps: le fichier flotio.yaml brut, ou {"content": "..."} en JSON.
+	api.HandleFunc("/buildconfig/validate", middleware.RequireScope(auth.ScopeProjectsRead, func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			httpx.BadRequest(w, "cannot read body")
+			return
+		}
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+			var in struct {
+				Content string `json:"content"`
+			}
+			if err := json.Unmarshal(data, &in); err != nil {
+				httpx.BadRequest(w, "invalid json")
+				return
+			}
+			data = []byte(in.Content)
+		}
+		out := struct {
+			Valid  bool                `json:"valid"`
+			Errors []buildconfig.Issue `json:"errors"`
+			Config *buildconfig.Config `json:"config,omitempty"`
+		}{Errors: []buildconfig.Issue{}}
+		cfg, err := buildconfig.Parse(data)
+		var ve *buildconfig.ValidationError
+		switch {
+		case errors.As(err, &ve):
+			out.Errors = ve.Issues
+		case err != nil:
+			httpx.InternalError(w, err.Error())
+			return
+		default:
+			out.Valid = true
+			out.Config = cfg
+		}
+		httpx.OK(w, out)
+	})).Methods(http.MethodPost)
+}
+
+// resolveBuildConfig lit et valide le flotio.yaml du projet (dans son RootDir) à la révision ref.
+// Retourne nil sans erreur si le projet n'est pas lié à GitHub ou si le fichier est absent;
+// un fichier invalide produit une *buildconfig.ValidationError.
+func (a *API) resolveBuildConfig(ctx context.Context, p db.Project, ref string) (*buildconfig.Config, error) {
+	if p.GithubRepo == nil || p.GithubToken == nil || *p.GithubToken == "" || ref == "" {
+		return nil, nil
+	}
+	file := buildconfig.FileName
+	if p.RootDir != nil && *p.RootDir != "" {
+		file = path.Join(*p.RootDir, buildconfig.FileName)
+	}
+	data, err := a.githubClient(*p.GithubToken).GetFileContents(ctx, *p.GithubRepo, file, ref)
+	if err != nil {
+		var ghErr *github.Error
+		if errors.As(err, &ghErr) && ghErr.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return buildconfig.Parse(data)
+}
+
+// writeBuildConfigError répond à une erreur de resolveBuildConfig.
+func writeBuildConfigError(w http.ResponseWriter, err error) {
+	var ve *buildconfig.ValidationError
+	if errors.As(err, &ve) {
+		httpx.UnprocessableEntity(w, "invalid "+buildconfig.FileName, ve.Issues)
+		return
+	}
+	httpx.InternalError(w, "cannot fetch "+buildconfig.FileName+": "+err.Error())
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/flotio-dev/project-service/pkg/auth"
 	"github.com/flotio-dev/project-service/pkg/db"
 	"github.com/flotio-dev/project-service/pkg/httpx"
 	"github.com/flotio-dev/project-service/pkg/middleware"
@@ -13,18 +14,9 @@ import (
 
 func (a *API) mountBuilds(api *mux.Router) {
 	// POST /api/projects/{projectID}/builds
-	api.HandleFunc("/projects/{projectID}/builds", func(w http.ResponseWriter, r *http.Request) {
-		sub, _ := middleware.GetValue[string](r, "sub")
-		vars := mux.Vars(r)
-		projectID := vars["projectID"]
-
-		var p db.Project
-		if err := a.DB.First(&p, "id = ? AND user_id = ?", projectID, sub).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				httpx.NotFound(w, "project not found")
-				return
-			}
-			httpx.InternalError(w, err.Error())
+	api.HandleFunc("/projects/{projectID}/builds", middleware.RequireScope(auth.ScopeBuildsTrigger, func(w http.ResponseWriter, r *http.Request) {
+		p, ok := a.ownedProject(w, r, auth.RoleDeveloper)
+		if !ok {
 			return
 		}
 
@@ -32,53 +24,74 @@ func (a *API) mountBuilds(api *mux.Router) {
 			BranchID    *string `json:"branch_id"`
 			Platform    string  `json:"platform"`
 			DownloadURL string  `json:"download_url"`
+			CommitSHA   *string `json:"commit_sha"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
 			httpx.BadRequest(w, "invalid json")
 			return
 		}
-		if in.Platform == "" || in.DownloadURL == "" {
-			httpx.BadRequest(w, "platform and download_url required")
+		if in.Platform == "" {
+			httpx.BadRequest(w, "platform required")
 			return
 		}
-		b := db.Build{ProjectID: projectID, BranchID: in.BranchID, Platform: in.Platform, DownloadURL: in.DownloadURL, Status: "success"}
-		if err := a.DB.Create(&b).Error; err != nil {
+		// sans download_url, le build attend ses artefacts
+		status := "success"
+		if in.DownloadURL == "" {
+			status = "pending"
+		}
+		b := db.Build{ProjectID: p.ID, BranchID: in.BranchID, Platform: in.Platform, DownloadURL: in.DownloadURL, Status: status, CommitSHA: in.CommitSHA}
+		if in.CommitSHA != nil {
+			cfg, err := a.resolveBuildConfig(r.Context(), p, *in.CommitSHA)
+			if err != nil {
+				writeBuildConfigError(w, err)
+				return
+			}
+			if cfg != nil {
+				if b.Config, err = db.NewJSON(cfg); err != nil {
+					httpx.InternalError(w, err.Error())
+					return
+				}
+			}
+		}
+		err := a.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&b).Error; err != nil {
+				return err
+			}
+			return emitBuildEvent(tx, eventBuildCreated, b)
+		})
+		if err != nil {
 			httpx.InternalError(w, err.Error())
 			return
 		}
 		httpx.Created(w, b)
-	}).Methods(http.MethodPost)
+	})).Methods(http.MethodPost)
 
 	// GET /api/projects/{projectID}/builds
-	api.HandleFunc("/projects/{projectID}/builds", func(w http.ResponseWriter, r *http.Request) {
-		sub, _ := middleware.GetValue[string](r, "sub")
-		vars := mux.Vars(r)
-		projectID := vars["projectID"]
-
-		var count int64
-		if err := a.DB.Model(&db.Project{}).Where("id = ? AND user_id = ?", projectID, sub).Count(&count).Error; err != nil {
-			httpx.InternalError(w, err.Error())
-			return
-		}
-		if count == 0 {
-			httpx.NotFound(w, "project not found")
+	api.HandleFunc("/projects/{projectID}/builds", middleware.RequireScope(auth.ScopeBuildsRead, func(w http.ResponseWriter, r *http.Request) {
+		p, ok := a.ownedProject(w, r, auth.RoleViewer)
+		if !ok {
 			return
 		}
 		var builds []db.Build
-		if err := a.DB.Where("project_id = ?", projectID).Order("created_at DESC").Find(&builds).Error; err != nil {
+		err := a.DB.Preload("Artifacts", "status = ?", "ready").
+			Where("project_id = ?", p.ID).Order("created_at DESC").Find(&builds).Error
+		if err != nil {
 			httpx.InternalError(w, err.Error())
 			return
 		}
 		httpx.OK(w, builds)
-	}).Methods(http.MethodGet)
-
-	// GET /api/builds/{buildID}/logs
-	api.HandleFunc("/builds/{buildID}/logs", func(w http.ResponseWriter, r *http.Request) {
-		sub, _ := middleware.GetValue[string](r, "sub")
-		buildID := mux.Vars(r)["buildID"]
+	})).Methods(http.MethodGet)
 
+	// GET /api/projects/{projectID}/builds/{number}
+	api.HandleFunc("/projects/{projectID}/builds/{number:[0-9]+}", middleware.RequireScope(auth.ScopeBuildsRead, func(w http.ResponseWriter, r *http.Request) {
+		p, ok := a.ownedProject(w, r, auth.RoleViewer)
+		if !ok {
+			return
+		}
 		var b db.Build
-		if err := a.DB.First(&b, "id = ?", buildID).Error; err != nil {
+		err := a.DB.Preload("Artifacts", "status = ?", "ready").
+			First(&b, "project_id = ? AND number = ?", p.ID, mux.Vars(r)["number"]).Error
+		if err != nil {
 			if err == gorm.ErrRecordNotFound {
 				httpx.NotFound(w, "build not found")
 				return
@@ -86,20 +99,79 @@ func (a *API) mountBuilds(api *mux.Router) {
 			httpx.InternalError(w, err.Error())
 			return
 		}
-		var p db.Project
-		if err := a.DB.Select("id,user_id").First(&p, "id = ?", b.ProjectID).Error; err != nil {
-			httpx.InternalError(w, err.Error())
-			return
-		}
-		if p.UserID != sub {
-			httpx.Forbidden(w, "forbidden")
+		httpx.OK(w, b)
+	})).Methods(http.MethodGet)
+
+	// PATCH /api/builds/{buildID}
+	api.HandleFunc("/builds/{buildID}", middleware.RequireScope(auth.ScopeBuildsWrite, a.patchBuild)).Methods(http.MethodPatch)
+
+	// GET /api/builds/{buildID}/logs
+	api.HandleFunc("/builds/{buildID}/logs", middleware.RequireScope(auth.ScopeBuildsRead, func(w http.ResponseWriter, r *http.Request) {
+		b, ok := a.ownedBuild(w, r, auth.RoleViewer)
+		if !ok {
 			return
 		}
 		var logs []db.BuildLog
-		if err := a.DB.Where("build_id = ?", buildID).Order("seq ASC").Find(&logs).Error; err != nil {
+		if err := a.DB.Where("build_id = ?", b.ID).Order("seq ASC").Find(&logs).Error; err != nil {
 			httpx.InternalError(w, err.Error())
 			return
 		}
 		httpx.OK(w, logs)
-	}).Methods(http.MethodGet)
+	})).Methods(http.MethodGet)
+}
+
+// patchBuild met à jour le statut ou l'URL de téléchargement d'un build (utilisateur ou worker).
+func (a *API) patchBuild(w http.ResponseWriter, r *http.Request) {
+	b, ok := a.ownedBuild(w, r, auth.RoleDeveloper)
+	if !ok {
+		return
+	}
+	var in struct {
+		Status      *string `json:"status"`
+		DownloadURL *string `json:"download_url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		httpx.BadRequest(w, "invalid json")
+		return
+	}
+	updates := map[string]any{}
+	if in.Status != nil {
+		switch *in.Status {
+		case "pending", "running", "success", "failed", "cancelled":
+			updates["status"] = *in.Status
+		default:
+			httpx.BadRequest(w, "invalid status")
+			return
+		}
+	}
+	if in.DownloadURL != nil {
+		updates["download_url"] = *in.DownloadURL
+	}
+	if len(updates) == 0 {
+		httpx.OK(w, b)
+		return
+	}
+	previous := b.Status
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&b).Updates(updates).Error; err != nil {
+			return err
+		}
+		if b.Status != previous && isFinishedStatus(b.Status) {
+			return emitBuildEvent(tx, eventBuildFinished, b)
+		}
+		return nil
+	})
+	if err != nil {
+		httpx.InternalError(w, err.Error())
+		return
+	}
+	if b.Status == "success" && previous != "success" {
+		a.notifyPullRequestAsync(b)
+	}
+	httpx.OK(w, b)
+}
+
+// isFinishedStatus indique si un build est terminé (succès, échec ou annulation).
+func isFinishedStatus(status string) bool {
+	return status == "success" || status == "failed" || status == "cancelled"
 }
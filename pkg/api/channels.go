@@ -0,0 +1,420 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/flotio-dev/project-service/pkg/auth"
+	"github.com/flotio-dev/project-service/pkg/db"
+	"github.com/flotio-dev/project-service/pkg/httpx"
+	"github.com/flotio-dev/project-service/pkg/middleware"
+	"github.com/flotio-dev/project-service/pkg/version"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var channelNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// errNoPreviousRelease est retournée par un rollback sans build précédent.
+var errNoPreviousRelease = errors.New("no previous build to roll back to")
+
+// channelView est un canal avec son build courant par plateforme.
+type channelView struct {
+	db.Channel
+	Current map[string]*db.Build `json:"current"`
+}
+
+// releaseInfo est la vue publique du build courant d'un canal.
+type releaseInfo struct {
+	BuildID     string    `json:"build_id"`
+	Channel     string    `json:"channel"`
+	Platform    string    `json:"platform"`
+	AppID       *string   `json:"app_id,omitempty"`
+	VersionName *string   `json:"version_name,omitempty"`
+	VersionCode *string   `json:"version_code,omitempty"`
+	CommitSHA   *string   `json:"commit_sha,omitempty"`
+	BuiltAt     time.Time `json:"built_at"`
+	PromotedAt  time.Time `json:"promoted_at"`
+	Notes       string    `json:"notes,omitempty"`
+	DownloadURL string    `json:"download_url,omitempty"`
+}
+
+// channelHead est le build courant d'un canal pour une plateforme.
+type channelHead struct {
+	HeadChannelID string
+	HeadPlatform  string
+	db.Build
+}
+
+// channelViews associe à chaque canal ses builds courants.
+func channelViews(chans []db.Channel, heads []channelHead) []channelView {
+	out := make([]channelView, len(chans))
+	index := make(map[string]int, len(chans))
+	for i, c := range chans {
+		out[i] = channelView{Channel: c, Current: map[string]*db.Build{}}
+		index[c.ID] = i
+	}
+	for _, h := range heads {
+		if i, ok := index[h.HeadChannelID]; ok {
+			b := h.Build
+			out[i].Current[h.HeadPlatform] = &b
+		}
+	}
+	return out
+}
+
+func (a *API) mountChannels(api *mux.Router) {
+	// GET /api/projects/{projectID}/channels
+	api.HandleFunc("/projects/{projectID}/channels", middleware.RequireScope(auth.ScopeChannelsRead, func(w http.ResponseWriter, r *http.Request) {
+		p, ok := a.ownedProject(w, r, auth.RoleViewer)
+		if !ok {
+			return
+		}
+		var chans []db.Channel
+		if err := a.DB.Where("project_id = ?", p.ID).Order("name ASC").Find(&chans).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		ids := make([]string, len(chans))
+		for i, c := range chans {
+			ids[i] = c.ID
+		}
+		// build courant de chaque canal et plateforme, en une requête
+		var heads []channelHead
+		if len(ids) > 0 {
+			err := a.DB.Raw(`SELECT DISTINCT ON (r.channel_id, r.platform)
+					r.channel_id AS head_channel_id, r.platform AS head_platform, b.*
+				FROM channel_releases r JOIN builds b ON b.id = r.build_id
+				WHERE r.channel_id IN ? AND r.action = 'promote' AND r.reverted_at IS NULL
+				ORDER BY r.channel_id, r.platform, r.id DESC`, ids).Scan(&heads).Error
+			if err != nil {
+				httpx.InternalError(w, err.Error())
+				return
+			}
+		}
+		httpx.OK(w, channelViews(chans, heads))
+	})).Methods(http.MethodGet)
+
+	// POST /api/projects/{projectID}/channels
+	api.HandleFunc("/projects/{projectID}/channels", middleware.RequireScope(auth.ScopeChannelsWrite, func(w http.ResponseWriter, r *http.Request) {
+		p, ok := a.ownedProject(w, r, auth.RoleDeveloper)
+		if !ok {
+			return
+		}
+		var in struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || !channelNameRe.MatchString(in.Name) {
+			httpx.BadRequest(w, "invalid payload (name must match [a-z0-9._-])")
+			return
+		}
+		c := db.Channel{ProjectID: p.ID, Name: in.Name}
+		res := a.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&c)
+		if res.Error != nil {
+			httpx.InternalError(w, res.Error.Error())
+			return
+		}
+		if res.RowsAffected == 0 {
+			httpx.Conflict(w, "channel already exists")
+			return
+		}
+		httpx.Created(w, c)
+	})).Methods(http.MethodPost)
+
+	// PATCH /api/projects/{projectID}/channels/{channel} {"min_versions": {"ANDROID": "1.4.0"}}
+	api.HandleFunc("/projects/{projectID}/channels/{channel}", middleware.RequireScope(auth.ScopeChannelsWrite, func(w http.ResponseWriter, r *http.Request) {
+		p, ok := a.ownedProject(w, r, auth.RoleDeveloper)
+		if !ok {
+			return
+		}
+		var in struct {
+			MinVersions map[string]string `json:"min_versions"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.MinVersions == nil {
+			httpx.BadRequest(w, "invalid payload (min_versions required)")
+			return
+		}
+		mins := make(map[string]string, len(in.MinVersions))
+		for pl, v := range in.MinVersions {
+			pl = strings.ToUpper(pl)
+			if !isKnownPlatform(pl) {
+				httpx.BadRequest(w, "unknown platform "+pl)
+				return
+			}
+			if v == "" {
+				continue
+			}
+			parsed, err := version.Parse(v)
+			if err != nil {
+				httpx.BadRequest(w, "invalid min_versions."+pl+": "+err.Error())
+				return
+			}
+			mins[pl] = parsed.String()
+		}
+		raw, _ := db.NewJSON(mins)
+		var c db.Channel
+		err := a.DB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if c, err = lockChannel(tx, p.ID, mux.Vars(r)["channel"], false); err != nil {
+				return err
+			}
+			c.MinVersions = raw
+			return tx.Model(&c).Update("min_versions", raw).Error
+		})
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				httpx.NotFound(w, "channel not found")
+				return
+			}
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.OK(w, c)
+	})).Methods(http.MethodPatch)
+
+	// POST /api/projects/{projectID}/channels/{channel}/promote {"build_id": "...", "note": "..."}
+	api.HandleFunc("/projects/{projectID}/channels/{channel}/promote", middleware.RequireScope(auth.ScopeChannelsWrite, func(w http.ResponseWriter, r *http.Request) {
+		sub, _ := middleware.GetValue[string](r, "sub")
+		p, ok := a.ownedProject(w, r, auth.RoleDeveloper)
+		if !ok {
+			return
+		}
+		name := mux.Vars(r)["channel"]
+		if !channelNameRe.MatchString(name) {
+			httpx.BadRequest(w, "invalid channel name")
+			return
+		}
+		var in struct {
+			BuildID string `json:"build_id"`
+			Note    string `json:"note"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.BuildID == "" {
+			httpx.BadRequest(w, "invalid payload (build_id required)")
+			return
+		}
+		var rel db.ChannelRelease
+		err := a.DB.Transaction(func(tx *gorm.DB) error {
+			// verrou partagé : cleanupPreview ne peut pas supprimer le build pendant la promotion
+			var b db.Build
+			if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).First(&b, "id = ? AND project_id = ?", in.BuildID, p.ID).Error; err != nil {
+				return err
+			}
+			var br *db.Branch
+			if b.BranchID != nil {
+				br = &db.Branch{}
+				if err := tx.First(br, "id = ?", *b.BranchID).Error; err != nil {
+					return err
+				}
+			}
+			if err := checkPromotable(b, br); err != nil {
+				return err
+			}
+			c, err := lockChannel(tx, p.ID, name, true)
+			if err != nil {
+				return err
+			}
+			rel = db.ChannelRelease{ChannelID: c.ID, Platform: b.Platform, Action: "promote", BuildID: &b.ID, ActorSub: sub, Note: in.Note}
+			if err := tx.Create(&rel).Error; err != nil {
+				return err
+			}
+			return emitEvent(tx, p.ID, aggregateChannel, c.ID, eventChannelPromoted, rel)
+		})
+		var notPromotable *notPromotableError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			httpx.NotFound(w, "build not found")
+			return
+		case errors.As(err, &notPromotable):
+			httpx.Conflict(w, err.Error())
+			return
+		case err != nil:
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.Created(w, rel)
+	})).Methods(http.MethodPost)
+
+	// POST /api/projects/{projectID}/channels/{channel}/rollback {"platform": "ANDROID"}
+	api.HandleFunc("/projects/{projectID}/channels/{channel}/rollback", middleware.RequireScope(auth.ScopeChannelsWrite, func(w http.ResponseWriter, r *http.Request) {
+		sub, _ := middleware.GetValue[string](r, "sub")
+		p, ok := a.ownedProject(w, r, auth.RoleDeveloper)
+		if !ok {
+			return
+		}
+		var in struct {
+			Platform string `json:"platform"`
+			Note     string `json:"note"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Platform == "" {
+			httpx.BadRequest(w, "invalid payload (platform required)")
+			return
+		}
+		in.Platform = strings.ToUpper(in.Platform)
+		var rel db.ChannelRelease
+		err := a.DB.Transaction(func(tx *gorm.DB) error {
+			c, err := lockChannel(tx, p.ID, mux.Vars(r)["channel"], false)
+			if err != nil {
+				return err
+			}
+			var heads []db.ChannelRelease
+			err = tx.Where("channel_id = ? AND platform = ? AND action = ? AND reverted_at IS NULL", c.ID, in.Platform, "promote").
+				Order("id DESC").Limit(2).Find(&heads).Error
+			if err != nil {
+				return err
+			}
+			if len(heads) < 2 {
+				return errNoPreviousRelease
+			}
+			if err := tx.Model(&heads[0]).Update("reverted_at", time.Now()).Error; err != nil {
+				return err
+			}
+			rel = db.ChannelRelease{ChannelID: c.ID, Platform: in.Platform, Action: "rollback", BuildID: heads[1].BuildID, ActorSub: sub, Note: in.Note}
+			if err := tx.Create(&rel).Error; err != nil {
+				return err
+			}
+			return emitEvent(tx, p.ID, aggregateChannel, c.ID, eventChannelRolledBack, rel)
+		})
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			httpx.NotFound(w, "channel not found")
+		case errors.Is(err, errNoPreviousRelease):
+			httpx.Conflict(w, err.Error())
+		case err != nil:
+			httpx.InternalError(w, err.Error())
+		default:
+			httpx.Created(w, rel)
+		}
+	})).Methods(http.MethodPost)
+
+	// GET /api/projects/{projectID}/channels/{channel}/history?platform=
+	api.HandleFunc("/projects/{projectID}/channels/{channel}/history", middleware.RequireScope(auth.ScopeChannelsRead, func(w http.ResponseWriter, r *http.Request) {
+		p, ok := a.ownedProject(w, r, auth.RoleViewer)
+		if !ok {
+			return
+		}
+		var c db.Channel
+		if err := a.DB.First(&c, "project_id = ? AND name = ?", p.ID, mux.Vars(r)["channel"]).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				httpx.NotFound(w, "channel not found")
+				return
+			}
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		q := a.DB.Where("channel_id = ?", c.ID)
+		if pl := r.URL.Query().Get("platform"); pl != "" {
+			q = q.Where("platform = ?", strings.ToUpper(pl))
+		}
+		var rels []db.ChannelRelease
+		if err := q.Order("id DESC").Limit(200).Find(&rels).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.OK(w, rels)
+	})).Methods(http.MethodGet)
+}
+
+// mountChannelResolver monte le résolveur public du dernier build d'un canal (update checker).
+func (a *API) mountChannelResolver(r *mux.Router) {
+	// GET /channels/{projectID}/{channel}/{platform}/latest
+	r.HandleFunc("/channels/{projectID}/{channel}/{platform}/latest", func(w http.ResponseWriter, r *http.Request) {
+		v := mux.Vars(r)
+		info, err := a.latestRelease(r.Context(), v["projectID"], v["channel"], strings.ToUpper(v["platform"]))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				httpx.NotFound(w, "no build promoted to this channel")
+				return
+			}
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.OK(w, info)
+	}).Methods(http.MethodGet)
+}
+
+// latestRelease résout le build courant d'un canal pour une plateforme.
+func (a *API) latestRelease(ctx context.Context, projectID, channel, platform string) (releaseInfo, error) {
+	tx := a.DB.WithContext(ctx)
+	var c db.Channel
+	if err := tx.First(&c, "project_id = ? AND name = ?", projectID, channel).Error; err != nil {
+		return releaseInfo{}, err
+	}
+	var rel db.ChannelRelease
+	err := tx.Where("channel_id = ? AND platform = ? AND action = ? AND reverted_at IS NULL", c.ID, platform, "promote").
+		Order("id DESC").First(&rel).Error
+	if err != nil {
+		return releaseInfo{}, err
+	}
+	var b db.Build
+	if err := tx.First(&b, "id = ?", *rel.BuildID).Error; err != nil {
+		return releaseInfo{}, err
+	}
+	info := releaseInfo{
+		BuildID:     b.ID,
+		Channel:     c.Name,
+		Platform:    b.Platform,
+		AppID:       b.AppID,
+		VersionName: b.VersionName,
+		VersionCode: b.VersionCode,
+		CommitSHA:   b.CommitSHA,
+		BuiltAt:     b.CreatedAt,
+		PromotedAt:  rel.CreatedAt,
+		Notes:       rel.Note,
+		DownloadURL: b.DownloadURL,
+	}
+	if art, err := a.installableArtifact(ctx, b); err == nil {
+		info.DownloadURL, _ = a.signedArtifactURL(art.ID, a.ArtifactURLTTL)
+	}
+	return info, nil
+}
+
+// notPromotableError explique pourquoi un build ne peut pas être promu.
+type notPromotableError struct{ reason string }
+
+func (e *notPromotableError) Error() string { return e.reason }
+
+// checkPromotable vérifie qu'un build peut être promu : réussi, et pas construit sur la branche
+// éphémère d'une pull request, supprimée avec ses builds à la fermeture de la PR (br est sa branche).
+func checkPromotable(b db.Build, br *db.Branch) error {
+	if b.Status != "success" {
+		return &notPromotableError{"only successful builds can be promoted"}
+	}
+	if br != nil && br.Ephemeral {
+		return &notPromotableError{"pull request preview builds cannot be promoted"}
+	}
+	return nil
+}
+
+// lockChannel verrouille le canal (créé si create) pour sérialiser promotions et rollbacks.
+func lockChannel(tx *gorm.DB, projectID, name string, create bool) (db.Channel, error) {
+	c := db.Channel{ProjectID: projectID, Name: name}
+	if create {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&c).Error; err != nil {
+			return c, err
+		}
+	}
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&c, "project_id = ? AND name = ?", projectID, name).Error
+	return c, err
+}
+
+// ownedProject charge le projet {projectID} et vérifie que l'appelant y a au moins le rôle min
+// (propriétaire, ou membre de son groupe).
+func (a *API) ownedProject(w http.ResponseWriter, r *http.Request, min auth.Role) (db.Project, bool) {
+	id := a.identity(r)
+	var p db.Project
+	if err := visibleProjects(a.DB, id, "").First(&p, "id = ?", mux.Vars(r)["projectID"]).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httpx.NotFound(w, "project not found")
+			return p, false
+		}
+		httpx.InternalError(w, err.Error())
+		return p, false
+	}
+	return p, requireProjectRole(w, id, p, min)
+}
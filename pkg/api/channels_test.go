@@ -0,0 +1,52 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/flotio-dev/project-service/pkg/db"
+)
+
+func TestCheckPromotable(t *testing.T) {
+	tests := []struct {
+		name    string
+		build   db.Build
+		branch  *db.Branch
+		wantErr bool
+	}{
+		{"success without branch", db.Build{Status: "success"}, nil, false},
+		{"success on branch", db.Build{Status: "success"}, &db.Branch{Name: "main"}, false},
+		{"failed", db.Build{Status: "failed"}, nil, true},
+		{"running", db.Build{Status: "running"}, &db.Branch{Name: "main"}, true},
+		{"pull request preview", db.Build{Status: "success"}, &db.Branch{Name: "pr-12", Ephemeral: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPromotable(tt.build, tt.branch)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkPromotable() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChannelViews(t *testing.T) {
+	chans := []db.Channel{{ID: "c1", Name: "beta"}, {ID: "c2", Name: "prod"}}
+	heads := []channelHead{
+		{HeadChannelID: "c1", HeadPlatform: "android", Build: db.Build{ID: "b1"}},
+		{HeadChannelID: "c1", HeadPlatform: "ios", Build: db.Build{ID: "b2"}},
+		{HeadChannelID: "c3", HeadPlatform: "ios", Build: db.Build{ID: "b3"}},
+	}
+	out := channelViews(chans, heads)
+	if len(out) != 2 {
+		t.Fatalf("len = %d, want 2", len(out))
+	}
+	if got := out[0].Current["android"]; got == nil || got.ID != "b1" {
+		t.Errorf("beta android = %v, want b1", got)
+	}
+	if got := out[0].Current["ios"]; got == nil || got.ID != "b2" {
+		t.Errorf("beta ios = %v, want b2", got)
+	}
+	if len(out[1].Current) != 0 {
+		t.Errorf("prod current = %v, want empty", out[1].Current)
+	}
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flotio-dev/project-service/pkg/httpx"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/mux"
+)
+
+// devTokenMaxTTL borne la durée de vie des tokens de développement.
+const devTokenMaxTTL = 24 * time.Hour
+
+// devTokenRequest décrit le token à émettre ; Claims complète ou remplace les claims générées.
+type devTokenRequest struct {
+	Sub    string         `json:"sub"`
+	Email  string         `json:"email"`
+	Groups []string       `json:"groups"`
+	Scopes []string       `json:"scopes"` // défaut : "*"
+	TTL    string         `json:"ttl"`    // ex: 1h, défaut 1h
+	Claims map[string]any `json:"claims"`
+}
+
+// mountDevAuth monte l'émetteur de tokens de développement (/dev), uniquement en AUTH_DEV_MODE.
+func (a *API) mountDevAuth(r *mux.Router) {
+	if a.DevIssuer == nil {
+		return
+	}
+	iss := a.DevIssuer
+	r.HandleFunc("/dev/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		httpx.JSON(w, map[string]any{
+			"issuer":                                iss.URL,
+			"jwks_uri":                              iss.URL + "/jwks.json",
+			"token_endpoint":                        iss.URL + "/token",
+			"introspection_endpoint":                iss.URL + "/introspect",
+			"revocation_endpoint":                   iss.URL + "/revoke",
+			"id_token_signing_alg_values_supported": []string{"ES256"},
+		})
+	}).Methods(http.MethodGet)
+	r.HandleFunc("/dev/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		httpx.JSON(w, iss.JWKS())
+	}).Methods(http.MethodGet)
+
+	// POST /dev/token {"sub":"alice","groups":["/org/team"],"scopes":["projects:read"]}
+	r.HandleFunc("/dev/token", func(w http.ResponseWriter, r *http.Request) {
+		var in devTokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			httpx.BadRequest(w, "invalid json")
+			return
+		}
+		in.Sub = strings.TrimSpace(in.Sub)
+		if in.Sub == "" {
+			httpx.UnprocessableEntity(w, "sub is required", nil)
+			return
+		}
+		ttl := time.Hour
+		if in.TTL != "" {
+			d, err := time.ParseDuration(in.TTL)
+			if err != nil || d <= 0 || d > devTokenMaxTTL {
+				httpx.UnprocessableEntity(w, "ttl must be a duration between 1s and 24h", nil)
+				return
+			}
+			ttl = d
+		}
+		if in.Scopes == nil {
+			in.Scopes = []string{"*"}
+		}
+		if in.Email == "" {
+			in.Email = in.Sub + "@dev.local"
+		}
+		groups := in.Groups
+		if groups == nil {
+			groups = []string{}
+		}
+		claims := jwt.MapClaims{
+			"sub":                in.Sub,
+			"preferred_username": in.Sub,
+			"email":              in.Email,
+			"email_verified":     true,
+			"groups":             groups,
+			"scope":              strings.Join(in.Scopes, " "),
+		}
+		// satisfait la politique configurée (audience, azp)
+		if len(a.TokenPolicy.Audiences) > 0 {
+			claims["aud"] = a.TokenPolicy.Audiences[0]
+		}
+		if len(a.TokenPolicy.AuthorizedParties) > 0 {
+			claims["azp"] = a.TokenPolicy.AuthorizedParties[0]
+		}
+		for k, v := range in.Claims {
+			claims[k] = v
+		}
+		token, err := iss.Mint(claims, ttl)
+		if err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.OK(w, map[string]any{
+			"access_token": token,
+			"token_type":   "Bearer",
+			"expires_in":   int(ttl.Seconds()),
+		})
+	}).Methods(http.MethodPost)
+
+	// POST /dev/introspect (token=...) : stand-in local d'un endpoint RFC 7662
+	r.HandleFunc("/dev/introspect", func(w http.ResponseWriter, r *http.Request) {
+		httpx.JSON(w, iss.Introspect(r.PostFormValue("token")))
+	}).Methods(http.MethodPost)
+	// POST /dev/revoke (token=...) : révoque un token de développement (RFC 7009)
+	r.HandleFunc("/dev/revoke", func(w http.ResponseWriter, r *http.Request) {
+		iss.Revoke(r.PostFormValue("token"))
+		w.WriteHeader(http.StatusOK)
+	}).Methods(http.MethodPost)
+}
@@ -1,49 +1,221 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
 
+	"github.com/flotio-dev/project-service/pkg/auth"
 	"github.com/flotio-dev/project-service/pkg/db"
 	"github.com/flotio-dev/project-service/pkg/httpx"
 	"github.com/flotio-dev/project-service/pkg/middleware"
 	"github.com/gorilla/mux"
+	"gorm.io/gorm"
 )
 
 func (a *API) mountEnvVars(api *mux.Router) {
-	api.HandleFunc("/envvars", func(w http.ResponseWriter, r *http.Request) {
-		sub, groups := getUserAndGroups(r)
-		var envs []db.EnvVar
-		q := a.DB.Model(&db.EnvVar{}).Joins("JOIN projects ON projects.id = env_vars.project_id")
-		if len(groups) > 0 {
-			q = q.Where("projects.user_id = ? OR projects.group_id IN ?", sub, groups)
-		} else {
-			q = q.Where("projects.user_id = ?", sub)
-		}
-		if err := q.Order("env_vars.created_at DESC").Find(&envs).Error; err != nil {
+	api.HandleFunc("/envvars", middleware.RequireScope(auth.ScopeEnvVarsRead, func(w http.ResponseWriter, r *http.Request) {
+		id := a.identity(r)
+		// la révélation des valeurs est une opération sensible
+		reveal := middleware.HasScope(r, auth.ScopeEnvVarsReveal)
+		if reveal {
+			var ok bool
+			if id, ok = a.activeIdentity(w, r, id); !ok {
+				return
+			}
+		}
+		var projects []db.Project
+		if err := visibleProjects(a.DB.Select("id, user_id, group_id"), id, "").Find(&projects).Error; err != nil {
 			httpx.InternalError(w, err.Error())
 			return
 		}
+		roles := make(map[string]auth.Role, len(projects))
+		ids := make([]string, 0, len(projects))
+		for _, p := range projects {
+			roles[p.ID] = id.ProjectRole(p.UserID, p.GroupID)
+			ids = append(ids, p.ID)
+		}
+		envs := []db.EnvVar{}
+		if len(ids) > 0 {
+			if err := a.DB.Where("project_id IN ?", ids).Order("created_at DESC").Find(&envs).Error; err != nil {
+				httpx.InternalError(w, err.Error())
+				return
+			}
+		}
+		// valeurs masquées sans envvars:reveal, et pour les simples lecteurs du projet
+		for i := range envs {
+			if !reveal || roles[envs[i].ProjectID] < auth.RoleDeveloper {
+				envs[i].Value, envs[i].FileURL = nil, nil
+			}
+		}
 		httpx.OK(w, envs)
-	}).Methods(http.MethodGet)
+	})).Methods(http.MethodGet)
+
+	// POST /api/projects/{projectID}/envvars {"key": "API_URL", "type": "text", "value": "..."}
+	api.HandleFunc("/projects/{projectID}/envvars", middleware.RequireScope(auth.ScopeEnvVarsWrite, func(w http.ResponseWriter, r *http.Request) {
+		p, ok := a.ownedProject(w, r, auth.RoleDeveloper)
+		if !ok {
+			return
+		}
+		var in envVarInput
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			httpx.BadRequest(w, "invalid payload")
+			return
+		}
+		e := db.EnvVar{ProjectID: p.ID, Type: "text"}
+		if err := in.apply(&e); err != nil {
+			httpx.BadRequest(w, err.Error())
+			return
+		}
+		err := a.DB.Transaction(func(tx *gorm.DB) error {
+			var n int64
+			if err := tx.Model(&db.EnvVar{}).Where("project_id = ? AND key = ?", p.ID, e.Key).Count(&n).Error; err != nil {
+				return err
+			}
+			if n > 0 {
+				return errEnvVarExists
+			}
+			if err := tx.Create(&e).Error; err != nil {
+				return err
+			}
+			return emitEnvVarEvent(tx, "created", e)
+		})
+		if errors.Is(err, errEnvVarExists) {
+			httpx.Conflict(w, err.Error())
+			return
+		}
+		if err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.Created(w, e)
+	})).Methods(http.MethodPost)
+
+	// PATCH /api/projects/{projectID}/envvars/{envVarID}
+	api.HandleFunc("/projects/{projectID}/envvars/{envVarID}", middleware.RequireScope(auth.ScopeEnvVarsWrite, func(w http.ResponseWriter, r *http.Request) {
+		e, ok := a.projectEnvVar(w, r)
+		if !ok {
+			return
+		}
+		var in envVarInput
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			httpx.BadRequest(w, "invalid payload")
+			return
+		}
+		if err := in.apply(&e); err != nil {
+			httpx.BadRequest(w, err.Error())
+			return
+		}
+		err := a.DB.Transaction(func(tx *gorm.DB) error {
+			var n int64
+			if err := tx.Model(&db.EnvVar{}).Where("project_id = ? AND key = ? AND id <> ?", e.ProjectID, e.Key, e.ID).Count(&n).Error; err != nil {
+				return err
+			}
+			if n > 0 {
+				return errEnvVarExists
+			}
+			updates := map[string]any{"key": e.Key, "category": e.Category, "type": e.Type, "value": e.Value, "file_url": e.FileURL}
+			if err := tx.Model(&e).Updates(updates).Error; err != nil {
+				return err
+			}
+			return emitEnvVarEvent(tx, "updated", e)
+		})
+		if errors.Is(err, errEnvVarExists) {
+			httpx.Conflict(w, err.Error())
+			return
+		}
+		if err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.OK(w, e)
+	})).Methods(http.MethodPatch)
+
+	// DELETE /api/projects/{projectID}/envvars/{envVarID}
+	api.HandleFunc("/projects/{projectID}/envvars/{envVarID}", middleware.RequireScope(auth.ScopeEnvVarsWrite, func(w http.ResponseWriter, r *http.Request) {
+		e, ok := a.projectEnvVar(w, r)
+		if !ok {
+			return
+		}
+		err := a.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Delete(&e).Error; err != nil {
+				return err
+			}
+			return emitEnvVarEvent(tx, "deleted", e)
+		})
+		if err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.NoContent(w)
+	})).Methods(http.MethodDelete)
+}
+
+var errEnvVarExists = errors.New("an env var with this key already exists in the project")
+
+// envVarInput est le corps de création ou de modification d'une variable ; les champs absents
+// sont conservés.
+type envVarInput struct {
+	Key      *string `json:"key"`
+	Category *string `json:"category"`
+	Type     *string `json:"type"`
+	Value    *string `json:"value"`
+	FileURL  *string `json:"file_url"`
 }
 
-// helper partagé avec projects.go
-func getUserAndGroups(r *http.Request) (string, []string) {
-	sub, _ := middleware.GetValue[string](r, "sub")
-	var groups []string
-	if claims, ok := middleware.GetValue[map[string]any](r, "claims"); ok {
-		if raw, ok := claims["groups"]; ok {
-			switch v := raw.(type) {
-			case []any:
-				for _, it := range v {
-					if s, _ := it.(string); s != "" {
-						groups = append(groups, s)
-					}
-				}
-			case []string:
-				groups = append(groups, v...)
-			}
-		}
-	}
-	return sub, groups
+// apply valide l'entrée et l'applique à e : une variable text porte une valeur, une variable
+// file une URL de fichier.
+func (in envVarInput) apply(e *db.EnvVar) error {
+	if in.Key != nil {
+		e.Key = strings.TrimSpace(*in.Key)
+	}
+	if in.Category != nil {
+		e.Category = strings.TrimSpace(*in.Category)
+	}
+	if in.Type != nil {
+		e.Type = *in.Type
+	}
+	if in.Value != nil {
+		e.Value = in.Value
+	}
+	if in.FileURL != nil {
+		e.FileURL = in.FileURL
+	}
+	if e.Key == "" {
+		return errors.New("key required")
+	}
+	switch e.Type {
+	case "text":
+		if e.Value == nil {
+			return errors.New("value required for a text variable")
+		}
+		e.FileURL = nil
+	case "file":
+		if e.FileURL == nil || *e.FileURL == "" {
+			return errors.New("file_url required for a file variable")
+		}
+		e.Value = nil
+	default:
+		return errors.New("type must be text or file")
+	}
+	return nil
+}
+
+// projectEnvVar charge la variable {envVarID} d'un projet où l'appelant est au moins developer.
+func (a *API) projectEnvVar(w http.ResponseWriter, r *http.Request) (db.EnvVar, bool) {
+	var e db.EnvVar
+	p, ok := a.ownedProject(w, r, auth.RoleDeveloper)
+	if !ok {
+		return e, false
+	}
+	if err := a.DB.First(&e, "id = ? AND project_id = ?", mux.Vars(r)["envVarID"], p.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httpx.NotFound(w, "env var not found")
+			return e, false
+		}
+		httpx.InternalError(w, err.Error())
+		return e, false
+	}
+	return e, true
 }
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/flotio-dev/project-service/pkg/db"
+)
+
+func TestEnvVarInputApply(t *testing.T) {
+	str := func(s string) *string { return &s }
+	tests := []struct {
+		name    string
+		from    db.EnvVar
+		in      envVarInput
+		want    db.EnvVar
+		wantErr bool
+	}{
+		{"new text", db.EnvVar{Type: "text"}, envVarInput{Key: str(" API_URL "), Value: str("https://x")},
+			db.EnvVar{Key: "API_URL", Type: "text", Value: str("https://x")}, false},
+		{"new file", db.EnvVar{Type: "text"}, envVarInput{Key: str("google-services.json"), Type: str("file"), FileURL: str("s3://f")},
+			db.EnvVar{Key: "google-services.json", Type: "file", FileURL: str("s3://f")}, false},
+		{"text to file drops the value", db.EnvVar{Key: "K", Type: "text", Value: str("v")}, envVarInput{Type: str("file"), FileURL: str("s3://f")},
+			db.EnvVar{Key: "K", Type: "file", FileURL: str("s3://f")}, false},
+		{"partial update keeps fields", db.EnvVar{Key: "K", Category: "push", Type: "text", Value: str("v")}, envVarInput{Value: str("w")},
+			db.EnvVar{Key: "K", Category: "push", Type: "text", Value: str("w")}, false},
+		{"missing key", db.EnvVar{Type: "text"}, envVarInput{Value: str("v")}, db.EnvVar{}, true},
+		{"text without value", db.EnvVar{Type: "text"}, envVarInput{Key: str("K")}, db.EnvVar{}, true},
+		{"file without url", db.EnvVar{Type: "text"}, envVarInput{Key: str("K"), Type: str("file")}, db.EnvVar{}, true},
+		{"unknown type", db.EnvVar{Type: "text"}, envVarInput{Key: str("K"), Type: str("secret"), Value: str("v")}, db.EnvVar{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := tt.from
+			err := tt.in.apply(&e)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if e.Key != tt.want.Key || e.Category != tt.want.Category || e.Type != tt.want.Type ||
+				!equalPtr(e.Value, tt.want.Value) || !equalPtr(e.FileURL, tt.want.FileURL) {
+				t.Errorf("got %+v, want %+v", e, tt.want)
+			}
+		})
+	}
+}
+
+func equalPtr(a, b *string) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"slices"
+
+	"github.com/flotio-dev/project-service/pkg/db"
+	"gorm.io/gorm"
+)
+
+// Évènements de domaine publiés via l'outbox, en plus de ceux proposés aux webhooks (webhookEvents).
+const (
+	eventProjectCreated    = "project.created"
+	eventProjectDeleted    = "project.deleted"
+	eventChannelPromoted   = "channel.promoted"
+	eventChannelRolledBack = "channel.rolled_back"
+	eventBuildDeleted      = "build.deleted"
+	eventArtifactCreated   = "artifact.created"
+	eventArtifactReady     = "artifact.ready"
+	eventArtifactDeleted   = "artifact.deleted"
+	aggregateProject       = "project"
+	aggregateBuild         = "build"
+	aggregateChannel       = "channel"
+	aggregateArtifact      = "artifact"
+	aggregateEnvVar        = "envvar"
+)
+
+// emitEvent écrit l'évènement dans l'outbox et crée les livraisons des webhooks abonnés,
+// dans la transaction tx de la modification : l'évènement existe si et seulement si elle est validée.
+// Il doit être appelé après l'écriture de l'agrégat : le verrou de ligne ainsi tenu garantit que
+// les id de l'outbox suivent l'ordre des modifications d'un même agrégat (voir events.Relay).
+func emitEvent(tx *gorm.DB, projectID, aggregateType, aggregateID, event string, data any) error {
+	payload, err := db.NewJSON(data)
+	if err != nil {
+		return err
+	}
+	ev := db.OutboxEvent{Type: event, AggregateType: aggregateType, AggregateID: aggregateID, ProjectID: &projectID, Payload: payload}
+	if err := tx.Create(&ev).Error; err != nil {
+		return err
+	}
+	if slices.Contains(webhookEvents, event) {
+		return emitWebhookEvent(tx, projectID, event, data)
+	}
+	return nil
+}
+
+// emitBuildEvent émet un évènement portant sur un build.
+func emitBuildEvent(tx *gorm.DB, event string, b db.Build) error {
+	return emitEvent(tx, b.ProjectID, aggregateBuild, b.ID, event, b)
+}
+
+// emitArtifactEvent émet un évènement portant sur un artefact du projet projectID.
+func emitArtifactEvent(tx *gorm.DB, event, projectID string, art db.BuildArtifact) error {
+	return emitEvent(tx, projectID, aggregateArtifact, art.ID, event, art)
+}
+
+// envVarChange est la charge de envvar.changed : la variable sans sa valeur.
+type envVarChange struct {
+	Action string    `json:"action"` // created, updated ou deleted
+	EnvVar db.EnvVar `json:"envvar"`
+}
+
+// emitEnvVarEvent émet envvar.changed ; la valeur et l'URL du fichier sont masquées.
+func emitEnvVarEvent(tx *gorm.DB, action string, e db.EnvVar) error {
+	e.Value, e.FileURL = nil, nil
+	return emitEvent(tx, e.ProjectID, aggregateEnvVar, e.ID, eventEnvVarChanged, envVarChange{Action: action, EnvVar: e})
+}
+
+// emitProjectEvent émet un évènement portant sur un projet (le token GitHub n'est jamais sérialisé).
+func emitProjectEvent(tx *gorm.DB, event string, p db.Project) error {
+	return emitEvent(tx, p.ID, aggregateProject, p.ID, event, p)
+}
+
+// createProjects crée les projets et leurs évènements project.created dans une transaction.
+func createProjects(conn *gorm.DB, ps ...*db.Project) error {
+	return conn.Transaction(func(tx *gorm.DB) error {
+		for _, p := range ps {
+			if err := tx.Create(p).Error; err != nil {
+				return err
+			}
+			if err := emitProjectEvent(tx, eventProjectCreated, *p); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
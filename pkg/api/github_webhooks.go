@@ -0,0 +1,543 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/flotio-dev/project-service/pkg/buildconfig"
+	"github.com/flotio-dev/project-service/pkg/db"
+	"github.com/flotio-dev/project-service/pkg/github"
+	"github.com/flotio-dev/project-service/pkg/httpx"
+	"github.com/flotio-dev/project-service/pkg/pathfilter"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// marqueur permettant de reconnaître le commentaire de preview sur la PR
+const previewCommentMarker = "<!-- flotio:preview-builds -->"
+
+// mountGithubWebhooks monte le récepteur de webhooks GitHub (public, authentifié par signature HMAC).
+func (a *API) mountGithubWebhooks(r *mux.Router) {
+	// POST /webhooks/github
+	r.HandleFunc("/webhooks/github", func(w http.ResponseWriter, r *http.Request) {
+		if a.GithubWebhookSecret == "" {
+			httpx.NotFound(w, "github webhooks are not configured")
+			return
+		}
+		payload, err := io.ReadAll(io.LimitReader(r.Body, 10<<20))
+		if err != nil {
+			httpx.BadRequest(w, "cannot read payload")
+			return
+		}
+		if !github.VerifySignature([]byte(a.GithubWebhookSecret), payload, r.Header.Get(github.HeaderSignature)) {
+			httpx.Unauthorized(w, "invalid signature")
+			return
+		}
+
+		switch event := r.Header.Get(github.HeaderEvent); event {
+		case "ping":
+			httpx.OK(w, map[string]any{"status": "pong"})
+		case "pull_request":
+			var ev github.PullRequestEvent
+			if err := json.Unmarshal(payload, &ev); err != nil {
+				httpx.BadRequest(w, "invalid pull_request payload")
+				return
+			}
+			res, err := a.handlePullRequest(r.Context(), ev)
+			if err != nil {
+				httpx.InternalError(w, err.Error())
+				return
+			}
+			httpx.OK(w, res)
+		case "push":
+			var ev github.PushEvent
+			if err := json.Unmarshal(payload, &ev); err != nil {
+				httpx.BadRequest(w, "invalid push payload")
+				return
+			}
+			res, err := a.handlePush(r.Context(), ev)
+			if err != nil {
+				httpx.InternalError(w, err.Error())
+				return
+			}
+			httpx.OK(w, res)
+		default:
+			httpx.OK(w, map[string]any{"status": "ignored", "event": event})
+		}
+	}).Methods(http.MethodPost)
+}
+
+// previewResult résume le traitement d'un évènement pull_request.
+type previewResult struct {
+	Action   string   `json:"action"`
+	Projects []string `json:"projects"`
+	Queued   int      `json:"queued"`
+	Skipped  string   `json:"skipped,omitempty"` // raison pour laquelle la PR n'est pas buildée
+}
+
+// handlePullRequest crée/maintient les branches éphémères et leurs builds de preview.
+// Les PR venant d'un fork ne sont pas buildées : le build recevrait le token GitHub et les
+// variables d'environnement du projet pour exécuter du code d'un tiers.
+func (a *API) handlePullRequest(ctx context.Context, ev github.PullRequestEvent) (previewResult, error) {
+	res := previewResult{Action: ev.Action, Projects: []string{}}
+	if ev.Action != "closed" && ev.FromFork() {
+		res.Skipped = "pull request from a fork"
+		return res, nil
+	}
+	var ps []db.Project
+	if err := a.DB.WithContext(ctx).Where("github_repo = ?", ev.Repository.FullName).Find(&ps).Error; err != nil {
+		return res, err
+	}
+	for _, p := range ps {
+		switch ev.Action {
+		case "opened", "reopened", "synchronize":
+			cfg, err := a.resolveBuildConfig(ctx, p, ev.PullRequest.Head.SHA)
+			issues, err := configIssues(err)
+			if err != nil {
+				return res, err
+			}
+			n, failed, err := a.queuePreviewBuilds(ctx, p, ev, cfg, issues)
+			if err != nil {
+				return res, err
+			}
+			if failed != nil {
+				a.notifyPullRequestAsync(*failed)
+			}
+			res.Queued += n
+		case "closed":
+			if err := a.cleanupPreview(ctx, p, ev.Number); err != nil {
+				return res, err
+			}
+		default:
+			continue
+		}
+		res.Projects = append(res.Projects, p.ID)
+	}
+	return res, nil
+}
+
+// queuePreviewBuilds enregistre la branche éphémère de la PR et met en file un build par plateforme configurée.
+// Si le dépôt contient un flotio.yaml, seules les plateformes qu'il déclare sont buildées ; s'il est
+// invalide (issues non vide), les builds sont enregistrés en échec et le premier est retourné.
+func (a *API) queuePreviewBuilds(ctx context.Context, p db.Project, ev github.PullRequestEvent, cfg *buildconfig.Config, issues []string) (int, *db.Build, error) {
+	sha := ev.PullRequest.Head.SHA
+	var rawCfg db.JSON
+	if cfg != nil {
+		var err error
+		if rawCfg, err = db.NewJSON(cfg); err != nil {
+			return 0, nil, err
+		}
+	}
+	queued := 0
+	var failed *db.Build
+	err := a.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// upsert sur l'index unique (project_id, pull_request) : deux livraisons concurrentes
+		// (opened puis synchronize) partagent la même branche
+		br := db.Branch{ProjectID: p.ID, Name: ev.PullRequest.Head.Ref, PullRequest: &ev.Number, Ephemeral: true, HeadSHA: &sha}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:     []clause.Column{{Name: "project_id"}, {Name: "pull_request"}},
+			TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "pull_request IS NOT NULL"}}},
+			DoUpdates:   clause.Assignments(map[string]any{"name": br.Name, "head_sha": sha, "updated_at": gorm.Expr("now()")}),
+		}).Create(&br).Error
+		if err != nil {
+			return err
+		}
+
+		// les builds en attente sur un ancien commit ne servent plus à rien
+		if err := cancelSupersededBuilds(tx, br.ID, sha); err != nil {
+			return err
+		}
+
+		var platforms []string
+		for _, pl := range splitPlatforms(p.PreviewPlatforms) {
+			if cfg == nil || slices.Contains(cfg.Platforms, pl) {
+				platforms = append(platforms, pl)
+			}
+		}
+		if issues != nil {
+			failed, err = failConfigBuilds(tx, p, br, sha, platforms, issues)
+			return err
+		}
+		queued, err = queueBuilds(tx, p, br, sha, platforms, rawCfg)
+		return err
+	})
+	return queued, failed, err
+}
+
+// configIssues sépare une erreur de resolveBuildConfig : un flotio.yaml invalide donne ses erreurs
+// de validation (à enregistrer sur le build), toute autre erreur est retournée telle quelle.
+func configIssues(err error) ([]string, error) {
+	var ve *buildconfig.ValidationError
+	if !errors.As(err, &ve) {
+		return nil, err
+	}
+	issues := make([]string, 0, len(ve.Issues)+1)
+	issues = append(issues, "invalid "+buildconfig.FileName+":")
+	for _, it := range ve.Issues {
+		issues = append(issues, it.String())
+	}
+	return issues, nil
+}
+
+// failConfigBuilds enregistre, pour chaque plateforme, un build en échec au commit sha dont les logs
+// sont les erreurs du flotio.yaml, en ignorant ceux qui existent déjà (redelivery du même évènement).
+// Retourne le premier build créé.
+func failConfigBuilds(tx *gorm.DB, p db.Project, br db.Branch, sha string, platforms, issues []string) (*db.Build, error) {
+	var first *db.Build
+	for _, pl := range platforms {
+		var count int64
+		if err := tx.Model(&db.Build{}).Where("branch_id = ? AND platform = ? AND commit_sha = ?", br.ID, pl, sha).Count(&count).Error; err != nil {
+			return first, err
+		}
+		if count > 0 {
+			continue
+		}
+		b := db.Build{ProjectID: p.ID, BranchID: &br.ID, Platform: pl, Status: "failed", CommitSHA: &sha}
+		if err := tx.Create(&b).Error; err != nil {
+			return first, err
+		}
+		logs := make([]db.BuildLog, len(issues))
+		for i, line := range issues {
+			logs[i] = db.BuildLog{BuildID: b.ID, Seq: i + 1, Line: line}
+		}
+		if err := tx.Create(&logs).Error; err != nil {
+			return first, err
+		}
+		if err := emitBuildEvent(tx, eventBuildCreated, b); err != nil {
+			return first, err
+		}
+		if err := emitBuildEvent(tx, eventBuildFinished, b); err != nil {
+			return first, err
+		}
+		if first == nil {
+			first = &b
+		}
+	}
+	return first, nil
+}
+
+// cancelSupersededBuilds annule les builds en attente de la branche sur un autre commit que sha
+// et émet build.finished pour chacun.
+func cancelSupersededBuilds(tx *gorm.DB, branchID, sha string) error {
+	var builds []db.Build
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("branch_id = ? AND status = ? AND (commit_sha IS NULL OR commit_sha <> ?)", branchID, "pending", sha).
+		Find(&builds).Error
+	if err != nil || len(builds) == 0 {
+		return err
+	}
+	ids := make([]string, len(builds))
+	for i, b := range builds {
+		ids[i] = b.ID
+	}
+	if err := tx.Model(&db.Build{}).Where("id IN ?", ids).Update("status", "cancelled").Error; err != nil {
+		return err
+	}
+	for _, b := range builds {
+		b.Status = "cancelled"
+		if err := emitBuildEvent(tx, eventBuildFinished, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queueBuilds crée un build en attente par plateforme pour le commit sha de la branche,
+// en ignorant ceux qui existent déjà (redelivery du même évènement).
+func queueBuilds(tx *gorm.DB, p db.Project, br db.Branch, sha string, platforms []string, rawCfg db.JSON) (int, error) {
+	queued := 0
+	for _, pl := range platforms {
+		var count int64
+		if err := tx.Model(&db.Build{}).Where("branch_id = ? AND platform = ? AND commit_sha = ?", br.ID, pl, sha).Count(&count).Error; err != nil {
+			return queued, err
+		}
+		if count > 0 {
+			continue
+		}
+		b := db.Build{ProjectID: p.ID, BranchID: &br.ID, Platform: pl, Status: "pending", CommitSHA: &sha, Config: rawCfg}
+		if err := tx.Create(&b).Error; err != nil {
+			return queued, err
+		}
+		if err := emitBuildEvent(tx, eventBuildCreated, b); err != nil {
+			return queued, err
+		}
+		queued++
+	}
+	return queued, nil
+}
+
+// pushResult résume le traitement d'un évènement push.
+type pushResult struct {
+	Ref      string   `json:"ref"`
+	Projects []string `json:"projects"` // projets pour lesquels des builds ont été demandés
+	Skipped  []string `json:"skipped"`  // projets dont aucun fichier n'a changé
+	Queued   int      `json:"queued"`
+}
+
+// handlePush met en file les builds des projets du dépôt concernés par les fichiers modifiés.
+// Seuls les projets disposant d'un flotio.yaml sont buildés, pour les plateformes qu'il déclare.
+func (a *API) handlePush(ctx context.Context, ev github.PushEvent) (pushResult, error) {
+	res := pushResult{Ref: ev.Ref, Projects: []string{}, Skipped: []string{}}
+	branch, ok := strings.CutPrefix(ev.Ref, "refs/heads/")
+	if !ok || ev.Deleted {
+		return res, nil // tags et suppressions de branche
+	}
+	files := ev.ChangedFiles()
+	var ps []db.Project
+	if err := a.DB.WithContext(ctx).Where("github_repo = ?", ev.Repository.FullName).Find(&ps).Error; err != nil {
+		return res, err
+	}
+	for _, p := range ps {
+		// une nouvelle branche ou un push tronqué (liste de fichiers incomplète) est buildé
+		// en entier, sinon on regarde les chemins touchés
+		if !ev.Created && !ev.Truncated() && !projectPathFilter(p).Match(files) {
+			res.Skipped = append(res.Skipped, p.ID)
+			continue
+		}
+		cfg, err := a.resolveBuildConfig(ctx, p, ev.After)
+		issues, err := configIssues(err)
+		if err != nil {
+			return res, err
+		}
+		if cfg == nil && issues == nil {
+			continue
+		}
+		n, err := a.queuePushBuilds(ctx, p, branch, ev.After, cfg, issues)
+		if err != nil {
+			return res, err
+		}
+		res.Queued += n
+		res.Projects = append(res.Projects, p.ID)
+	}
+	return res, nil
+}
+
+// projectPathFilter construit le filtre de chemins d'un projet (RootDir + PathFilters).
+func projectPathFilter(p db.Project) pathfilter.Filter {
+	var f pathfilter.Filter
+	if p.RootDir != nil {
+		f.Root = *p.RootDir
+	}
+	if p.PathFilters != nil {
+		f.Patterns = pathfilter.Split(*p.PathFilters)
+	}
+	return f
+}
+
+// queuePushBuilds enregistre la branche poussée et met en file ses builds.
+// Un flotio.yaml invalide (issues non vide) ne dit pas quelles plateformes builder : un seul build
+// en échec, sans plateforme, porte alors ses erreurs.
+func (a *API) queuePushBuilds(ctx context.Context, p db.Project, name, sha string, cfg *buildconfig.Config, issues []string) (int, error) {
+	var rawCfg db.JSON
+	if cfg != nil {
+		var err error
+		if rawCfg, err = db.NewJSON(cfg); err != nil {
+			return 0, err
+		}
+	}
+	queued := 0
+	err := a.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var br db.Branch
+		err := tx.Where("project_id = ? AND name = ? AND pull_request IS NULL", p.ID, name).First(&br).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			br = db.Branch{ProjectID: p.ID, Name: name, HeadSHA: &sha}
+			if err := tx.Create(&br).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			if err := tx.Model(&br).Update("head_sha", sha).Error; err != nil {
+				return err
+			}
+		}
+		if issues != nil {
+			_, err = failConfigBuilds(tx, p, br, sha, []string{""}, issues)
+			return err
+		}
+		queued, err = queueBuilds(tx, p, br, sha, cfg.Platforms, rawCfg)
+		return err
+	})
+	return queued, err
+}
+
+// cleanupPreview supprime la branche éphémère d'une PR fermée, ses builds, leurs logs et leurs artefacts.
+func (a *API) cleanupPreview(ctx context.Context, p db.Project, number int) error {
+	var arts []db.BuildArtifact
+	err := a.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var br db.Branch
+		if err := tx.Where("project_id = ? AND pull_request = ? AND ephemeral", p.ID, number).First(&br).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		var builds []db.Build
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("branch_id = ?", br.ID).Find(&builds).Error; err != nil {
+			return err
+		}
+		ids := make([]string, len(builds))
+		for i, b := range builds {
+			ids[i] = b.ID
+		}
+		if err := tx.Where("build_id IN ?", ids).Find(&arts).Error; err != nil {
+			return err
+		}
+		if len(ids) > 0 {
+			if err := tx.Where("build_id IN ?", ids).Delete(&db.BuildArtifact{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("build_id IN ?", ids).Delete(&db.BuildLog{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("id IN ?", ids).Delete(&db.Build{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Delete(&br).Error; err != nil {
+			return err
+		}
+		for _, art := range arts {
+			if err := emitArtifactEvent(tx, eventArtifactDeleted, p.ID, art); err != nil {
+				return err
+			}
+		}
+		for _, b := range builds {
+			if err := emitBuildEvent(tx, eventBuildDeleted, b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// les objets ne sont supprimés qu'une fois les lignes effacées
+	return a.deleteArtifactObjects(ctx, arts)
+}
+
+// notifyPullRequest publie (ou met à jour) sur la PR un commentaire listant les liens de téléchargement.
+func (a *API) notifyPullRequest(ctx context.Context, b db.Build) error {
+	if b.BranchID == nil {
+		return nil
+	}
+	var br db.Branch
+	if err := a.DB.WithContext(ctx).First(&br, "id = ?", *b.BranchID).Error; err != nil {
+		return err
+	}
+	if br.PullRequest == nil {
+		return nil
+	}
+	var p db.Project
+	if err := a.DB.WithContext(ctx).First(&p, "id = ?", br.ProjectID).Error; err != nil {
+		return err
+	}
+	if p.GithubRepo == nil || p.GithubToken == nil || *p.GithubToken == "" {
+		return nil
+	}
+
+	q := a.DB.WithContext(ctx).Where("branch_id = ?", br.ID)
+	if br.HeadSHA != nil {
+		q = q.Where("commit_sha = ?", *br.HeadSHA)
+	}
+	var builds []db.Build
+	if err := q.Order("platform ASC").Find(&builds).Error; err != nil {
+		return err
+	}
+	// un build en échec jamais pris par un worker a été refusé pour un flotio.yaml invalide
+	var configErrors []string
+	for _, b := range builds {
+		if b.Status == "failed" && b.WorkerID == nil {
+			err := a.DB.WithContext(ctx).Model(&db.BuildLog{}).Where("build_id = ?", b.ID).
+				Order("seq ASC").Pluck("line", &configErrors).Error
+			if err != nil {
+				return err
+			}
+			break
+		}
+	}
+	body := previewComment(br, builds, a.previewLinks(ctx, builds), configErrors)
+
+	gh := a.githubClient(*p.GithubToken)
+	if br.CommentID != nil {
+		err := gh.UpdateIssueComment(ctx, *p.GithubRepo, *br.CommentID, body)
+		var ghErr *github.Error
+		if err == nil || !errors.As(err, &ghErr) || ghErr.StatusCode != http.StatusNotFound {
+			return err
+		}
+		// commentaire supprimé côté GitHub: on en recrée un
+	}
+	id, err := gh.CreateIssueComment(ctx, *p.GithubRepo, *br.PullRequest, body)
+	if err != nil {
+		return err
+	}
+	return a.DB.WithContext(ctx).Model(&br).Update("comment_id", id).Error
+}
+
+// previewLinks signe, pour chaque build réussi, l'URL de son artefact installable.
+// Les liens ont la durée de vie d'un lien d'installation par défaut: le commentaire est réécrit à chaque build.
+func (a *API) previewLinks(ctx context.Context, builds []db.Build) map[string]string {
+	links := map[string]string{}
+	if a.PublicBaseURL == "" {
+		return links
+	}
+	for _, b := range builds {
+		if b.Status != "success" {
+			continue
+		}
+		art, err := a.installableArtifact(ctx, b)
+		if err != nil {
+			continue
+		}
+		links[b.ID], _ = a.signedArtifactURL(art.ID, defaultInstallLinkTTL)
+	}
+	return links
+}
+
+// previewComment rend le commentaire markdown des builds de preview.
+// links associe un build à l'URL signée de son artefact; DownloadURL sert de repli.
+// configErrors sont les erreurs du flotio.yaml qui ont empêché les builds.
+func previewComment(br db.Branch, builds []db.Build, links map[string]string, configErrors []string) string {
+	var sb strings.Builder
+	sb.WriteString(previewCommentMarker + "\n")
+	sb.WriteString("### Preview builds\n\n")
+	if br.HeadSHA != nil && len(*br.HeadSHA) >= 7 {
+		fmt.Fprintf(&sb, "Commit `%s`\n\n", (*br.HeadSHA)[:7])
+	}
+	sb.WriteString("| Platform | Status | Download |\n|---|---|---|\n")
+	for _, b := range builds {
+		link := "-"
+		if b.Status == "success" {
+			u := links[b.ID]
+			if u == "" {
+				u = b.DownloadURL
+			}
+			if u != "" {
+				link = fmt.Sprintf("[Download](%s)", u)
+			}
+		}
+		fmt.Fprintf(&sb, "| %s | %s | %s |\n", b.Platform, b.Status, link)
+	}
+	if len(configErrors) > 0 {
+		sb.WriteString("\n```\n" + strings.Join(configErrors, "\n") + "\n```\n")
+	}
+	return sb.String()
+}
+
+// notifyPullRequestAsync lance notifyPullRequest hors du chemin de la requête.
+func (a *API) notifyPullRequestAsync(b db.Build) {
+	go func() {
+		if err := a.notifyPullRequest(context.Background(), b); err != nil {
+			log.Printf("preview: cannot comment pull request for build %s: %v", b.ID, err)
+		}
+	}()
+}
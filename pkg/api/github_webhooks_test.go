@@ -0,0 +1,171 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/flotio-dev/project-service/pkg/buildconfig"
+	"github.com/flotio-dev/project-service/pkg/db"
+	"github.com/flotio-dev/project-service/pkg/db/dbtest"
+	"github.com/flotio-dev/project-service/pkg/github"
+)
+
+func TestPreviewCommentLinks(t *testing.T) {
+	sha := "0123456789abcdef"
+	br := db.Branch{HeadSHA: &sha}
+	tests := []struct {
+		name  string
+		build db.Build
+		links map[string]string
+		want  string
+	}{
+		{"signed artifact url", db.Build{ID: "b1", Platform: "ANDROID", Status: "success"},
+			map[string]string{"b1": "https://ci.example/artifacts/a1?sig"}, "| ANDROID | success | [Download](https://ci.example/artifacts/a1?sig) |"},
+		{"legacy download url", db.Build{ID: "b2", Platform: "IOS", Status: "success", DownloadURL: "https://cdn/app.ipa"},
+			nil, "| IOS | success | [Download](https://cdn/app.ipa) |"},
+		{"signed url wins", db.Build{ID: "b3", Platform: "IOS", Status: "success", DownloadURL: "https://cdn/app.ipa"},
+			map[string]string{"b3": "https://ci.example/artifacts/a3"}, "[Download](https://ci.example/artifacts/a3)"},
+		{"no artifact", db.Build{ID: "b4", Platform: "ANDROID", Status: "success"}, nil, "| ANDROID | success | - |"},
+		{"not finished", db.Build{ID: "b5", Platform: "ANDROID", Status: "running"},
+			map[string]string{"b5": "https://ci.example/artifacts/a5"}, "| ANDROID | running | - |"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := previewComment(br, []db.Build{tt.build}, tt.links, nil)
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("comment does not contain %q:\n%s", tt.want, got)
+			}
+			if !strings.Contains(got, "Commit `0123456`") {
+				t.Errorf("comment misses short sha:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestConfigIssues(t *testing.T) {
+	_, err := buildconfig.Parse([]byte("version: 1\nplatforms: [PLAYSTATION]\n"))
+	issues, err := configIssues(err)
+	if err != nil {
+		t.Fatalf("configIssues() error = %v", err)
+	}
+	if len(issues) < 2 || issues[0] != "invalid flotio.yaml:" || !strings.Contains(issues[1], "line 2") {
+		t.Errorf("issues = %q", issues)
+	}
+
+	boom := errors.New("github unavailable")
+	if issues, err := configIssues(boom); err != boom || issues != nil {
+		t.Errorf("configIssues(other) = %q, %v, want nil, %v", issues, err, boom)
+	}
+	if issues, err := configIssues(nil); err != nil || issues != nil {
+		t.Errorf("configIssues(nil) = %q, %v", issues, err)
+	}
+}
+
+func TestPreviewCommentConfigErrors(t *testing.T) {
+	builds := []db.Build{{ID: "b1", Platform: "ANDROID", Status: "failed"}}
+	got := previewComment(db.Branch{}, builds, nil, []string{"invalid flotio.yaml:", "line 2: platforms: unknown platform"})
+	if !strings.Contains(got, "| ANDROID | failed | - |") || !strings.Contains(got, "```\ninvalid flotio.yaml:\nline 2: platforms: unknown platform\n```") {
+		t.Errorf("comment misses config errors:\n%s", got)
+	}
+	if got := previewComment(db.Branch{}, builds, nil, nil); strings.Contains(got, "```") {
+		t.Errorf("comment without config errors has a code block:\n%s", got)
+	}
+}
+
+func pullRequestEvent(action string, fork bool) github.PullRequestEvent {
+	var ev github.PullRequestEvent
+	ev.Action, ev.Number = action, 7
+	ev.Repository = github.Repository{ID: 1, FullName: "acme/app"}
+	ev.PullRequest.Head.Ref, ev.PullRequest.Head.SHA = "feature", "new-sha"
+	ev.PullRequest.Head.Repo = &github.Repository{ID: 1, FullName: "acme/app"}
+	if fork {
+		ev.PullRequest.Head.Repo = &github.Repository{ID: 2, FullName: "someone/app"}
+	}
+	return ev
+}
+
+func TestHandlePullRequestFork(t *testing.T) {
+	gdb, fake := dbtest.Open(t)
+	a := &API{DB: gdb}
+	res, err := a.handlePullRequest(context.Background(), pullRequestEvent("opened", true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Skipped == "" || res.Queued != 0 {
+		t.Errorf("fork result = %+v, want skipped", res)
+	}
+	if qs := fake.Queries(`.`); len(qs) != 0 {
+		t.Errorf("fork pull request ran %d queries", len(qs))
+	}
+}
+
+func TestHandlePullRequestQueue(t *testing.T) {
+	gdb, fake := dbtest.Open(t)
+	fake.Return(`FROM "projects"`, dbtest.Rows([]string{"id", "preview_platforms"}, []any{"p1", "ANDROID,IOS"}))
+	fake.Return(`INSERT INTO "branches"`, dbtest.Rows([]string{"id"}, []any{"br1"}))
+	// un build en attente sur l'ancien commit de la PR
+	fake.Return(`FROM "builds" WHERE .*status = .* FOR UPDATE`,
+		dbtest.Rows([]string{"id", "project_id", "branch_id", "platform", "status", "commit_sha"}, []any{"old", "p1", "br1", "ANDROID", "pending", "old-sha"}))
+	var number int64
+	fake.Handle(`INSERT INTO build_counters`, func(dbtest.Query) dbtest.Result {
+		number++
+		return dbtest.Rows([]string{"last"}, []any{number})
+	})
+
+	a := &API{DB: gdb}
+	res, err := a.handlePullRequest(context.Background(), pullRequestEvent("synchronize", false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Queued != 2 || len(res.Projects) != 1 {
+		t.Errorf("result = %+v, want 2 builds queued for p1", res)
+	}
+
+	upsert := fake.Queries(`INSERT INTO "branches" .* ON CONFLICT \("project_id","pull_request"\) +WHERE pull_request IS NOT NULL DO UPDATE`)
+	if len(upsert) != 1 {
+		t.Errorf("branch is not upserted: %v", fake.Queries(`INSERT INTO "branches"`))
+	}
+	cancel := fake.Queries(`UPDATE "builds" SET "status"`)
+	if len(cancel) != 1 || cancel[0].Args[0] != "cancelled" || cancel[0].Args[2] != "old" {
+		t.Errorf("superseded build is not cancelled: %v", cancel)
+	}
+	var platforms []any
+	for _, q := range fake.Queries(`INSERT INTO "builds"`) {
+		platforms = append(platforms, queryValue(t, q, "platform"))
+	}
+	if fmt.Sprint(platforms) != "[ANDROID IOS]" {
+		t.Errorf("queued platforms = %v, want [ANDROID IOS]", platforms)
+	}
+	events := map[string]int{}
+	for _, q := range fake.Queries(`INSERT INTO "outbox_events"`) {
+		events[fmt.Sprint(queryValue(t, q, "type"))]++
+	}
+	if events[eventBuildFinished] != 1 || events[eventBuildCreated] != 2 {
+		t.Errorf("events = %v, want 1 %s and 2 %s", events, eventBuildFinished, eventBuildCreated)
+	}
+}
+
+func TestHandlePullRequestCleanup(t *testing.T) {
+	gdb, fake := dbtest.Open(t)
+	fake.Return(`FROM "projects"`, dbtest.Rows([]string{"id"}, []any{"p1"}))
+	fake.Return(`FROM "branches"`, dbtest.Rows([]string{"id", "project_id", "pull_request", "ephemeral"}, []any{"br1", "p1", int64(7), true}))
+	fake.Return(`FROM "builds" WHERE branch_id = .* FOR UPDATE`,
+		dbtest.Rows([]string{"id", "project_id", "branch_id", "platform", "status"}, []any{"b1", "p1", "br1", "ANDROID", "success"}))
+
+	a := &API{DB: gdb}
+	// la fermeture d'une PR venant d'un fork nettoie aussi (rien n'a été créé)
+	if _, err := a.handlePullRequest(context.Background(), pullRequestEvent("closed", true)); err != nil {
+		t.Fatal(err)
+	}
+	for _, pattern := range []string{`DELETE FROM "build_artifacts"`, `DELETE FROM "build_logs"`, `DELETE FROM "builds"`, `DELETE FROM "branches"`} {
+		if len(fake.Queries(pattern)) != 1 {
+			t.Errorf("cleanup did not run %s", pattern)
+		}
+	}
+	if qs := fake.Queries(`INSERT INTO "outbox_events"`); len(qs) != 1 {
+		t.Errorf("cleanup emitted %d events, want 1 build.deleted", len(qs))
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/flotio-dev/project-service/pkg/auth"
+	"github.com/flotio-dev/project-service/pkg/db"
+	"github.com/flotio-dev/project-service/pkg/httpx"
+	"github.com/flotio-dev/project-service/pkg/middleware"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+)
+
+// identity retourne l'identité de l'appelant : sub, groupes normalisés et rôles associés.
+func (a *API) identity(r *http.Request) auth.Identity {
+	claims, _ := middleware.GetValue[jwt.MapClaims](r, "claims")
+	id := a.GroupMapping.Identity(claims)
+	if id.Sub == "" {
+		id.Sub, _ = middleware.GetValue[string](r, "sub")
+	}
+	return id
+}
+
+// visibleProjects restreint q aux projets de l'appelant : propriétaire, ou membre du groupe
+// du projet ou d'un groupe ancêtre. table préfixe les colonnes (ex: "projects").
+func visibleProjects(q *gorm.DB, id auth.Identity, table string) *gorm.DB {
+	col := func(c string) string {
+		if table == "" {
+			return c
+		}
+		return table + "." + c
+	}
+	conds := []string{col("user_id") + " = ?"}
+	args := []any{id.Sub}
+	for _, g := range id.GroupPaths() {
+		conds = append(conds, col("group_id")+" = ?", col("group_id")+` LIKE ? ESCAPE '\'`)
+		args = append(args, g, likeEscaper.Replace(g)+"/%")
+	}
+	return q.Where("("+strings.Join(conds, " OR ")+")", args...)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// requireProjectRole vérifie le rôle de l'appelant sur le projet ; sinon répond 403.
+func requireProjectRole(w http.ResponseWriter, id auth.Identity, p db.Project, min auth.Role) bool {
+	if id.ProjectRole(p.UserID, p.GroupID) < min {
+		httpx.Forbidden(w, "forbidden")
+		return false
+	}
+	return true
+}
+
+// checkTargetGroup normalise le groupe d'un projet créé ou transféré et vérifie que
+// l'appelant y a au moins le rôle developer ; sinon répond 400/403.
+func checkTargetGroup(w http.ResponseWriter, id auth.Identity, groupID **string) bool {
+	if *groupID == nil {
+		return true
+	}
+	g := auth.NormalizeGroupPath(**groupID)
+	if g == "" {
+		*groupID = nil
+		return true
+	}
+	*groupID = &g
+	if id.GroupRole(g) < auth.RoleDeveloper {
+		httpx.Forbidden(w, "not a member of group "+g)
+		return false
+	}
+	return true
+}
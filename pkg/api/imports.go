@@ -0,0 +1,219 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flotio-dev/project-service/pkg/auth"
+	"github.com/flotio-dev/project-service/pkg/db"
+	"github.com/flotio-dev/project-service/pkg/github"
+	"github.com/flotio-dev/project-service/pkg/httpx"
+	"github.com/flotio-dev/project-service/pkg/middleware"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+func (a *API) mountImports(api *mux.Router) {
+	// POST /api/projects/import/github/bulk
+	api.HandleFunc("/projects/import/github/bulk", middleware.RequireScope(auth.ScopeProjectsWrite, func(w http.ResponseWriter, r *http.Request) {
+		sub, _ := middleware.GetValue[string](r, "sub")
+		var in struct {
+			Token     string   `json:"token"`
+			Owner     string   `json:"owner"`
+			OwnerType string   `json:"owner_type"` // org ou user (défaut)
+			Repos     []string `json:"repos"`      // noms ou full_names à importer
+			All       bool     `json:"all"`
+			GroupID   *string  `json:"group_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Token == "" {
+			httpx.BadRequest(w, "invalid payload (token required)")
+			return
+		}
+		if in.OwnerType == "" {
+			in.OwnerType = "user"
+		}
+		if in.OwnerType != "user" && in.OwnerType != "org" {
+			httpx.BadRequest(w, "owner_type must be user or org")
+			return
+		}
+		if in.OwnerType == "org" && in.Owner == "" {
+			httpx.BadRequest(w, "owner required for an organization")
+			return
+		}
+		if !in.All && len(in.Repos) == 0 {
+			httpx.BadRequest(w, "provide repos or all=true")
+			return
+		}
+		id := a.identity(r)
+		if !checkTargetGroup(w, id, &in.GroupID) {
+			return
+		}
+		job := db.ImportJob{UserID: sub, GroupID: in.GroupID, Owner: in.Owner, Status: "pending"}
+		if err := a.DB.Create(&job).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		go a.runImportJob(job, id, in.Token, in.OwnerType, in.Repos, in.All)
+		httpx.Created(w, job)
+	})).Methods(http.MethodPost)
+
+	// GET /api/projects/import/jobs
+	api.HandleFunc("/projects/import/jobs", middleware.RequireScope(auth.ScopeProjectsRead, func(w http.ResponseWriter, r *http.Request) {
+		sub, _ := middleware.GetValue[string](r, "sub")
+		var jobs []db.ImportJob
+		if err := a.DB.Where("user_id = ?", sub).Order("created_at DESC").Limit(50).Find(&jobs).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.OK(w, jobs)
+	})).Methods(http.MethodGet)
+
+	// GET /api/projects/import/jobs/{jobID}
+	api.HandleFunc("/projects/import/jobs/{jobID}", middleware.RequireScope(auth.ScopeProjectsRead, func(w http.ResponseWriter, r *http.Request) {
+		sub, _ := middleware.GetValue[string](r, "sub")
+		var job db.ImportJob
+		err := a.DB.Preload("Items", func(tx *gorm.DB) *gorm.DB { return tx.Order("id ASC") }).
+			First(&job, "id = ? AND user_id = ?", mux.Vars(r)["jobID"], sub).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				httpx.NotFound(w, "import job not found")
+				return
+			}
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.OK(w, job)
+	})).Methods(http.MethodGet)
+}
+
+// runImportJob liste les dépôts de l'owner, applique la sélection et crée un projet par dépôt non encore lié.
+// id est l'identité du créateur du job, capturée à la requête.
+func (a *API) runImportJob(job db.ImportJob, id auth.Identity, token, ownerType string, selection []string, all bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	tx := a.DB.WithContext(ctx)
+	fail := func(err error) {
+		msg := err.Error()
+		now := time.Now()
+		if err := tx.Model(&job).Updates(map[string]any{"status": "failed", "error": msg, "finished_at": now}).Error; err != nil {
+			log.Printf("import job %s: %v", job.ID, err)
+		}
+	}
+	if err := tx.Model(&job).Update("status", "running").Error; err != nil {
+		log.Printf("import job %s: %v", job.ID, err)
+		return
+	}
+
+	gh := a.githubClient(token)
+	path, query, err := gh.OwnerReposPath(ctx, job.Owner, ownerType)
+	if err != nil {
+		fail(fmt.Errorf("cannot resolve owner: %w", err))
+		return
+	}
+	repos, err := gh.ListAll(ctx, path, query)
+	if err != nil {
+		fail(fmt.Errorf("cannot list repositories: %w", err))
+		return
+	}
+	if job.Owner != "" {
+		owned := repos[:0]
+		for _, repo := range repos {
+			if strings.EqualFold(repo.Owner.Login, job.Owner) {
+				owned = append(owned, repo)
+			}
+		}
+		repos = owned
+	}
+
+	var items []db.ImportJobItem
+	if !all {
+		byName := make(map[string]github.Repo, len(repos)*2)
+		for _, repo := range repos {
+			byName[strings.ToLower(repo.FullName)] = repo
+			byName[strings.ToLower(repo.Name)] = repo
+		}
+		var selected []github.Repo
+		for _, name := range selection {
+			if repo, ok := byName[strings.ToLower(strings.TrimSpace(name))]; ok {
+				selected = append(selected, repo)
+				continue
+			}
+			items = append(items, db.ImportJobItem{FullName: name, Status: "failed", Message: "repository not found or not accessible"})
+		}
+		repos = selected
+	}
+
+	job.Total = len(repos) + len(items)
+	job.Failed = len(items)
+	for i := range items {
+		items[i].JobID = job.ID
+		if err := tx.Create(&items[i]).Error; err != nil {
+			log.Printf("import job %s: %v", job.ID, err)
+		}
+	}
+	if err := tx.Model(&job).Updates(map[string]any{"total": job.Total, "failed": job.Failed}).Error; err != nil {
+		log.Printf("import job %s: %v", job.ID, err)
+	}
+
+	for _, repo := range repos {
+		item := a.importRepo(ctx, job, id, token, repo)
+		switch item.Status {
+		case "imported":
+			job.Imported++
+		case "skipped":
+			job.Skipped++
+		default:
+			job.Failed++
+		}
+		if err := tx.Create(&item).Error; err != nil {
+			log.Printf("import job %s: %v", job.ID, err)
+		}
+		if err := tx.Model(&job).Updates(map[string]any{"imported": job.Imported, "skipped": job.Skipped, "failed": job.Failed}).Error; err != nil {
+			log.Printf("import job %s: %v", job.ID, err)
+		}
+	}
+
+	now := time.Now()
+	if err := tx.Model(&job).Updates(map[string]any{"status": "done", "finished_at": now}).Error; err != nil {
+		log.Printf("import job %s: %v", job.ID, err)
+	}
+}
+
+// importRepo crée le projet d'un dépôt, sauf s'il est déjà lié à un projet visible par le créateur
+// du job (les siens, ceux de ses groupes et de leurs sous-groupes).
+func (a *API) importRepo(ctx context.Context, job db.ImportJob, id auth.Identity, token string, repo github.Repo) db.ImportJobItem {
+	item := db.ImportJobItem{JobID: job.ID, FullName: repo.FullName}
+	q := visibleProjects(a.DB.WithContext(ctx).Model(&db.Project{}), id, "").Where("github_repo = ?", repo.FullName)
+	var existing db.Project
+	err := q.Select("id").First(&existing).Error
+	switch {
+	case err == nil:
+		item.Status = "skipped"
+		item.ProjectID = &existing.ID
+		item.Message = "repository already linked"
+		return item
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		item.Status = "failed"
+		item.Message = err.Error()
+		return item
+	}
+
+	p := db.Project{UserID: job.UserID, GroupID: job.GroupID, Name: repo.Name, GithubToken: &token, GithubRepo: &repo.FullName}
+	if repo.HTMLURL != "" {
+		p.GithubURL = &repo.HTMLURL
+	}
+	if err := createProjects(a.DB.WithContext(ctx), &p); err != nil {
+		item.Status = "failed"
+		item.Message = err.Error()
+		return item
+	}
+	item.Status = "imported"
+	item.ProjectID = &p.ID
+	return item
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flotio-dev/project-service/pkg/auth"
+	"github.com/flotio-dev/project-service/pkg/db"
+	"github.com/flotio-dev/project-service/pkg/db/dbtest"
+)
+
+// importFixture sert les dépôts de l'organisation acme (sur deux pages) et de l'utilisateur me.
+// Le dépôt acme/lib est déjà lié au projet p-lib ; les projets créés reçoivent l'id p-new.
+func importFixture(t *testing.T) (*API, *dbtest.DB) {
+	repo := func(owner, name string) map[string]any {
+		return map[string]any{"name": name, "full_name": owner + "/" + name, "html_url": "https://github.com/" + owner + "/" + name, "owner": map[string]any{"login": owner}}
+	}
+	gh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var repos []map[string]any
+		switch {
+		case r.URL.Path == "/user":
+			_, _ = w.Write([]byte(`{"login":"me"}`))
+			return
+		case r.URL.Path == "/orgs/acme/repos" && r.URL.Query().Get("page") == "1":
+			w.Header().Set("Link", `<`+r.URL.Path+`?page=2>; rel="next"`)
+			repos = append(repos, repo("acme", "app"), repo("other", "shared"))
+		case r.URL.Path == "/orgs/acme/repos":
+			repos = append(repos, repo("acme", "lib"))
+		case r.URL.Path == "/user/repos":
+			repos = append(repos, repo("me", "tool"), repo("me", "site"))
+		default:
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(repos)
+	}))
+	t.Cleanup(gh.Close)
+
+	gdb, fake := dbtest.Open(t)
+	fake.Handle(`SELECT "id" FROM "projects"`, func(q dbtest.Query) dbtest.Result {
+		for _, arg := range q.Args {
+			if arg == "acme/lib" {
+				return dbtest.Rows([]string{"id"}, []any{"p-lib"})
+			}
+		}
+		return dbtest.Result{}
+	})
+	fake.Return(`INSERT INTO "projects"`, dbtest.Rows([]string{"id"}, []any{"p-new"}))
+	return &API{DB: gdb, githubURL: gh.URL}, fake
+}
+
+// importItems retourne les items enregistrés, par dépôt.
+func importItems(t *testing.T, fake *dbtest.DB) map[string]dbtest.Query {
+	t.Helper()
+	items := map[string]dbtest.Query{}
+	for _, q := range fake.Queries(`INSERT INTO "import_job_items"`) {
+		items[queryValue(t, q, "full_name").(string)] = q
+	}
+	return items
+}
+
+// lastJobUpdate retourne la dernière mise à jour du job portant column.
+func lastJobUpdate(t *testing.T, fake *dbtest.DB, column string) dbtest.Query {
+	t.Helper()
+	updates := fake.Queries(`UPDATE "import_jobs" SET .*"` + column + `"=`)
+	if len(updates) == 0 {
+		t.Fatalf("job %s never updated", column)
+	}
+	return updates[len(updates)-1]
+}
+
+func TestRunImportJobSelection(t *testing.T) {
+	a, fake := importFixture(t)
+	job := db.ImportJob{ID: "j1", UserID: "u1", Owner: "acme"}
+	a.runImportJob(job, auth.Identity{Sub: "u1"}, "ghp_x", "org", []string{"app", " ACME/LIB ", "shared", "missing"}, false)
+
+	items := importItems(t, fake)
+	tests := []struct {
+		repo, status, project string
+	}{
+		{"acme/app", "imported", "p-new"},
+		{"acme/lib", "skipped", "p-lib"},
+		{"shared", "failed", ""}, // dépôt d'un autre owner listé par GitHub
+		{"missing", "failed", ""},
+	}
+	for _, tt := range tests {
+		item, ok := items[tt.repo]
+		if !ok {
+			t.Errorf("no item for %s", tt.repo)
+			continue
+		}
+		if got := queryValue(t, item, "status"); got != tt.status {
+			t.Errorf("%s status = %v (%v), want %s", tt.repo, got, queryValue(t, item, "message"), tt.status)
+		}
+		if tt.project != "" {
+			if got := queryValue(t, item, "project_id"); got == nil || *got.(*string) != tt.project {
+				t.Errorf("%s project = %v, want %s", tt.repo, got, tt.project)
+			}
+		}
+		if got := queryValue(t, item, "job_id"); got != "j1" {
+			t.Errorf("%s job_id = %v, want j1", tt.repo, got)
+		}
+	}
+	if len(items) != len(tests) {
+		t.Errorf("%d items, want %d", len(items), len(tests))
+	}
+
+	projects := fake.Queries(`INSERT INTO "projects"`)
+	if len(projects) != 1 {
+		t.Fatalf("%d projects created, want 1", len(projects))
+	}
+	if got := queryValue(t, projects[0], "github_repo"); *got.(*string) != "acme/app" {
+		t.Errorf("project repo = %v, want acme/app", *got.(*string))
+	}
+	if got := queryValue(t, projects[0], "github_token"); *got.(*string) != "ghp_x" {
+		t.Errorf("project token = %v, want the job token", *got.(*string))
+	}
+
+	if got := queryValue(t, lastJobUpdate(t, fake, "total"), "total"); got != 4 {
+		t.Errorf("total = %v, want 4", got)
+	}
+	counts := lastJobUpdate(t, fake, "imported")
+	for col, want := range map[string]int{"imported": 1, "skipped": 1, "failed": 2} {
+		if got := queryValue(t, counts, col); got != want {
+			t.Errorf("%s = %v, want %d", col, got, want)
+		}
+	}
+	if got := queryValue(t, lastJobUpdate(t, fake, "status"), "status"); got != "done" {
+		t.Errorf("job status = %v, want done", got)
+	}
+}
+
+func TestRunImportJobAll(t *testing.T) {
+	a, fake := importFixture(t)
+	a.runImportJob(db.ImportJob{ID: "j1", UserID: "u1"}, auth.Identity{Sub: "u1"}, "ghp_x", "user", nil, true)
+
+	items := importItems(t, fake)
+	for _, repo := range []string{"me/tool", "me/site"} {
+		if item, ok := items[repo]; !ok || queryValue(t, item, "status") != "imported" {
+			t.Errorf("%s not imported", repo)
+		}
+	}
+	if len(items) != 2 {
+		t.Errorf("%d items, want 2", len(items))
+	}
+	if got := queryValue(t, lastJobUpdate(t, fake, "status"), "status"); got != "done" {
+		t.Errorf("job status = %v, want done", got)
+	}
+}
+
+func TestRunImportJobListingFails(t *testing.T) {
+	a, fake := importFixture(t)
+	a.runImportJob(db.ImportJob{ID: "j1", UserID: "u1", Owner: "ghost"}, auth.Identity{Sub: "u1"}, "ghp_x", "org", nil, true)
+
+	update := lastJobUpdate(t, fake, "status")
+	if got := queryValue(t, update, "status"); got != "failed" {
+		t.Fatalf("job status = %v, want failed", got)
+	}
+	if msg := queryValue(t, update, "error").(string); !strings.Contains(msg, "cannot list repositories") {
+		t.Errorf("error = %q", msg)
+	}
+	if qs := fake.Queries(`INSERT`); len(qs) != 0 {
+		t.Errorf("failed job wrote %v", qs)
+	}
+}
@@ -0,0 +1,401 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flotio-dev/project-service/pkg/auth"
+	"github.com/flotio-dev/project-service/pkg/db"
+	"github.com/flotio-dev/project-service/pkg/httpx"
+	"github.com/flotio-dev/project-service/pkg/middleware"
+	"github.com/gorilla/mux"
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+)
+
+// durées de validité des liens d'installation
+const (
+	defaultInstallLinkTTL = 7 * 24 * time.Hour
+	maxInstallLinkTTL     = 90 * 24 * time.Hour
+)
+
+// installLinkView est un lien d'installation avec son URL publique (le token n'est connu qu'à la création).
+type installLinkView struct {
+	db.InstallLink
+	URL   string `json:"url,omitempty"`
+	Token string `json:"token,omitempty"`
+}
+
+func (a *API) mountInstallLinks(api *mux.Router) {
+	// POST /api/builds/{buildID}/install-links
+	api.HandleFunc("/builds/{buildID}/install-links", middleware.RequireScope(auth.ScopeBuildsWrite, func(w http.ResponseWriter, r *http.Request) {
+		sub, _ := middleware.GetValue[string](r, "sub")
+		b, ok := a.ownedBuild(w, r, auth.RoleDeveloper)
+		if !ok {
+			return
+		}
+		var in struct {
+			ExpiresIn int64 `json:"expires_in"` // secondes
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+				httpx.BadRequest(w, "invalid json")
+				return
+			}
+		}
+		ttl := defaultInstallLinkTTL
+		if in.ExpiresIn > 0 {
+			ttl = time.Duration(in.ExpiresIn) * time.Second
+		}
+		if ttl > maxInstallLinkTTL {
+			httpx.BadRequest(w, "expires_in too large (max 90 days)")
+			return
+		}
+		if !a.installLinksEnabled(w) {
+			return
+		}
+		if _, err := a.installableArtifact(r.Context(), b); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				httpx.Conflict(w, "build has no installable artifact")
+				return
+			}
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		raw := make([]byte, 24)
+		if _, err := rand.Read(raw); err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		token := base64.RawURLEncoding.EncodeToString(raw)
+		link := db.InstallLink{BuildID: b.ID, CreatedBy: sub, TokenHash: hashToken(token), ExpiresAt: time.Now().Add(ttl)}
+		if err := a.DB.Create(&link).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.Created(w, installLinkView{InstallLink: link, Token: token, URL: a.installURL(token)})
+	})).Methods(http.MethodPost)
+
+	// GET /api/builds/{buildID}/install-links
+	api.HandleFunc("/builds/{buildID}/install-links", middleware.RequireScope(auth.ScopeBuildsRead, func(w http.ResponseWriter, r *http.Request) {
+		b, ok := a.ownedBuild(w, r, auth.RoleViewer)
+		if !ok {
+			return
+		}
+		var links []db.InstallLink
+		if err := a.DB.Where("build_id = ?", b.ID).Order("created_at DESC").Find(&links).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.OK(w, links)
+	})).Methods(http.MethodGet)
+
+	// PATCH /api/builds/{buildID}/install-links/{linkID} : {"revoked": true|false}
+	api.HandleFunc("/builds/{buildID}/install-links/{linkID}", middleware.RequireScope(auth.ScopeBuildsWrite, func(w http.ResponseWriter, r *http.Request) {
+		b, ok := a.ownedBuild(w, r, auth.RoleDeveloper)
+		if !ok {
+			return
+		}
+		var in struct {
+			Revoked *bool `json:"revoked"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Revoked == nil {
+			httpx.BadRequest(w, "invalid payload (revoked required)")
+			return
+		}
+		var link db.InstallLink
+		if err := a.DB.First(&link, "id = ? AND build_id = ?", mux.Vars(r)["linkID"], b.ID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				httpx.NotFound(w, "install link not found")
+				return
+			}
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		var revokedAt *time.Time
+		if *in.Revoked {
+			now := time.Now()
+			revokedAt = &now
+		}
+		if err := a.DB.Model(&link).Update("revoked_at", revokedAt).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.OK(w, link)
+	})).Methods(http.MethodPatch)
+}
+
+// mountInstallPages monte les pages publiques d'installation, accessibles avec le token du lien.
+func (a *API) mountInstallPages(r *mux.Router) {
+	// une seule extraction des métadonnées par IPA pour les manifestes demandés avant la fin de extractMetadata
+	var extracting singleflight.Group
+
+	// GET /install/{token}
+	r.HandleFunc("/install/{token}", func(w http.ResponseWriter, r *http.Request) {
+		token := mux.Vars(r)["token"]
+		if !a.installLinksEnabled(w) {
+			return
+		}
+		link, b, art, ok := a.resolveInstallLink(w, r)
+		if !ok {
+			return
+		}
+		var p db.Project
+		if err := a.DB.Select("id,name").First(&p, "id = ?", b.ProjectID).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		base := a.installURL(token)
+		data := installPageData{
+			Project:   p.Name,
+			Platform:  b.Platform,
+			Filename:  art.Filename,
+			Size:      art.Size,
+			CreatedAt: b.CreatedAt,
+			ExpiresAt: link.ExpiresAt,
+			QRCodeURL: base + "/qr.png",
+		}
+		if art.Kind == "ipa" {
+			// itms-services exige une URL de manifeste absolue en https
+			data.InstallURL = template.URL("itms-services://?action=download-manifest&url=" + url.QueryEscape(base+"/manifest.plist"))
+		} else {
+			data.InstallURL = template.URL(base + "/download")
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		if err := installPage.Execute(w, data); err != nil {
+			log.Printf("install: render page: %v", err)
+		}
+	}).Methods(http.MethodGet)
+
+	// GET /install/{token}/qr.png
+	r.HandleFunc("/install/{token}/qr.png", func(w http.ResponseWriter, r *http.Request) {
+		if !a.installLinksEnabled(w) {
+			return
+		}
+		if _, _, _, ok := a.resolveInstallLink(w, r); !ok {
+			return
+		}
+		png, err := qrcode.Encode(a.installURL(mux.Vars(r)["token"]), qrcode.Medium, 256)
+		if err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = w.Write(png)
+	}).Methods(http.MethodGet)
+
+	// GET /install/{token}/manifest.plist (iOS)
+	r.HandleFunc("/install/{token}/manifest.plist", func(w http.ResponseWriter, r *http.Request) {
+		if !a.installLinksEnabled(w) {
+			return
+		}
+		_, b, art, ok := a.resolveInstallLink(w, r)
+		if !ok {
+			return
+		}
+		if art.Kind != "ipa" {
+			httpx.NotFound(w, "manifest is only available for iOS builds")
+			return
+		}
+		meta, err := a.buildAppMetadata(r.Context(), &extracting, b, art)
+		if err != nil {
+			httpx.InternalError(w, "cannot read IPA metadata: "+err.Error())
+			return
+		}
+		title := meta.Name
+		if title == "" {
+			title = strings.TrimSuffix(art.Filename, ".ipa")
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = io.WriteString(w, manifestPlist(a.installURL(mux.Vars(r)["token"])+"/download", meta.BundleID, meta.VersionName, title))
+	}).Methods(http.MethodGet)
+
+	// GET /install/{token}/download
+	r.HandleFunc("/install/{token}/download", func(w http.ResponseWriter, r *http.Request) {
+		_, _, art, ok := a.resolveInstallLink(w, r)
+		if !ok {
+			return
+		}
+		a.serveArtifact(w, r, art)
+	}).Methods(http.MethodGet)
+}
+
+// resolveInstallLink valide le token (existence, expiration, révocation) et charge le build et son paquet installable.
+func (a *API) resolveInstallLink(w http.ResponseWriter, r *http.Request) (db.InstallLink, db.Build, db.BuildArtifact, bool) {
+	var (
+		link db.InstallLink
+		b    db.Build
+		art  db.BuildArtifact
+	)
+	if err := a.DB.First(&link, "token_hash = ?", hashToken(mux.Vars(r)["token"])).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httpx.NotFound(w, "install link not found")
+			return link, b, art, false
+		}
+		httpx.InternalError(w, err.Error())
+		return link, b, art, false
+	}
+	if link.RevokedAt != nil || time.Now().After(link.ExpiresAt) {
+		httpx.Gone(w, "install link expired or revoked")
+		return link, b, art, false
+	}
+	if err := a.DB.First(&b, "id = ?", link.BuildID).Error; err != nil {
+		httpx.NotFound(w, "build not found")
+		return link, b, art, false
+	}
+	art, err := a.installableArtifact(r.Context(), b)
+	if err != nil {
+		httpx.NotFound(w, "build has no installable artifact")
+		return link, b, art, false
+	}
+	return link, b, art, true
+}
+
+// installableArtifact choisit le paquet installable d'un build selon sa plateforme.
+func (a *API) installableArtifact(ctx context.Context, b db.Build) (db.BuildArtifact, error) {
+	var kinds []string
+	switch b.Platform {
+	case "IOS":
+		kinds = []string{"ipa"}
+	case "ANDROID":
+		kinds = []string{"apk"}
+	default:
+		kinds = []string{"dmg", "msi", "appimage"}
+	}
+	var art db.BuildArtifact
+	err := a.DB.WithContext(ctx).Where("build_id = ? AND status = ? AND kind IN ?", b.ID, "ready", kinds).
+		Order("created_at DESC").First(&art).Error
+	return art, err
+}
+
+// artifactTempFile copie un artefact dans un fichier temporaire (l'appelant le ferme et le supprime).
+func (a *API) artifactTempFile(ctx context.Context, art db.BuildArtifact) (*os.File, int64, error) {
+	rc, _, err := a.Storage.Open(ctx, art.StorageKey)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rc.Close()
+	f, err := os.CreateTemp("", "artifact-*")
+	if err != nil {
+		return nil, 0, err
+	}
+	n, err := io.Copy(f, rc)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, 0, err
+	}
+	return f, n, nil
+}
+
+// installLinksEnabled répond 503 si PublicBaseURL n'est pas configurée : les URLs des liens
+// d'installation ne sont jamais déduites de l'en-tête Host, choisi par le client.
+func (a *API) installLinksEnabled(w http.ResponseWriter) bool {
+	if a.PublicBaseURL == "" {
+		httpx.ServiceUnavailable(w, "install links require PUBLIC_BASE_URL")
+		return false
+	}
+	return true
+}
+
+// installURL retourne l'URL publique de la page d'installation d'un lien.
+func (a *API) installURL(token string) string {
+	return a.PublicBaseURL + "/install/" + token
+}
+
+// hashToken retourne l'empreinte SHA-256 stockée à la place d'un token secret.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// manifestPlist génère le manifeste itms-services d'une IPA.
+func manifestPlist(ipaURL, bundleID, version, title string) string {
+	esc := func(s string) string {
+		var sb strings.Builder
+		_ = xml.EscapeText(&sb, []byte(s))
+		return sb.String()
+	}
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+  <key>items</key>
+  <array>
+    <dict>
+      <key>assets</key>
+      <array>
+        <dict>
+          <key>kind</key><string>software-package</string>
+          <key>url</key><string>` + esc(ipaURL) + `</string>
+        </dict>
+      </array>
+      <key>metadata</key>
+      <dict>
+        <key>bundle-identifier</key><string>` + esc(bundleID) + `</string>
+        <key>bundle-version</key><string>` + esc(version) + `</string>
+        <key>kind</key><string>software</string>
+        <key>title</key><string>` + esc(title) + `</string>
+      </dict>
+    </dict>
+  </array>
+</dict>
+</plist>
+`
+}
+
+type installPageData struct {
+	Project    string
+	Platform   string
+	Filename   string
+	Size       int64
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	InstallURL template.URL
+	QRCodeURL  string
+}
+
+var installPage = template.Must(template.New("install").Funcs(template.FuncMap{
+	"mib": func(n int64) string { return strconv.FormatFloat(float64(n)/(1<<20), 'f', 1, 64) + " MiB" },
+}).Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Install {{.Project}}</title>
+<style>
+body{font-family:system-ui,sans-serif;max-width:28rem;margin:2rem auto;padding:0 1rem;text-align:center;color:#222}
+a.button{display:inline-block;margin:1.5rem 0;padding:.9rem 1.6rem;border-radius:.5rem;background:#2563eb;color:#fff;text-decoration:none;font-weight:600}
+small{color:#666}
+</style>
+</head>
+<body>
+<h1>{{.Project}}</h1>
+<p>{{.Platform}} build &middot; {{.Filename}} ({{mib .Size}})<br><small>Built {{.CreatedAt.Format "2006-01-02 15:04 MST"}}</small></p>
+<a class="button" href="{{.InstallURL}}">Install</a>
+<p><img src="{{.QRCodeURL}}" width="200" height="200" alt="QR code of this page"></p>
+<p><small>Open this page on your device or scan the QR code. Link expires {{.ExpiresAt.Format "2006-01-02 15:04 MST"}}.</small></p>
+</body>
+</html>
+`))
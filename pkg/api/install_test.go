@@ -0,0 +1,82 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flotio-dev/project-service/pkg/db"
+	"github.com/flotio-dev/project-service/pkg/db/dbtest"
+	"github.com/flotio-dev/project-service/pkg/storage"
+	"golang.org/x/sync/singleflight"
+)
+
+func TestInstallLinksRequirePublicBaseURL(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if (&API{}).installLinksEnabled(rec) || rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("without PUBLIC_BASE_URL: status %d, want 503", rec.Code)
+	}
+	a := &API{PublicBaseURL: "https://ci.example"}
+	if !a.installLinksEnabled(httptest.NewRecorder()) {
+		t.Error("install links disabled with PUBLIC_BASE_URL")
+	}
+	if got, want := a.installURL("tok"), "https://ci.example/install/tok"; got != want {
+		t.Errorf("installURL = %q, want %q", got, want)
+	}
+}
+
+func TestBuildAppMetadata(t *testing.T) {
+	var ipa bytes.Buffer
+	zw := zip.NewWriter(&ipa)
+	f, _ := zw.Create("Payload/App.app/Info.plist")
+	f.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0"><dict>
+<key>CFBundleIdentifier</key><string>com.acme.app</string>
+<key>CFBundleShortVersionString</key><string>1.2.0</string>
+</dict></plist>`))
+	zw.Close()
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(context.Background(), "app.ipa", bytes.NewReader(ipa.Bytes()), int64(ipa.Len()), ""); err != nil {
+		t.Fatal(err)
+	}
+	art := db.BuildArtifact{ID: "a1", BuildID: "b1", Kind: "ipa", StorageKey: "app.ipa", Filename: "app.ipa"}
+	var extracting singleflight.Group
+
+	t.Run("extracted and stored", func(t *testing.T) {
+		gdb, fake := dbtest.Open(t)
+		// extractMetadata lit le build sans métadonnées, buildAppMetadata le relit après l'UPDATE
+		fake.Return(`FROM "builds"`, dbtest.Rows([]string{"id"}, []any{"b1"}))
+		fake.Handle(`UPDATE "builds"`, func(dbtest.Query) dbtest.Result {
+			fake.Return(`FROM "builds"`, dbtest.Rows([]string{"id", "app_id", "version_name"}, []any{"b1", "com.acme.app", "1.2.0"}))
+			return dbtest.Result{RowsAffected: 1}
+		})
+		a := &API{DB: gdb, Storage: store}
+		meta, err := a.buildAppMetadata(context.Background(), &extracting, db.Build{ID: "b1"}, art)
+		if err != nil || meta.BundleID != "com.acme.app" || meta.VersionName != "1.2.0" {
+			t.Fatalf("buildAppMetadata() = %+v, %v", meta, err)
+		}
+		updates := fake.Queries(`UPDATE "builds" SET .*"app_id"`)
+		if len(updates) != 1 || updates[0].Args[0] != "com.acme.app" {
+			t.Errorf("metadata not stored on the build: %v", fake.Queries(`UPDATE`))
+		}
+	})
+
+	t.Run("stored metadata", func(t *testing.T) {
+		gdb, fake := dbtest.Open(t)
+		id, version := "com.acme.app", "1.2.0"
+		a := &API{DB: gdb, Storage: store}
+		meta, err := a.buildAppMetadata(context.Background(), &extracting, db.Build{ID: "b1", AppID: &id, VersionName: &version}, art)
+		if err != nil || meta.BundleID != id || meta.VersionName != version {
+			t.Fatalf("buildAppMetadata() = %+v, %v", meta, err)
+		}
+		if qs := fake.Queries(`.`); len(qs) != 0 {
+			t.Errorf("stored metadata ran %d queries", len(qs))
+		}
+	})
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/flotio-dev/project-service/pkg/auth"
+	"github.com/flotio-dev/project-service/pkg/httpx"
+	"github.com/flotio-dev/project-service/pkg/middleware"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// activeIdentity vérifie par introspection que le JWT de l'appelant n'a pas été révoqué
+// avant une opération sensible (révélation de secrets, suppression, transfert de projet).
+// Si l'issuer renvoie les groupes, l'identité retournée en est reconstruite : les rôles
+// sont revérifiés sur l'appartenance actuelle et non sur celle figée dans le JWT.
+// Les tokens d'API sont vérifiés en base à chaque requête et ne sont pas introspectés.
+// En cas d'échec, la réponse est écrite et false retourné.
+func (a *API) activeIdentity(w http.ResponseWriter, r *http.Request, id auth.Identity) (auth.Identity, bool) {
+	if a.Introspector == nil {
+		return id, true
+	}
+	token, _ := middleware.GetValue[string](r, "token")
+	if auth.IsAPIToken(token) {
+		return id, true
+	}
+	res, err := a.Introspector.Introspect(r.Context(), token)
+	if err != nil {
+		// état inconnu : l'opération est refusée plutôt qu'accordée sur un token peut-être révoqué
+		log.Printf("token introspection failed: %v", err)
+		httpx.ServiceUnavailable(w, "cannot verify token status")
+		return id, false
+	}
+	sub, _ := middleware.GetValue[string](r, "sub")
+	if !res.Active || (res.Sub != "" && res.Sub != sub) {
+		httpx.TokenRejected(w, "token_revoked", "token is no longer active")
+		return id, false
+	}
+	if res.Groups != nil {
+		fresh := a.GroupMapping.Identity(jwt.MapClaims{"groups": res.Groups})
+		id.Groups = fresh.Groups
+	}
+	return id, true
+}
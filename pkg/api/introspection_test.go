@@ -0,0 +1,68 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flotio-dev/project-service/pkg/auth"
+	"github.com/flotio-dev/project-service/pkg/middleware"
+)
+
+func TestActiveIdentity(t *testing.T) {
+	group := "/acme"
+	mapping := auth.GroupMapping{DefaultRole: auth.RoleDeveloper, SubRoles: map[string]auth.Role{"admins": auth.RoleAdmin}}
+	// rôle tiré du JWT : admin du groupe
+	jwtID := mapping.Identity(map[string]any{"sub": "alice", "groups": []any{"/acme/admins"}})
+	tests := []struct {
+		name       string
+		token      string
+		status     int
+		body       string
+		wantOK     bool
+		wantStatus int
+		wantRole   auth.Role
+	}{
+		{"groups unchanged", "jwt", http.StatusOK, `{"active":true,"sub":"alice","groups":["/acme/admins"]}`, true, 0, auth.RoleAdmin},
+		{"demoted in group", "jwt", http.StatusOK, `{"active":true,"sub":"alice","groups":["/acme"]}`, true, 0, auth.RoleDeveloper},
+		{"removed from all groups", "jwt", http.StatusOK, `{"active":true,"sub":"alice","groups":[]}`, true, 0, auth.RoleNone},
+		{"groups not returned keep jwt roles", "jwt", http.StatusOK, `{"active":true,"sub":"alice"}`, true, 0, auth.RoleAdmin},
+		{"revoked", "jwt", http.StatusOK, `{"active":false}`, false, http.StatusUnauthorized, 0},
+		{"other subject", "jwt", http.StatusOK, `{"active":true,"sub":"bob"}`, false, http.StatusUnauthorized, 0},
+		{"issuer down", "jwt", http.StatusBadGateway, ``, false, http.StatusServiceUnavailable, 0},
+		{"api tokens are not introspected", auth.APITokenPrefix + "abc", http.StatusOK, `{"active":false}`, true, 0, auth.RoleAdmin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+			a := &API{GroupMapping: mapping, Introspector: auth.NewIntrospector(srv.URL, "", "", time.Minute)}
+
+			r := httptest.NewRequest(http.MethodDelete, "/api/projects/p1", nil)
+			r = middleware.WithValue(r, "token", tt.token)
+			r = middleware.WithValue(r, "sub", "alice")
+			w := httptest.NewRecorder()
+			id, ok := a.activeIdentity(w, r, jwtID)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v (status %d)", ok, tt.wantOK, w.Code)
+			}
+			if !ok {
+				if w.Code != tt.wantStatus {
+					t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+				}
+				return
+			}
+			if id.Sub != "alice" {
+				t.Errorf("sub = %q", id.Sub)
+			}
+			if got := id.ProjectRole("someone-else", &group); got != tt.wantRole {
+				t.Errorf("project role = %s, want %s", got, tt.wantRole)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/flotio-dev/project-service/pkg/buildconfig"
+)
+
+// normalizePlatforms valide une liste "android, ios" et la retourne sous forme canonique "ANDROID,IOS".
+func normalizePlatforms(s string) (string, error) {
+	var out []string
+	seen := map[string]bool{}
+	for _, it := range strings.Split(s, ",") {
+		pl := strings.ToUpper(strings.TrimSpace(it))
+		if pl == "" || seen[pl] {
+			continue
+		}
+		if !isKnownPlatform(pl) {
+			return "", fmt.Errorf("unknown platform %q", pl)
+		}
+		seen[pl] = true
+		out = append(out, pl)
+	}
+	return strings.Join(out, ","), nil
+}
+
+// splitPlatforms découpe une liste canonique "ANDROID,IOS".
+func splitPlatforms(s *string) []string {
+	if s == nil || *s == "" {
+		return nil
+	}
+	return strings.Split(*s, ",")
+}
+
+func isKnownPlatform(pl string) bool {
+	return slices.Contains(buildconfig.Platforms, pl)
+}
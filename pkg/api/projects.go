@@ -5,11 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/flotio-dev/project-service/pkg/auth"
 	"github.com/flotio-dev/project-service/pkg/db"
 	"github.com/flotio-dev/project-service/pkg/httpx"
 	"github.com/flotio-dev/project-service/pkg/middleware"
+	"github.com/flotio-dev/project-service/pkg/pathfilter"
 	"github.com/gorilla/mux"
 	"gorm.io/gorm"
 )
@@ -21,83 +24,60 @@ func (a *API) mountProjects(api *mux.Router) {
 		FullName string `json:"full_name"`
 		HTMLURL  string `json:"html_url"`
 	}
-	getUserAndGroups := func(r *http.Request) (string, []string) {
-		sub, _ := middleware.GetValue[string](r, "sub")
-		var groups []string
-		if claims, ok := middleware.GetValue[map[string]any](r, "claims"); ok {
-			if raw, ok := claims["groups"]; ok {
-				switch v := raw.(type) {
-				case []any:
-					for _, it := range v {
-						if s, _ := it.(string); s != "" {
-							groups = append(groups, s)
-						}
-					}
-				case []string:
-					groups = append(groups, v...)
-				}
-			}
-		}
-		return sub, groups
-	}
-	hasAccess := func(p db.Project, sub string, groups []string) bool {
-		if p.UserID == sub {
-			return true
-		}
-		if p.GroupID != nil {
-			gid := *p.GroupID
-			for _, g := range groups {
-				if g == gid {
-					return true
-				}
-			}
-		}
-		return false
-	}
-
 	// Create project
-	api.HandleFunc("/projects", func(w http.ResponseWriter, r *http.Request) {
-		sub, _ := middleware.GetValue[string](r, "sub")
+	api.HandleFunc("/projects", middleware.RequireScope(auth.ScopeProjectsWrite, func(w http.ResponseWriter, r *http.Request) {
+		id := a.identity(r)
 		var in struct {
-			Name        string  `json:"name"`
-			GroupID     *string `json:"group_id"`
-			GithubToken *string `json:"github_token"`
+			Name             string  `json:"name"`
+			GroupID          *string `json:"group_id"`
+			GithubToken      *string `json:"github_token"`
+			PreviewPlatforms *string `json:"preview_platforms"`
+			RootDir          *string `json:"root_dir"`
+			PathFilters      *string `json:"path_filters"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Name == "" {
 			httpx.BadRequest(w, "invalid payload (name required)")
 			return
 		}
-		p := db.Project{UserID: sub, GroupID: in.GroupID, Name: in.Name, GithubToken: in.GithubToken}
-		if err := a.DB.Create(&p).Error; err != nil {
+		if err := normalizeMonorepo(&in.RootDir, &in.PathFilters); err != nil {
+			httpx.BadRequest(w, err.Error())
+			return
+		}
+		if !checkTargetGroup(w, id, &in.GroupID) {
+			return
+		}
+		if in.PreviewPlatforms != nil {
+			v, err := normalizePlatforms(*in.PreviewPlatforms)
+			if err != nil {
+				httpx.BadRequest(w, err.Error())
+				return
+			}
+			in.PreviewPlatforms = &v
+		}
+		p := db.Project{UserID: id.Sub, GroupID: in.GroupID, Name: in.Name, GithubToken: in.GithubToken, PreviewPlatforms: in.PreviewPlatforms, RootDir: in.RootDir, PathFilters: in.PathFilters}
+		if err := createProjects(a.DB, &p); err != nil {
 			httpx.InternalError(w, err.Error())
 			return
 		}
 		httpx.Created(w, p)
-	}).Methods(http.MethodPost)
+	})).Methods(http.MethodPost)
 
 	// List projects
-	api.HandleFunc("/projects", func(w http.ResponseWriter, r *http.Request) {
-		sub, groups := getUserAndGroups(r)
+	api.HandleFunc("/projects", middleware.RequireScope(auth.ScopeProjectsRead, func(w http.ResponseWriter, r *http.Request) {
 		var ps []db.Project
-		q := a.DB.Model(&db.Project{})
-		if len(groups) > 0 {
-			q = q.Where("user_id = ? OR group_id IN ?", sub, groups)
-		} else {
-			q = q.Where("user_id = ?", sub)
-		}
+		q := visibleProjects(a.DB.Model(&db.Project{}), a.identity(r), "")
 		if err := q.Order("created_at DESC").Find(&ps).Error; err != nil {
 			httpx.InternalError(w, err.Error())
 			return
 		}
 		httpx.OK(w, ps)
-	}).Methods(http.MethodGet)
+	})).Methods(http.MethodGet)
 
 	// Get one project
-	api.HandleFunc("/projects/{projectID}", func(w http.ResponseWriter, r *http.Request) {
-		sub, groups := getUserAndGroups(r)
-		id := mux.Vars(r)["projectID"]
+	api.HandleFunc("/projects/{projectID}", middleware.RequireScope(auth.ScopeProjectsRead, func(w http.ResponseWriter, r *http.Request) {
+		id := a.identity(r)
 		var p db.Project
-		if err := a.DB.First(&p, "id = ?", id).Error; err != nil {
+		if err := a.DB.First(&p, "id = ?", mux.Vars(r)["projectID"]).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				httpx.NotFound(w, "project not found")
 				return
@@ -105,19 +85,17 @@ func (a *API) mountProjects(api *mux.Router) {
 			httpx.InternalError(w, err.Error())
 			return
 		}
-		if !hasAccess(p, sub, groups) {
-			httpx.Forbidden(w, "forbidden")
+		if !requireProjectRole(w, id, p, auth.RoleViewer) {
 			return
 		}
 		httpx.OK(w, p)
-	}).Methods(http.MethodGet)
+	})).Methods(http.MethodGet)
 
 	// Update project
-	api.HandleFunc("/projects/{projectID}", func(w http.ResponseWriter, r *http.Request) {
-		sub, groups := getUserAndGroups(r)
-		id := mux.Vars(r)["projectID"]
+	api.HandleFunc("/projects/{projectID}", middleware.RequireScope(auth.ScopeProjectsWrite, func(w http.ResponseWriter, r *http.Request) {
+		id := a.identity(r)
 		var p db.Project
-		if err := a.DB.First(&p, "id = ?", id).Error; err != nil {
+		if err := a.DB.First(&p, "id = ?", mux.Vars(r)["projectID"]).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				httpx.NotFound(w, "project not found")
 				return
@@ -125,46 +103,85 @@ func (a *API) mountProjects(api *mux.Router) {
 			httpx.InternalError(w, err.Error())
 			return
 		}
-		if !hasAccess(p, sub, groups) {
-			httpx.Forbidden(w, "forbidden")
+		if !requireProjectRole(w, id, p, auth.RoleDeveloper) {
 			return
 		}
 		var in struct {
-			Name        *string `json:"name"`
-			GroupID     *string `json:"group_id"`
-			GithubToken *string `json:"github_token"`
+			Name             *string `json:"name"`
+			GroupID          *string `json:"group_id"`
+			GithubToken      *string `json:"github_token"`
+			PreviewPlatforms *string `json:"preview_platforms"`
+			RootDir          *string `json:"root_dir"`
+			PathFilters      *string `json:"path_filters"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
 			httpx.BadRequest(w, "invalid payload")
 			return
 		}
+		if err := normalizeMonorepo(&in.RootDir, &in.PathFilters); err != nil {
+			httpx.BadRequest(w, err.Error())
+			return
+		}
 		updates := map[string]any{}
 		if in.Name != nil {
 			updates["name"] = *in.Name
 		}
 		if in.GroupID != nil {
+			if !checkTargetGroup(w, id, &in.GroupID) {
+				return
+			}
+			// changer de groupe transfère le projet : réservé aux admins, opération sensible
+			if in.GroupID == nil && p.GroupID != nil || in.GroupID != nil && (p.GroupID == nil || *p.GroupID != *in.GroupID) {
+				if !requireProjectRole(w, id, p, auth.RoleAdmin) {
+					return
+				}
+				// revérifié sur les groupes actuels de l'appelant
+				fresh, ok := a.activeIdentity(w, r, id)
+				if !ok || !requireProjectRole(w, fresh, p, auth.RoleAdmin) || !checkTargetGroup(w, fresh, &in.GroupID) {
+					return
+				}
+			}
 			updates["group_id"] = in.GroupID
 		}
 		if in.GithubToken != nil {
 			updates["github_token"] = in.GithubToken
 		}
+		if in.PreviewPlatforms != nil {
+			v, err := normalizePlatforms(*in.PreviewPlatforms)
+			if err != nil {
+				httpx.BadRequest(w, err.Error())
+				return
+			}
+			updates["preview_platforms"] = v
+		}
+		if in.RootDir != nil {
+			updates["root_dir"] = in.RootDir
+		}
+		if in.PathFilters != nil {
+			updates["path_filters"] = in.PathFilters
+		}
 		if len(updates) == 0 {
 			httpx.OK(w, p)
 			return
 		}
-		if err := a.DB.Model(&p).Updates(updates).Error; err != nil {
+		err := a.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&p).Updates(updates).Error; err != nil {
+				return err
+			}
+			return emitProjectEvent(tx, eventProjectUpdated, p)
+		})
+		if err != nil {
 			httpx.InternalError(w, err.Error())
 			return
 		}
 		httpx.OK(w, p)
-	}).Methods(http.MethodPatch, http.MethodPut)
+	})).Methods(http.MethodPatch, http.MethodPut)
 
 	// Delete project
-	api.HandleFunc("/projects/{projectID}", func(w http.ResponseWriter, r *http.Request) {
-		sub, groups := getUserAndGroups(r)
-		id := mux.Vars(r)["projectID"]
+	api.HandleFunc("/projects/{projectID}", middleware.RequireScope(auth.ScopeProjectsWrite, func(w http.ResponseWriter, r *http.Request) {
+		id := a.identity(r)
 		var p db.Project
-		if err := a.DB.First(&p, "id = ?", id).Error; err != nil {
+		if err := a.DB.First(&p, "id = ?", mux.Vars(r)["projectID"]).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				httpx.NotFound(w, "project not found")
 				return
@@ -172,30 +189,58 @@ func (a *API) mountProjects(api *mux.Router) {
 			httpx.InternalError(w, err.Error())
 			return
 		}
-		if !hasAccess(p, sub, groups) {
-			httpx.Forbidden(w, "forbidden")
+		if !requireProjectRole(w, id, p, auth.RoleAdmin) {
+			return
+		}
+		if id, ok := a.activeIdentity(w, r, id); !ok || !requireProjectRole(w, id, p, auth.RoleAdmin) {
 			return
 		}
-		if err := a.DB.Delete(&p).Error; err != nil {
+		err := a.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Delete(&p).Error; err != nil {
+				return err
+			}
+			return emitProjectEvent(tx, eventProjectDeleted, p)
+		})
+		if err != nil {
 			httpx.InternalError(w, err.Error())
 			return
 		}
 		httpx.NoContent(w)
-	}).Methods(http.MethodDelete)
+	})).Methods(http.MethodDelete)
 
 	// Import GitHub
-	api.HandleFunc("/projects/import/github", func(w http.ResponseWriter, r *http.Request) {
-		sub, _ := middleware.GetValue[string](r, "sub")
+	api.HandleFunc("/projects/import/github", middleware.RequireScope(auth.ScopeProjectsWrite, func(w http.ResponseWriter, r *http.Request) {
+		id := a.identity(r)
+		type subproject struct {
+			Name        string  `json:"name"`
+			RootDir     *string `json:"root_dir"`
+			PathFilters *string `json:"path_filters"`
+		}
 		var in struct {
 			Token    string  `json:"token"`
 			FullName *string `json:"full_name"`
 			Query    *string `json:"query"`
 			GroupID  *string `json:"group_id"`
+			// Monorepo: un projet par entrée (sinon un seul projet à la racine)
+			Projects []subproject `json:"projects"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Token == "" {
 			httpx.BadRequest(w, "invalid payload (token required)")
 			return
 		}
+		if !checkTargetGroup(w, id, &in.GroupID) {
+			return
+		}
+		for i := range in.Projects {
+			if in.Projects[i].Name == "" {
+				httpx.BadRequest(w, "projects[].name required")
+				return
+			}
+			if err := normalizeMonorepo(&in.Projects[i].RootDir, &in.Projects[i].PathFilters); err != nil {
+				httpx.BadRequest(w, err.Error())
+				return
+			}
+		}
 		var repo githubRepo
 		client := &http.Client{Timeout: 10 * time.Second}
 		if in.FullName != nil && *in.FullName != "" {
@@ -239,18 +284,56 @@ func (a *API) mountProjects(api *mux.Router) {
 			httpx.BadRequest(w, "provide full_name or query")
 			return
 		}
-		name := repo.Name
-		p := db.Project{UserID: sub, GroupID: in.GroupID, Name: name, GithubToken: &in.Token}
-		if repo.FullName != "" {
-			p.GithubRepo = &repo.FullName
+		newProject := func(name string) db.Project {
+			p := db.Project{UserID: id.Sub, GroupID: in.GroupID, Name: name, GithubToken: &in.Token}
+			if repo.FullName != "" {
+				p.GithubRepo = &repo.FullName
+			}
+			if repo.HTMLURL != "" {
+				p.GithubURL = &repo.HTMLURL
+			}
+			return p
+		}
+		if len(in.Projects) == 0 {
+			p := newProject(repo.Name)
+			if err := createProjects(a.DB, &p); err != nil {
+				httpx.InternalError(w, err.Error())
+				return
+			}
+			httpx.Created(w, p)
+			return
 		}
-		if repo.HTMLURL != "" {
-			p.GithubURL = &repo.HTMLURL
+		ps := make([]db.Project, 0, len(in.Projects))
+		for _, sp := range in.Projects {
+			p := newProject(sp.Name)
+			p.RootDir, p.PathFilters = sp.RootDir, sp.PathFilters
+			ps = append(ps, p)
 		}
-		if err := a.DB.Create(&p).Error; err != nil {
+		ptrs := make([]*db.Project, len(ps))
+		for i := range ps {
+			ptrs[i] = &ps[i]
+		}
+		if err := createProjects(a.DB, ptrs...); err != nil {
 			httpx.InternalError(w, err.Error())
 			return
 		}
-		httpx.Created(w, p)
-	}).Methods(http.MethodPost)
+		httpx.Created(w, ps)
+	})).Methods(http.MethodPost)
+}
+
+// normalizeMonorepo nettoie le dossier racine et valide les globs d'un projet de monorepo.
+func normalizeMonorepo(rootDir, pathFilters **string) error {
+	if *rootDir != nil {
+		v := pathfilter.CleanRoot(**rootDir)
+		*rootDir = &v
+	}
+	if *pathFilters != nil {
+		patterns := pathfilter.Split(**pathFilters)
+		if err := pathfilter.Validate(patterns); err != nil {
+			return fmt.Errorf("invalid path_filters: %v", err)
+		}
+		v := strings.Join(patterns, ",")
+		*pathFilters = &v
+	}
+	return nil
 }
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/flotio-dev/project-service/pkg/httpx"
+	"github.com/flotio-dev/project-service/pkg/middleware"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/mux"
+)
+
+// Classes de coût des routes, en jetons par requête.
+const (
+	costRead        = 1
+	costWrite       = 2
+	costGitHub      = 10 // la requête appelle api.github.com pour le compte de l'appelant
+	costAuthFailure = 10 // token ou signature refusé, débité du seau auth:<ip>
+)
+
+// githubRoutes sont les routes qui relaient des appels à l'API GitHub.
+var githubRoutes = map[string]bool{
+	"/api/projects/import/github":      true,
+	"/api/projects/import/github/bulk": true,
+	"/api/github/repos":                true,
+}
+
+// routeCost retourne le coût de la route appelée.
+func routeCost(r *http.Request) int {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil && githubRoutes[tpl] {
+			return costGitHub
+		}
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return costRead
+	}
+	return costWrite
+}
+
+// rateLimit limite les routes authentifiées, par worker, token d'API ou utilisateur.
+func (a *API) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.allow(w, r, a.rateLimitKey(r), routeCost(r)) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// rateLimitPublic limite les routes publiques par adresse IP. Les routes /api sont
+// limitées après authentification (rateLimit) et le webhook GitHub, authentifié par
+// signature, n'est pas limité : GitHub ne renvoie pas une livraison refusée. Leurs échecs
+// d'authentification sont limités par rateLimitAuth.
+func (a *API) rateLimitPublic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/api/"), r.URL.Path == "/webhooks/github":
+			a.rateLimitAuth(next).ServeHTTP(w, r)
+		case r.URL.Path == "/healthz" || a.allow(w, r, "ip:"+a.clientIP(r), costRead):
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// rateLimitAuth limite par adresse IP les tokens ou signatures invalides : chaque réponse 401
+// débite costAuthFailure jetons du seau auth:<ip>, et l'adresse est refusée tant qu'il est vide.
+// Les requêtes authentifiées ne le consomment pas.
+func (a *API) rateLimitAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := "auth:" + a.clientIP(r)
+		if !a.allow(w, r, key, 0) {
+			return
+		}
+		rec := middleware.NewStatusRecorder(w)
+		next.ServeHTTP(rec, r)
+		if rec.Status == http.StatusUnauthorized && a.RateLimiter != nil {
+			if _, err := a.RateLimiter.Allow(r.Context(), key, costAuthFailure, a.RateLimit); err != nil {
+				log.Printf("rate limit: %v", err)
+			}
+		}
+	})
+}
+
+// rateLimitKey identifie le seau de l'appelant.
+func (a *API) rateLimitKey(r *http.Request) string {
+	if id, ok := middleware.WorkerID(r); ok {
+		return "worker:" + id
+	}
+	claims, _ := middleware.GetValue[jwt.MapClaims](r, "claims")
+	if middleware.FromAPIToken(r) && claims["token_type"] == "api_token" {
+		if id, _ := claims["token_id"].(string); id != "" {
+			return "token:" + id
+		}
+	}
+	if sub, _ := middleware.GetValue[string](r, "sub"); sub != "" {
+		return "user:" + sub
+	}
+	return "ip:" + a.clientIP(r)
+}
+
+// allow débite le seau et écrit les en-têtes RateLimit-* ; répond 429 avec Retry-After
+// si le seau est vide. Une erreur du backend laisse passer la requête.
+func (a *API) allow(w http.ResponseWriter, r *http.Request, key string, cost int) bool {
+	if a.RateLimiter == nil {
+		return true
+	}
+	res, err := a.RateLimiter.Allow(r.Context(), key, cost, a.RateLimit)
+	if err != nil {
+		log.Printf("rate limit: %v", err)
+		return true
+	}
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(int(res.Reset.Seconds())))
+	if a.RateLimit.PerSecond > 0 {
+		h.Set("RateLimit-Policy", strconv.Itoa(a.RateLimit.Burst)+";w="+strconv.Itoa(int(float64(a.RateLimit.Burst)/a.RateLimit.PerSecond)))
+	}
+	if !res.Allowed {
+		h.Set("Retry-After", strconv.Itoa(max(int(res.RetryAfter.Seconds()), 1)))
+		httpx.TooManyRequests(w, "rate limit exceeded")
+		return false
+	}
+	return true
+}
+
+// clientIP retourne l'adresse de l'appelant ; derrière un reverse proxy de confiance
+// (RateLimitTrustProxy), la dernière entrée de X-Forwarded-For, ajoutée par ce proxy.
+func (a *API) clientIP(r *http.Request) string {
+	if a.RateLimitTrustProxy {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			parts := strings.Split(xff, ",")
+			if ip := strings.TrimSpace(parts[len(parts)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flotio-dev/project-service/pkg/ratelimit"
+)
+
+func TestRateLimitPublic(t *testing.T) {
+	// 40 jetons sans recharge : 4 échecs d'authentification ou 40 requêtes publiques
+	rate := ratelimit.Rate{Burst: 4 * costAuthFailure}
+	status := func(r *http.Request) int {
+		if r.Header.Get("Authorization") == "Bearer good" {
+			return http.StatusOK
+		}
+		return http.StatusUnauthorized
+	}
+	type req struct {
+		path, auth, ip string
+		n              int // nombre de requêtes identiques
+		want           int // status de la dernière
+	}
+	tests := []struct {
+		name string
+		reqs []req
+	}{
+		{"failed auth is limited per ip", []req{
+			{"/api/projects", "Bearer bad", "10.0.0.1", 4, http.StatusUnauthorized},
+			{"/api/projects", "Bearer bad", "10.0.0.1", 1, http.StatusTooManyRequests},
+			{"/api/projects", "Bearer good", "10.0.0.1", 1, http.StatusTooManyRequests},
+			{"/api/projects", "Bearer bad", "10.0.0.2", 1, http.StatusUnauthorized},
+		}},
+		{"authenticated requests do not consume the auth bucket", []req{
+			{"/api/projects", "Bearer good", "10.0.0.1", 100, http.StatusOK},
+			{"/api/worker/heartbeat", "Bearer bad", "10.0.0.1", 4, http.StatusUnauthorized},
+			{"/api/worker/heartbeat", "Bearer bad", "10.0.0.1", 1, http.StatusTooManyRequests},
+		}},
+		{"github webhook is not limited per ip", []req{
+			{"/webhooks/github", "Bearer good", "10.0.0.1", 100, http.StatusOK},
+		}},
+		{"github webhook bad signatures are limited", []req{
+			{"/webhooks/github", "", "10.0.0.1", 4, http.StatusUnauthorized},
+			{"/webhooks/github", "Bearer good", "10.0.0.1", 1, http.StatusTooManyRequests},
+		}},
+		{"public routes are limited per ip", []req{
+			{"/install/abc", "Bearer good", "10.0.0.1", 40, http.StatusOK},
+			{"/install/abc", "Bearer good", "10.0.0.1", 1, http.StatusTooManyRequests},
+			{"/healthz", "Bearer good", "10.0.0.1", 1, http.StatusOK},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &API{RateLimiter: ratelimit.NewMemory(), RateLimit: rate}
+			h := a.rateLimitPublic(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(status(r))
+			}))
+			for i, rq := range tt.reqs {
+				var code int
+				for range rq.n {
+					r := httptest.NewRequest(http.MethodGet, rq.path, nil)
+					r.RemoteAddr = rq.ip + ":1234"
+					if rq.auth != "" {
+						r.Header.Set("Authorization", rq.auth)
+					}
+					w := httptest.NewRecorder()
+					h.ServeHTTP(w, r)
+					code = w.Code
+				}
+				if code != rq.want {
+					t.Fatalf("request %d (%s from %s): status %d, want %d", i, rq.path, rq.ip, code, rq.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package api
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flotio-dev/project-service/pkg/auth"
+	"github.com/flotio-dev/project-service/pkg/db"
+	"github.com/flotio-dev/project-service/pkg/github"
+	"github.com/flotio-dev/project-service/pkg/httpx"
+	"github.com/flotio-dev/project-service/pkg/middleware"
+	"github.com/gorilla/mux"
+)
+
+// repoPickerTTL est la durée de cache des pages de dépôts GitHub.
+const repoPickerTTL = time.Minute
+
+// repoChoice est un dépôt proposé dans le sélecteur, annoté avec le projet déjà lié éventuel.
+type repoChoice struct {
+	github.Repo
+	ProjectID *string `json:"project_id,omitempty"`
+}
+
+type repoPage struct {
+	Items      []repoChoice `json:"items"`
+	Page       int          `json:"page"`
+	PerPage    int          `json:"per_page"`
+	HasNext    bool         `json:"has_next"`
+	TotalCount *int         `json:"total_count,omitempty"` // seulement pour source=search
+	Cached     bool         `json:"cached"`
+}
+
+// repoCacheSize est le nombre maximal de pages gardées en cache.
+const repoCacheSize = 1000
+
+// repoCache est un cache mémoire des réponses GitHub, indexé par token haché et paramètres.
+// Au-delà de size pages (repoCacheSize par défaut), les plus anciennes sont évincées.
+type repoCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element // valeur *repoCacheEntry
+	order   list.List                // de la plus ancienne à la plus récente
+}
+
+type repoCacheEntry struct {
+	key       string
+	page      repoPage
+	expiresAt time.Time
+}
+
+func (c *repoCache) get(key string) (repoPage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return repoPage{}, false
+	}
+	e := el.Value.(*repoCacheEntry)
+	if time.Now().After(e.expiresAt) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return repoPage{}, false
+	}
+	return e.page, true
+}
+
+func (c *repoCache) set(key string, p repoPage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*list.Element)
+	}
+	e := &repoCacheEntry{key: key, page: p, expiresAt: time.Now().Add(repoPickerTTL)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = e
+		c.order.MoveToBack(el)
+		return
+	}
+	c.entries[key] = c.order.PushBack(e)
+	size := c.size
+	if size <= 0 {
+		size = repoCacheSize
+	}
+	for c.order.Len() > size {
+		oldest := c.order.Front()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*repoCacheEntry).key)
+	}
+}
+
+func (a *API) mountRepos(api *mux.Router) {
+	cache := &repoCache{}
+
+	// GET /api/github/repos?source=user|org|search&owner=&q=&page=&per_page=&visibility=&language=
+	// Le token GitHub est passé dans l'en-tête X-GitHub-Token.
+	// page, per_page et has_next suivent la pagination GitHub : hors recherche, language (et visibility
+	// pour une org ou un autre utilisateur) filtre chaque page après coup, qui peut donc compter moins
+	// de per_page dépôts, voire aucun, avec has_next vrai.
+	api.HandleFunc("/github/repos", middleware.RequireScope(auth.ScopeProjectsRead, func(w http.ResponseWriter, r *http.Request) {
+		id := a.identity(r)
+		token := r.Header.Get("X-GitHub-Token")
+		if token == "" {
+			httpx.BadRequest(w, "missing X-GitHub-Token header")
+			return
+		}
+		q := r.URL.Query()
+		source := q.Get("source")
+		if source == "" {
+			source = "user"
+		}
+		owner := q.Get("owner")
+		visibility := q.Get("visibility")
+		language := q.Get("language")
+		page, perPage := 1, 30
+		if v, err := strconv.Atoi(q.Get("page")); err == nil && v > 0 {
+			page = v
+		}
+		if v, err := strconv.Atoi(q.Get("per_page")); err == nil && v > 0 {
+			perPage = min(v, 100)
+		}
+		switch visibility {
+		case "", "all", "public", "private":
+		default:
+			httpx.BadRequest(w, "visibility must be all, public or private")
+			return
+		}
+
+		h := sha256.Sum256([]byte(token))
+		key := strings.Join([]string{hex.EncodeToString(h[:]), source, owner, q.Get("q"), visibility, language, strconv.Itoa(page), strconv.Itoa(perPage)}, "|")
+		res, cached := cache.get(key)
+		if !cached {
+			gh := a.githubClient(token)
+			var (
+				repos []github.Repo
+				err   error
+			)
+			res = repoPage{Page: page, PerPage: perPage}
+			switch source {
+			case "search":
+				if q.Get("q") == "" {
+					httpx.BadRequest(w, "q required for source=search")
+					return
+				}
+				query := q.Get("q")
+				if visibility == "public" || visibility == "private" {
+					query += " is:" + visibility
+				}
+				if language != "" {
+					query += " language:" + language
+				}
+				if owner != "" {
+					query += " user:" + owner
+				}
+				var total int
+				repos, total, res.HasNext, err = gh.SearchRepos(r.Context(), query, page, perPage)
+				res.TotalCount = &total
+			case "org":
+				if owner == "" {
+					httpx.BadRequest(w, "owner required for source=org")
+					return
+				}
+				repos, res.HasNext, err = gh.ListPage(r.Context(), "/orgs/"+url.PathEscape(owner)+"/repos", url.Values{"type": {"all"}, "sort": {"updated"}}, page, perPage)
+			case "user":
+				params := url.Values{"sort": {"updated"}}
+				path := "/user/repos"
+				if owner != "" {
+					path = "/users/" + url.PathEscape(owner) + "/repos"
+				} else if visibility != "" {
+					params.Set("visibility", visibility)
+				}
+				repos, res.HasNext, err = gh.ListPage(r.Context(), path, params, page, perPage)
+			default:
+				httpx.BadRequest(w, "source must be user, org or search")
+				return
+			}
+			if err != nil {
+				var ghErr *github.Error
+				if errors.As(err, &ghErr) && ghErr.StatusCode < 500 {
+					httpx.BadRequest(w, "github request failed: "+ghErr.Error())
+					return
+				}
+				httpx.InternalError(w, err.Error())
+				return
+			}
+			// le listing GitHub ne filtre pas par langage (ni par visibilité pour les orgs)
+			res.Items = make([]repoChoice, 0, len(repos))
+			for _, repo := range repos {
+				if source != "search" {
+					if visibility == "public" && repo.Private || visibility == "private" && !repo.Private {
+						continue
+					}
+					if language != "" && !strings.EqualFold(repo.Language, language) {
+						continue
+					}
+				}
+				res.Items = append(res.Items, repoChoice{Repo: repo})
+			}
+			cache.set(key, res)
+		}
+		res.Cached = cached
+
+		// annotation (non cachée) des dépôts déjà importés par l'utilisateur
+		if len(res.Items) > 0 {
+			names := make([]string, 0, len(res.Items))
+			for _, it := range res.Items {
+				names = append(names, it.FullName)
+			}
+			var linked []db.Project
+			lq := visibleProjects(a.DB.Select("id, github_repo").Where("github_repo IN ?", names), id, "")
+			if err := lq.Find(&linked).Error; err != nil {
+				httpx.InternalError(w, err.Error())
+				return
+			}
+			byRepo := make(map[string]string, len(linked))
+			for _, p := range linked {
+				if p.GithubRepo != nil {
+					byRepo[*p.GithubRepo] = p.ID
+				}
+			}
+			items := make([]repoChoice, len(res.Items))
+			for i, it := range res.Items {
+				if id, ok := byRepo[it.FullName]; ok {
+					it.ProjectID = &id
+				}
+				items[i] = it
+			}
+			res.Items = items
+		}
+		httpx.OK(w, res)
+	})).Methods(http.MethodGet)
+}
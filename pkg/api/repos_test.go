@@ -0,0 +1,44 @@
+package api
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestRepoCacheEviction(t *testing.T) {
+	c := &repoCache{size: 3}
+	for i := range 3 {
+		c.set(strconv.Itoa(i), repoPage{Page: i})
+	}
+	// "0" est réécrit : il devient le plus récent
+	c.set("0", repoPage{Page: 10})
+	c.set("3", repoPage{Page: 3})
+	c.set("4", repoPage{Page: 4})
+
+	if len(c.entries) != 3 || c.order.Len() != 3 {
+		t.Fatalf("cache holds %d entries (%d ordered), want 3", len(c.entries), c.order.Len())
+	}
+	for _, k := range []string{"1", "2"} {
+		if _, ok := c.get(k); ok {
+			t.Errorf("oldest entry %q not evicted", k)
+		}
+	}
+	for k, want := range map[string]int{"0": 10, "3": 3, "4": 4} {
+		if p, ok := c.get(k); !ok || p.Page != want {
+			t.Errorf("get(%q) = %v, %v, want page %d", k, p.Page, ok, want)
+		}
+	}
+}
+
+func TestRepoCacheDefaultSize(t *testing.T) {
+	var c repoCache
+	for i := range repoCacheSize + 50 {
+		c.set(strconv.Itoa(i), repoPage{})
+	}
+	if len(c.entries) != repoCacheSize {
+		t.Errorf("cache holds %d entries, want %d", len(c.entries), repoCacheSize)
+	}
+	if _, ok := c.get("0"); ok {
+		t.Error("first entry not evicted")
+	}
+}
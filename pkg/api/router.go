@@ -11,23 +11,45 @@ import (
 
 func (a *API) Router() http.Handler {
 	r := mux.NewRouter()
-	// global logging middleware
-	r.Use(middleware.LoggingMiddleware)
+	// global logging middleware, per-IP rate limiting of public routes
+	r.Use(middleware.LoggingMiddleware, a.rateLimitPublic)
 	// Public
 	r.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		httpx.OK(w, map[string]any{"status": "ok", "time": time.Now()})
 	}).Methods(http.MethodGet)
+	a.mountGithubWebhooks(r)
+	a.mountArtifactDownloads(r)
+	a.mountInstallPages(r)
+	a.mountChannelResolver(r)
+	a.mountUpdates(r)
+	a.mountDevAuth(r)
+
+	// Workers de build : routes déclarées avant /api, qui refuse les workers
+	wk := r.PathPrefix("/api/worker").Subrouter()
+	wk.Use(middleware.RequireAuth(a.JWKS, a.workerTokenPolicy(), a.validateAPIToken), a.requireWorker, a.rateLimit)
+	a.mountWorkerRoutes(wk)
+	a.mountArtifacts(wk)
 
 	// Protected API
 	api := r.PathPrefix("/api").Subrouter()
-	if a.JWKS != nil {
-		api.Use(middleware.RequireAuth(a.JWKS, ""))
-	}
+	api.Use(middleware.RequireAuth(a.JWKS, a.TokenPolicy, a.validateAPIToken), a.rejectWorkers, a.rateLimit)
 
 	// Mount per-model subrouters
 	a.mountProjects(api)
+	a.mountImports(api)
+	a.mountRepos(api)
 	a.mountBuilds(api)
+	a.mountArtifacts(api)
+	a.mountInstallLinks(api)
+	a.mountAppMetadata(api)
+	a.mountChannels(api)
+	a.mountAppKeys(api)
+	a.mountSchedules(api)
+	a.mountWebhooks(api)
+	a.mountBuildConfig(api)
 	a.mountEnvVars(api)
 	a.mountAuth(api)
+	a.mountTokens(api)
+	a.mountWorkers(api)
 	return r
 }
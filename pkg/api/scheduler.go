@@ -0,0 +1,207 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/flotio-dev/project-service/pkg/buildconfig"
+	"github.com/flotio-dev/project-service/pkg/db"
+	"gorm.io/gorm"
+)
+
+// scheduleLockClass est la première clé des advisory locks des planifications
+// (la seconde est le hash de l'id), pour ne pas entrer en collision avec d'autres verrous.
+const scheduleLockClass = 0x666c6f74 // "flot"
+
+// RunScheduler évalue les planifications dues toutes les interval jusqu'à l'annulation de ctx.
+// Plusieurs réplicas peuvent tourner en parallèle : chaque déclenchement est protégé par un
+// advisory lock Postgres et next_run_at est revérifié une fois le verrou obtenu.
+func (a *API) RunScheduler(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		a.runDueSchedules(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (a *API) runDueSchedules(ctx context.Context) {
+	var ids []string
+	err := a.DB.WithContext(ctx).Model(&db.Schedule{}).
+		Where("enabled AND next_run_at <= ?", time.Now()).Order("next_run_at ASC").Pluck("id", &ids).Error
+	if err != nil {
+		log.Printf("scheduler: %v", err)
+		return
+	}
+	for _, id := range ids {
+		if err := a.runSchedule(ctx, id); err != nil {
+			log.Printf("scheduler: schedule %s: %v", id, err)
+		}
+	}
+}
+
+// scheduleTrigger est le résultat des appels GitHub d'un déclenchement, préparé hors transaction.
+type scheduleTrigger struct {
+	project db.Project
+	sha     string
+	cfg     *buildconfig.Config
+	skip    string // raison du saut, vide si des builds doivent être créés
+}
+
+// runSchedule déclenche une planification due, sauf si un autre réplica s'en occupe.
+// Les appels GitHub sont faits avant de prendre le verrou : la transaction ne contient que
+// la revérification de next_run_at et les insertions.
+func (a *API) runSchedule(ctx context.Context, id string) error {
+	var s db.Schedule
+	if err := a.DB.WithContext(ctx).First(&s, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if !s.Enabled || s.NextRunAt == nil || s.NextRunAt.After(time.Now()) {
+		return nil
+	}
+	due, branch := *s.NextRunAt, s.Branch
+	trigger, prepErr := a.prepareSchedule(ctx, s)
+
+	return a.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?, hashtext(?))", scheduleLockClass, id).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+		if err := tx.First(&s, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		now := time.Now()
+		// déjà traitée par un autre réplica, ou modifiée pendant la préparation
+		if !s.Enabled || s.NextRunAt == nil || !s.NextRunAt.Equal(due) || s.Branch != branch {
+			return nil
+		}
+		run := db.ScheduleRun{ScheduleID: s.ID, ScheduledFor: due}
+		if trigger.sha != "" {
+			run.CommitSHA = &trigger.sha
+		}
+		err := prepErr
+		if err == nil {
+			// savepoint : un échec annule les builds partiellement créés mais garde la trace du run
+			err = tx.Transaction(func(inner *gorm.DB) error {
+				return a.triggerSchedule(inner, s, trigger, &run)
+			})
+		}
+		if err != nil {
+			run.Status, run.Message, run.Builds = "failed", err.Error(), 0
+		}
+		if err := tx.Create(&run).Error; err != nil {
+			return err
+		}
+		// les occurrences manquées (service arrêté) ne sont pas rattrapées
+		updates := map[string]any{"last_run_at": now}
+		if next, err := nextRun(s, now); err == nil {
+			updates["next_run_at"] = next
+		} else {
+			updates["enabled"] = false
+		}
+		return tx.Model(&s).Updates(updates).Error
+	})
+}
+
+// prepareSchedule résout la tête de la branche et la configuration de build via GitHub.
+// Le déclenchement est sauté si la tête n'a pas changé depuis le dernier build planifié réussi.
+func (a *API) prepareSchedule(ctx context.Context, s db.Schedule) (scheduleTrigger, error) {
+	t := scheduleTrigger{project: db.Project{ID: s.ProjectID}}
+	if err := a.DB.WithContext(ctx).First(&t.project, "id = ?", s.ProjectID).Error; err != nil {
+		return t, err
+	}
+	p := t.project
+	if p.GithubRepo == nil || p.GithubToken == nil || *p.GithubToken == "" {
+		return t, errors.New("project is not linked to a GitHub repository")
+	}
+	sha, err := a.githubClient(*p.GithubToken).GetBranchHead(ctx, *p.GithubRepo, s.Branch)
+	if err != nil {
+		return t, fmt.Errorf("resolve branch %s: %w", s.Branch, err)
+	}
+	t.sha = sha
+
+	var last []string
+	err = a.DB.WithContext(ctx).Model(&db.Build{}).Where("schedule_id = ? AND status = ?", s.ID, "success").
+		Order("created_at DESC").Limit(1).Pluck("commit_sha", &last).Error
+	if err != nil {
+		return t, err
+	}
+	if len(last) > 0 && last[0] == sha {
+		t.skip = "branch head unchanged since last successful build"
+		return t, nil
+	}
+	t.cfg, err = a.resolveBuildConfig(ctx, p, sha)
+	return t, err
+}
+
+// triggerSchedule met en file les builds de la planification préparée par prepareSchedule.
+func (a *API) triggerSchedule(tx *gorm.DB, s db.Schedule, t scheduleTrigger, run *db.ScheduleRun) error {
+	p, sha, cfg := t.project, t.sha, t.cfg
+	if t.skip != "" {
+		run.Status, run.Message = "skipped", t.skip
+		return nil
+	}
+
+	var err error
+	var rawCfg db.JSON
+	var platforms []string
+	for _, pl := range splitPlatforms(&s.Platforms) {
+		if cfg == nil || slices.Contains(cfg.Platforms, pl) {
+			platforms = append(platforms, pl)
+		}
+	}
+	if cfg != nil {
+		if rawCfg, err = db.NewJSON(cfg); err != nil {
+			return err
+		}
+	}
+	if len(platforms) == 0 {
+		run.Status, run.Message = "skipped", "no scheduled platform enabled in build config"
+		return nil
+	}
+
+	var br db.Branch
+	err = tx.Where("project_id = ? AND name = ? AND pull_request IS NULL", p.ID, s.Branch).First(&br).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		br = db.Branch{ProjectID: p.ID, Name: s.Branch, HeadSHA: &sha}
+		if err := tx.Create(&br).Error; err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		if err := tx.Model(&br).Update("head_sha", sha).Error; err != nil {
+			return err
+		}
+	}
+	for _, pl := range platforms {
+		b := db.Build{ProjectID: p.ID, BranchID: &br.ID, Platform: pl, Status: "pending", CommitSHA: &sha, Config: rawCfg, ScheduleID: &s.ID}
+		if err := tx.Create(&b).Error; err != nil {
+			return err
+		}
+		if err := emitBuildEvent(tx, eventBuildCreated, b); err != nil {
+			return err
+		}
+		run.Builds++
+	}
+	run.Status = "queued"
+	return nil
+}
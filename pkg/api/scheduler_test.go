@@ -0,0 +1,167 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flotio-dev/project-service/pkg/db/dbtest"
+)
+
+// queryValue retourne l'argument lié à column dans un INSERT ("col", ... VALUES ($1, ...)) ou
+// un UPDATE ("col"=$n).
+func queryValue(t *testing.T, q dbtest.Query, column string) any {
+	t.Helper()
+	if m := regexp.MustCompile(`"` + column + `"=\$(\d+)`).FindStringSubmatch(q.SQL); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return q.Args[n-1]
+	}
+	if strings.HasPrefix(q.SQL, "INSERT") {
+		cols := q.SQL[strings.Index(q.SQL, "(")+1 : strings.Index(q.SQL, ")")]
+		for i, c := range strings.Split(cols, ",") {
+			if c == `"`+column+`"` {
+				return q.Args[i]
+			}
+		}
+	}
+	t.Fatalf("no %s in %s", column, q.SQL)
+	return nil
+}
+
+// scheduleFixture prépare une planification due de p1 (branche main, ANDROID et IOS, tous les jours
+// à 2h UTC) dont la branche pointe sur head côté GitHub.
+func scheduleFixture(t *testing.T, head string) (*API, *dbtest.DB, time.Time) {
+	gh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/acme/app/branches/main":
+			_, _ = w.Write([]byte(`{"commit":{"sha":"` + head + `"}}`))
+		default: // pas de flotio.yaml
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(gh.Close)
+
+	gdb, fake := dbtest.Open(t)
+	due := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	fake.Return(`FROM "schedules"`, dbtest.Rows(
+		[]string{"id", "project_id", "cron", "time_zone", "branch", "platforms", "enabled", "next_run_at"},
+		[]any{"s1", "p1", "0 2 * * *", "UTC", "main", "ANDROID,IOS", true, due}))
+	fake.Return(`FROM "projects"`, dbtest.Rows([]string{"id", "github_repo", "github_token"}, []any{"p1", "acme/app", "ghp_x"}))
+	fake.Return(`pg_try_advisory_xact_lock`, dbtest.Rows([]string{"locked"}, []any{true}))
+	return &API{DB: gdb, githubURL: gh.URL}, fake, due
+}
+
+// scheduleRun retourne le run enregistré et la mise à jour de la planification.
+func scheduleRun(t *testing.T, fake *dbtest.DB) (run, update dbtest.Query) {
+	t.Helper()
+	runs, updates := fake.Queries(`INSERT INTO "schedule_runs"`), fake.Queries(`UPDATE "schedules"`)
+	if len(runs) != 1 || len(updates) != 1 {
+		t.Fatalf("%d runs and %d schedule updates, want 1 each", len(runs), len(updates))
+	}
+	return runs[0], updates[0]
+}
+
+func TestRunScheduleQueuesBuilds(t *testing.T) {
+	a, fake, due := scheduleFixture(t, "new-sha")
+	fake.Return(`SELECT "commit_sha" FROM "builds"`, dbtest.Rows([]string{"commit_sha"}, []any{"old-sha"}))
+	if err := a.runSchedule(context.Background(), "s1"); err != nil {
+		t.Fatal(err)
+	}
+	run, update := scheduleRun(t, fake)
+	if got := queryValue(t, run, "status"); got != "queued" {
+		t.Errorf("run status = %v (%v), want queued", got, queryValue(t, run, "message"))
+	}
+	if got := queryValue(t, run, "builds"); got != 2 {
+		t.Errorf("run builds = %v, want 2", got)
+	}
+	if got := queryValue(t, run, "scheduled_for"); !got.(time.Time).Equal(due) {
+		t.Errorf("scheduled_for = %v, want %v", got, due)
+	}
+	builds := fake.Queries(`INSERT INTO "builds"`)
+	if len(builds) != 2 || queryValue(t, builds[0], "platform") != "ANDROID" || queryValue(t, builds[1], "platform") != "IOS" {
+		t.Fatalf("builds = %v", builds)
+	}
+	if sha := queryValue(t, builds[0], "commit_sha"); *sha.(*string) != "new-sha" {
+		t.Errorf("build commit = %v, want new-sha", *sha.(*string))
+	}
+	// prochaine occurrence : demain (ou aujourd'hui) à 2h UTC, strictement après maintenant
+	next := queryValue(t, update, "next_run_at").(time.Time)
+	if next.Hour() != 2 || next.Minute() != 0 || !next.After(time.Now()) || next.After(time.Now().Add(24*time.Hour)) {
+		t.Errorf("next_run_at = %v, want the next 02:00 UTC", next)
+	}
+}
+
+func TestRunScheduleSkipsUnchangedHead(t *testing.T) {
+	a, fake, _ := scheduleFixture(t, "same-sha")
+	fake.Return(`SELECT "commit_sha" FROM "builds"`, dbtest.Rows([]string{"commit_sha"}, []any{"same-sha"}))
+	if err := a.runSchedule(context.Background(), "s1"); err != nil {
+		t.Fatal(err)
+	}
+	run, update := scheduleRun(t, fake)
+	if got := queryValue(t, run, "status"); got != "skipped" {
+		t.Errorf("run status = %v, want skipped", got)
+	}
+	if len(fake.Queries(`INSERT INTO "builds"`)) != 0 {
+		t.Error("builds created for an unchanged head")
+	}
+	if _, ok := queryValue(t, update, "next_run_at").(time.Time); !ok {
+		t.Error("next_run_at not advanced after a skipped run")
+	}
+}
+
+func TestRunScheduleNotDue(t *testing.T) {
+	a, fake, _ := scheduleFixture(t, "new-sha")
+	fake.Return(`FROM "schedules"`, dbtest.Rows([]string{"id", "project_id", "cron", "time_zone", "branch", "platforms", "enabled", "next_run_at"},
+		[]any{"s1", "p1", "0 2 * * *", "UTC", "main", "ANDROID", true, time.Now().Add(time.Hour)}))
+	if err := a.runSchedule(context.Background(), "s1"); err != nil {
+		t.Fatal(err)
+	}
+	if qs := fake.Queries(`^BEGIN|INSERT|UPDATE`); len(qs) != 0 {
+		t.Errorf("schedule not due ran %v", qs)
+	}
+}
+
+func TestRunScheduleLockedByAnotherReplica(t *testing.T) {
+	a, fake, _ := scheduleFixture(t, "new-sha")
+	fake.Return(`pg_try_advisory_xact_lock`, dbtest.Rows([]string{"locked"}, []any{false}))
+	if err := a.runSchedule(context.Background(), "s1"); err != nil {
+		t.Fatal(err)
+	}
+	if qs := fake.Queries(`INSERT|UPDATE`); len(qs) != 0 {
+		t.Errorf("locked schedule wrote %v", qs)
+	}
+}
+
+func TestRunScheduleFailures(t *testing.T) {
+	t.Run("project not linked to GitHub", func(t *testing.T) {
+		a, fake, _ := scheduleFixture(t, "new-sha")
+		fake.Return(`FROM "projects"`, dbtest.Rows([]string{"id"}, []any{"p1"}))
+		if err := a.runSchedule(context.Background(), "s1"); err != nil {
+			t.Fatal(err)
+		}
+		run, update := scheduleRun(t, fake)
+		if got := queryValue(t, run, "status"); got != "failed" {
+			t.Errorf("run status = %v, want failed", got)
+		}
+		if _, ok := queryValue(t, update, "next_run_at").(time.Time); !ok {
+			t.Error("next_run_at not advanced after a failed run")
+		}
+	})
+	t.Run("invalid time zone disables the schedule", func(t *testing.T) {
+		a, fake, due := scheduleFixture(t, "new-sha")
+		fake.Return(`FROM "schedules"`, dbtest.Rows([]string{"id", "project_id", "cron", "time_zone", "branch", "platforms", "enabled", "next_run_at"},
+			[]any{"s1", "p1", "0 2 * * *", "Mars/Olympus", "main", "ANDROID", true, due}))
+		if err := a.runSchedule(context.Background(), "s1"); err != nil {
+			t.Fatal(err)
+		}
+		_, update := scheduleRun(t, fake)
+		if got := queryValue(t, update, "enabled"); got != false {
+			t.Errorf("enabled = %v, want false", got)
+		}
+	})
+}
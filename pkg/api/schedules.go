@@ -0,0 +1,205 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flotio-dev/project-service/pkg/auth"
+	"github.com/flotio-dev/project-service/pkg/cron"
+	"github.com/flotio-dev/project-service/pkg/db"
+	"github.com/flotio-dev/project-service/pkg/httpx"
+	"github.com/flotio-dev/project-service/pkg/middleware"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+func (a *API) mountSchedules(api *mux.Router) {
+	// GET /api/projects/{projectID}/schedules
+	api.HandleFunc("/projects/{projectID}/schedules", middleware.RequireScope(auth.ScopeBuildsRead, func(w http.ResponseWriter, r *http.Request) {
+		p, ok := a.ownedProject(w, r, auth.RoleViewer)
+		if !ok {
+			return
+		}
+		var ss []db.Schedule
+		if err := a.DB.Where("project_id = ?", p.ID).Order("created_at ASC").Find(&ss).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.OK(w, ss)
+	})).Methods(http.MethodGet)
+
+	// POST /api/projects/{projectID}/schedules
+	// {"cron": "0 2 * * *", "time_zone": "Europe/Paris", "branch": "main", "platforms": "ANDROID,IOS"}
+	api.HandleFunc("/projects/{projectID}/schedules", middleware.RequireScope(auth.ScopeBuildsTrigger, func(w http.ResponseWriter, r *http.Request) {
+		sub, _ := middleware.GetValue[string](r, "sub")
+		p, ok := a.ownedProject(w, r, auth.RoleDeveloper)
+		if !ok {
+			return
+		}
+		var in struct {
+			Cron      string `json:"cron"`
+			TimeZone  string `json:"time_zone"`
+			Branch    string `json:"branch"`
+			Platforms string `json:"platforms"`
+			Enabled   *bool  `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Cron == "" || in.Branch == "" || in.Platforms == "" {
+			httpx.BadRequest(w, "invalid payload (cron, branch and platforms required)")
+			return
+		}
+		if p.GithubRepo == nil {
+			httpx.Conflict(w, "project is not linked to a GitHub repository")
+			return
+		}
+		if in.TimeZone == "" {
+			in.TimeZone = "UTC"
+		}
+		s := db.Schedule{ProjectID: p.ID, CreatedBy: sub, Cron: in.Cron, TimeZone: in.TimeZone, Branch: in.Branch, Platforms: in.Platforms, Enabled: true}
+		if in.Enabled != nil {
+			s.Enabled = *in.Enabled
+		}
+		if err := prepareSchedule(&s, time.Now()); err != nil {
+			httpx.BadRequest(w, err.Error())
+			return
+		}
+		if err := a.DB.Create(&s).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.Created(w, s)
+	})).Methods(http.MethodPost)
+
+	// PATCH /api/projects/{projectID}/schedules/{scheduleID}
+	api.HandleFunc("/projects/{projectID}/schedules/{scheduleID}", middleware.RequireScope(auth.ScopeBuildsTrigger, func(w http.ResponseWriter, r *http.Request) {
+		s, ok := a.ownedSchedule(w, r, auth.RoleDeveloper)
+		if !ok {
+			return
+		}
+		var in struct {
+			Cron      *string `json:"cron"`
+			TimeZone  *string `json:"time_zone"`
+			Branch    *string `json:"branch"`
+			Platforms *string `json:"platforms"`
+			Enabled   *bool   `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			httpx.BadRequest(w, "invalid payload")
+			return
+		}
+		if in.Cron != nil {
+			s.Cron = *in.Cron
+		}
+		if in.TimeZone != nil {
+			s.TimeZone = *in.TimeZone
+		}
+		if in.Branch != nil && *in.Branch != "" {
+			s.Branch = *in.Branch
+		}
+		if in.Platforms != nil {
+			s.Platforms = *in.Platforms
+		}
+		if in.Enabled != nil {
+			s.Enabled = *in.Enabled
+		}
+		if err := prepareSchedule(&s, time.Now()); err != nil {
+			httpx.BadRequest(w, err.Error())
+			return
+		}
+		err := a.DB.Model(&s).Select("cron", "time_zone", "branch", "platforms", "enabled", "next_run_at").Updates(&s).Error
+		if err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.OK(w, s)
+	})).Methods(http.MethodPatch)
+
+	// DELETE /api/projects/{projectID}/schedules/{scheduleID}
+	api.HandleFunc("/projects/{projectID}/schedules/{scheduleID}", middleware.RequireScope(auth.ScopeBuildsTrigger, func(w http.ResponseWriter, r *http.Request) {
+		s, ok := a.ownedSchedule(w, r, auth.RoleDeveloper)
+		if !ok {
+			return
+		}
+		err := a.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("schedule_id = ?", s.ID).Delete(&db.ScheduleRun{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&s).Error
+		})
+		if err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.NoContent(w)
+	})).Methods(http.MethodDelete)
+
+	// GET /api/projects/{projectID}/schedules/{scheduleID}/runs
+	api.HandleFunc("/projects/{projectID}/schedules/{scheduleID}/runs", middleware.RequireScope(auth.ScopeBuildsRead, func(w http.ResponseWriter, r *http.Request) {
+		s, ok := a.ownedSchedule(w, r, auth.RoleViewer)
+		if !ok {
+			return
+		}
+		var runs []db.ScheduleRun
+		if err := a.DB.Where("schedule_id = ?", s.ID).Order("id DESC").Limit(100).Find(&runs).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.OK(w, runs)
+	})).Methods(http.MethodGet)
+}
+
+// prepareSchedule valide une planification et calcule sa prochaine exécution.
+func prepareSchedule(s *db.Schedule, now time.Time) error {
+	pls, err := normalizePlatforms(s.Platforms)
+	if err != nil {
+		return err
+	}
+	if pls == "" {
+		return errors.New("platforms required")
+	}
+	s.Platforms = pls
+	s.Branch = strings.TrimPrefix(s.Branch, "refs/heads/")
+	next, err := nextRun(*s, now)
+	if err != nil {
+		return err
+	}
+	s.NextRunAt = &next
+	return nil
+}
+
+// nextRun retourne la prochaine occurrence de la planification après t, dans son fuseau.
+func nextRun(s db.Schedule, t time.Time) (time.Time, error) {
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return time.Time{}, errors.New("invalid time_zone: " + s.TimeZone)
+	}
+	c, err := cron.Parse(s.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := c.Next(t.In(loc))
+	if next.IsZero() {
+		return next, errors.New("cron expression never matches")
+	}
+	return next.UTC(), nil
+}
+
+// ownedSchedule charge la planification {scheduleID} d'un projet sur lequel l'appelant a au moins le rôle min.
+func (a *API) ownedSchedule(w http.ResponseWriter, r *http.Request, min auth.Role) (db.Schedule, bool) {
+	var s db.Schedule
+	p, ok := a.ownedProject(w, r, min)
+	if !ok {
+		return s, false
+	}
+	if err := a.DB.First(&s, "id = ? AND project_id = ?", mux.Vars(r)["scheduleID"], p.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httpx.NotFound(w, "schedule not found")
+			return s, false
+		}
+		httpx.InternalError(w, err.Error())
+		return s, false
+	}
+	return s, true
+}
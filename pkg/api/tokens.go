@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/flotio-dev/project-service/pkg/auth"
+	"github.com/flotio-dev/project-service/pkg/db"
+	"github.com/flotio-dev/project-service/pkg/httpx"
+	"github.com/flotio-dev/project-service/pkg/middleware"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// maxAPITokenTTL borne la durée de vie d'un token d'API (1 an).
+const maxAPITokenTTL = 365 * 24 * time.Hour
+
+// apiTokenView expose le token en clair uniquement à la création.
+type apiTokenView struct {
+	db.APIToken
+	Token string `json:"token,omitempty"`
+}
+
+func (a *API) mountTokens(api *mux.Router) {
+	// GET /api/tokens
+	api.HandleFunc("/tokens", middleware.RequireScope(auth.ScopeTokensManage, func(w http.ResponseWriter, r *http.Request) {
+		sub, _ := middleware.GetValue[string](r, "sub")
+		var ts []db.APIToken
+		if err := a.DB.Where("user_id = ?", sub).Order("created_at DESC").Find(&ts).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.OK(w, ts)
+	})).Methods(http.MethodGet)
+
+	// POST /api/tokens {"name": "ci", "scopes": "projects:read builds:trigger", "expires_in": 2592000}
+	api.HandleFunc("/tokens", middleware.RequireScope(auth.ScopeTokensManage, func(w http.ResponseWriter, r *http.Request) {
+		sub, _ := middleware.GetValue[string](r, "sub")
+		var in struct {
+			Name      string `json:"name"`
+			Scopes    string `json:"scopes"`
+			ExpiresIn int64  `json:"expires_in"` // secondes, 0 = 1 an
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Name == "" {
+			httpx.BadRequest(w, "invalid payload (name required)")
+			return
+		}
+		scopes, err := normalizeScopes(in.Scopes)
+		if err != nil {
+			httpx.BadRequest(w, err.Error())
+			return
+		}
+		// un token ne peut pas obtenir plus de droits que son créateur
+		for _, sc := range strings.Fields(scopes) {
+			if !middleware.HasScope(r, sc) {
+				httpx.Forbidden(w, "missing scope "+sc)
+				return
+			}
+		}
+		ttl := time.Duration(in.ExpiresIn) * time.Second
+		if ttl <= 0 {
+			ttl = maxAPITokenTTL
+		}
+		if ttl > maxAPITokenTTL {
+			httpx.BadRequest(w, "expires_in too large (max 1 year)")
+			return
+		}
+		token, prefix, hash, err := auth.NewAPIToken(auth.APITokenPrefix)
+		if err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		// le token agit avec les groupes de son créateur au moment de sa création
+		claims, _ := middleware.GetValue[jwt.MapClaims](r, "claims")
+		var groups db.JSON
+		if g := auth.GroupsClaim(claims); len(g) > 0 {
+			if groups, err = db.NewJSON(g); err != nil {
+				httpx.InternalError(w, err.Error())
+				return
+			}
+		}
+		exp := time.Now().Add(ttl)
+		t := db.APIToken{UserID: sub, Name: in.Name, Prefix: prefix, TokenHash: hash, Scopes: scopes, Groups: groups, ExpiresAt: &exp}
+		if err := a.DB.Create(&t).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.Created(w, apiTokenView{APIToken: t, Token: token})
+	})).Methods(http.MethodPost)
+
+	// DELETE /api/tokens/{tokenID} : révocation
+	api.HandleFunc("/tokens/{tokenID}", middleware.RequireScope(auth.ScopeTokensManage, func(w http.ResponseWriter, r *http.Request) {
+		sub, _ := middleware.GetValue[string](r, "sub")
+		res := a.DB.Model(&db.APIToken{}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL", mux.Vars(r)["tokenID"], sub).
+			Update("revoked_at", time.Now())
+		if res.Error != nil {
+			httpx.InternalError(w, res.Error.Error())
+			return
+		}
+		if res.RowsAffected == 0 {
+			httpx.NotFound(w, "token not found")
+			return
+		}
+		httpx.NoContent(w)
+	})).Methods(http.MethodDelete)
+}
+
+// validateAPIToken authentifie un token d'API (ou de worker) pour middleware.RequireAuth.
+func (a *API) validateAPIToken(ctx context.Context, token string) (jwt.MapClaims, error) {
+	if strings.HasPrefix(token, auth.WorkerTokenPrefix) {
+		return a.validateWorkerToken(ctx, token)
+	}
+	var t db.APIToken
+	if err := a.DB.WithContext(ctx).First(&t, "token_hash = ?", auth.HashAPIToken(token)).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invalid token")
+		}
+		return nil, err
+	}
+	now := time.Now()
+	switch {
+	case t.RevokedAt != nil:
+		return nil, errors.New("token revoked")
+	case t.ExpiresAt != nil && now.After(*t.ExpiresAt):
+		return nil, errors.New("token expired")
+	}
+	// last_used_at à la minute près, pour ne pas écrire à chaque requête
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) > time.Minute {
+		a.DB.WithContext(ctx).Model(&t).Update("last_used_at", now)
+	}
+	claims := jwt.MapClaims{"sub": t.UserID, "scope": t.Scopes, "token_type": "api_token", "token_id": t.ID}
+	if len(t.Groups) > 0 {
+		var groups []string
+		if err := json.Unmarshal(t.Groups, &groups); err != nil {
+			return nil, err
+		}
+		claims["groups"] = groups
+	}
+	if t.ExpiresAt != nil {
+		claims["exp"] = t.ExpiresAt.Unix()
+	}
+	return claims, nil
+}
+
+// normalizeScopes valide une liste de scopes (auth.KnownScopes ou jokers) séparés par des espaces ou des virgules.
+func normalizeScopes(s string) (string, error) {
+	var out []string
+	for _, sc := range strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' }) {
+		if !slices.Contains(auth.KnownScopes, sc) && sc != "*" && !strings.HasSuffix(sc, ":*") {
+			return "", fmt.Errorf("unknown scope %q (expected one of %s)", sc, strings.Join(auth.KnownScopes, ", "))
+		}
+		if !slices.Contains(out, sc) {
+			out = append(out, sc)
+		}
+	}
+	return strings.Join(out, " "), nil
+}
@@ -1,7 +1,12 @@
 package api
 
 import (
+	"time"
+
 	"github.com/flotio-dev/project-service/pkg/auth"
+	"github.com/flotio-dev/project-service/pkg/github"
+	"github.com/flotio-dev/project-service/pkg/ratelimit"
+	"github.com/flotio-dev/project-service/pkg/storage"
 	"gorm.io/gorm"
 )
 
@@ -9,4 +14,45 @@ import (
 type API struct {
 	DB   *gorm.DB
 	JWKS *auth.JWKSProvider
+
+	// Conversion des groupes Keycloak en rôles sur les projets
+	GroupMapping auth.GroupMapping
+
+	// Introspection RFC 7662 des opérations sensibles ; nil = désactivée
+	Introspector *auth.Introspector
+
+	// Limitation de débit (token bucket) ; nil = désactivée
+	RateLimiter         ratelimit.Limiter
+	RateLimit           ratelimit.Rate
+	RateLimitTrustProxy bool // X-Forwarded-For fourni par un reverse proxy de confiance
+
+	// Émetteur de tokens intégré (AUTH_DEV_MODE) ; nil hors développement
+	DevIssuer *auth.DevIssuer
+
+	// Politique de validation des JWT utilisateurs (audience, azp, horloge, claims requises)
+	TokenPolicy auth.TokenPolicy
+
+	// Clients Keycloak (azp) reconnus comme workers de build
+	WorkerClientIDs []string
+
+	// Secret des webhooks GitHub entrants; vide = récepteur désactivé
+	GithubWebhookSecret string
+
+	// Stockage des artefacts et signature des URLs de téléchargement
+	Storage        storage.Store
+	Signer         storage.URLSigner
+	ArtifactURLTTL time.Duration
+	PublicBaseURL  string // préfixe des URLs générées, vide = chemins relatifs
+
+	// URL de l'API GitHub, remplacée par un serveur de test ; vide = github.DefaultBaseURL
+	githubURL string
+}
+
+// githubClient construit un client de l'API GitHub authentifié par token.
+func (a *API) githubClient(token string) *github.Client {
+	c := github.NewClient(token)
+	if a.githubURL != "" {
+		c.BaseURL = a.githubURL
+	}
+	return c
 }
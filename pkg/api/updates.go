@@ -0,0 +1,142 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/flotio-dev/project-service/pkg/auth"
+	"github.com/flotio-dev/project-service/pkg/db"
+	"github.com/flotio-dev/project-service/pkg/httpx"
+	"github.com/flotio-dev/project-service/pkg/middleware"
+	"github.com/flotio-dev/project-service/pkg/version"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// updateCheck est la réponse de l'update checker.
+type updateCheck struct {
+	UpdateAvailable     bool         `json:"update_available"`
+	Mandatory           bool         `json:"mandatory"`
+	CurrentVersion      string       `json:"current_version"`
+	LatestVersion       string       `json:"latest_version,omitempty"`
+	MinSupportedVersion string       `json:"min_supported_version,omitempty"`
+	ReleaseNotes        string       `json:"release_notes,omitempty"`
+	DownloadURL         string       `json:"download_url,omitempty"`
+	Release             *releaseInfo `json:"release,omitempty"`
+}
+
+func (a *API) mountAppKeys(api *mux.Router) {
+	// POST /api/projects/{projectID}/app-key : génère (ou remplace) la clé de l'update checker
+	api.HandleFunc("/projects/{projectID}/app-key", middleware.RequireScope(auth.ScopeProjectsWrite, func(w http.ResponseWriter, r *http.Request) {
+		p, ok := a.ownedProject(w, r, auth.RoleAdmin)
+		if !ok {
+			return
+		}
+		raw := make([]byte, 16)
+		if _, err := rand.Read(raw); err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		key := "ak_" + hex.EncodeToString(raw)
+		if err := a.DB.Model(&p).Update("app_key", key).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.Created(w, map[string]string{"app_key": key})
+	})).Methods(http.MethodPost)
+}
+
+// mountUpdates monte l'endpoint public interrogé par les applications.
+func (a *API) mountUpdates(r *mux.Router) {
+	// GET /updates/{appKey}/check?platform=ANDROID&channel=production&version=1.2.3&build_number=45
+	r.HandleFunc("/updates/{appKey}/check", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		platform := strings.ToUpper(q.Get("platform"))
+		if !isKnownPlatform(platform) {
+			httpx.BadRequest(w, "invalid platform")
+			return
+		}
+		channel := q.Get("channel")
+		if channel == "" {
+			channel = "production"
+		}
+		current, err := version.Parse(q.Get("version"))
+		if err != nil {
+			httpx.BadRequest(w, "invalid version: "+err.Error())
+			return
+		}
+		current = current.WithBuild(q.Get("build_number"))
+
+		var p db.Project
+		if err := a.DB.Select("id").First(&p, "app_key = ?", mux.Vars(r)["appKey"]).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				httpx.NotFound(w, "unknown app key")
+				return
+			}
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		out := updateCheck{CurrentVersion: current.String()}
+		var c db.Channel
+		if err := a.DB.First(&c, "project_id = ? AND name = ?", p.ID, channel).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				httpx.OK(w, out)
+				return
+			}
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		info, err := a.latestRelease(r.Context(), p.ID, channel, platform)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				httpx.OK(w, out)
+				return
+			}
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		if latest, ok := releaseVersion(info); ok {
+			out.LatestVersion = latest.String()
+			out.UpdateAvailable = version.Compare(current, latest) < 0
+		}
+		if min, ok := minVersion(c, platform); ok {
+			out.MinSupportedVersion = min.String()
+			out.Mandatory = out.UpdateAvailable && version.Compare(current, min) < 0
+		}
+		if out.UpdateAvailable {
+			out.ReleaseNotes = info.Notes
+			out.DownloadURL = info.DownloadURL
+			out.Release = &info
+		}
+		httpx.OK(w, out)
+	}).Methods(http.MethodGet)
+}
+
+// releaseVersion lit la version d'un build promu (version_name + version_code).
+func releaseVersion(info releaseInfo) (version.Version, bool) {
+	if info.VersionName == nil {
+		return version.Version{}, false
+	}
+	v, err := version.Parse(*info.VersionName)
+	if err != nil {
+		return v, false
+	}
+	if info.VersionCode != nil {
+		v = v.WithBuild(*info.VersionCode)
+	}
+	return v, true
+}
+
+// minVersion retourne la version minimale supportée d'un canal pour une plateforme.
+func minVersion(c db.Channel, platform string) (version.Version, bool) {
+	var mins map[string]string
+	if len(c.MinVersions) == 0 || json.Unmarshal(c.MinVersions, &mins) != nil || mins[platform] == "" {
+		return version.Version{}, false
+	}
+	v, err := version.Parse(mins[platform])
+	return v, err == nil
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/flotio-dev/project-service/pkg/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// En-têtes des livraisons de webhooks.
+const (
+	webhookEventHeader     = "X-Flotio-Event"
+	webhookDeliveryHeader  = "X-Flotio-Delivery"
+	webhookSignatureHeader = "X-Flotio-Signature-256" // "sha256=" + HMAC-SHA256 hexa du corps
+)
+
+const (
+	webhookMaxAttempts  = 8
+	webhookBaseBackoff  = 30 * time.Second
+	webhookMaxBackoff   = time.Hour
+	webhookBatchSize    = 20
+	webhookTimeout      = 10 * time.Second
+	webhookLease        = 2 * time.Minute // une livraison réservée n'est pas reprise avant (> webhookTimeout)
+	webhookResponseSize = 2048
+)
+
+// webhookClient n'accepte que des adresses publiques (vérifiées à la connexion, après résolution DNS,
+// pour couvrir le DNS rebinding), ignore les proxys d'environnement et ne suit pas les redirections.
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(_, address string, _ syscall.RawConn) error {
+				return checkWebhookAddr(address)
+			},
+		}).DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: webhookTimeout,
+		MaxIdleConns:          20,
+		IdleConnTimeout:       90 * time.Second,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// errWebhookAddr est retournée pour une cible de webhook non publique.
+var errWebhookAddr = errors.New("webhook target address is not public")
+
+// webhookBlockedPrefixes complète les catégories de net/netip (bouclage, privé, lien local...).
+var webhookBlockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // CGNAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64
+}
+
+// checkWebhookAddr refuse les adresses ip:port non routables publiquement.
+func checkWebhookAddr(address string) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return errWebhookAddr
+	}
+	if !publicAddr(ap.Addr()) {
+		return fmt.Errorf("%w: %s", errWebhookAddr, ap.Addr())
+	}
+	return nil
+}
+
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, p := range webhookBlockedPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// RunWebhookDispatcher envoie les livraisons dues toutes les interval jusqu'à l'annulation de ctx.
+// Les livraisons sont réservées avec FOR UPDATE SKIP LOCKED : plusieurs réplicas se partagent la file.
+func (a *API) RunWebhookDispatcher(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		for {
+			n, err := a.dispatchWebhooks(ctx)
+			if err != nil {
+				log.Printf("webhooks: %v", err)
+			}
+			if n < webhookBatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// dispatchWebhooks envoie jusqu'à webhookBatchSize livraisons dues.
+// Chaque livraison est réservée juste avant son envoi : le bail ne couvre qu'une requête.
+func (a *API) dispatchWebhooks(ctx context.Context) (int, error) {
+	n := 0
+	for ; n < webhookBatchSize; n++ {
+		d, err := a.claimWebhookDelivery(ctx)
+		if err != nil {
+			return n, err
+		}
+		if d == nil {
+			break
+		}
+		a.deliverWebhook(ctx, *d)
+	}
+	return n, nil
+}
+
+// claimWebhookDelivery réserve la livraison due la plus ancienne (FOR UPDATE SKIP LOCKED) en
+// repoussant next_attempt_at de webhookLease; retourne nil si la file est vide.
+func (a *API) claimWebhookDelivery(ctx context.Context) (*db.WebhookDelivery, error) {
+	var d db.WebhookDelivery
+	err := a.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", "pending", time.Now()).
+			Order("next_attempt_at ASC").Take(&d).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&d).Update("next_attempt_at", time.Now().Add(webhookLease)).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// deliverWebhook tente une livraison et planifie la suivante en cas d'échec.
+func (a *API) deliverWebhook(ctx context.Context, d db.WebhookDelivery) {
+	var hook db.Webhook
+	updates := map[string]any{"attempts": d.Attempts + 1, "last_attempt_at": time.Now()}
+	err := a.DB.WithContext(ctx).First(&hook, "id = ?", d.WebhookID).Error
+	switch {
+	case err != nil:
+		updates["status"], updates["error"] = "failed", "webhook not found"
+	case !hook.Active:
+		updates["status"], updates["error"] = "failed", "webhook disabled"
+	default:
+		start := time.Now()
+		code, body, err := sendWebhook(ctx, hook, d)
+		updates["duration_ms"] = time.Since(start).Milliseconds()
+		updates["response_body"] = body
+		if code != 0 {
+			updates["response_code"] = code
+		}
+		switch {
+		case err == nil:
+			updates["status"], updates["error"], updates["next_attempt_at"] = "succeeded", "", nil
+		case d.Attempts+1 >= webhookMaxAttempts:
+			updates["status"], updates["error"], updates["next_attempt_at"] = "failed", err.Error(), nil
+		default:
+			updates["error"], updates["next_attempt_at"] = err.Error(), time.Now().Add(webhookBackoff(d.Attempts+1))
+		}
+	}
+	if err := a.DB.WithContext(ctx).Model(&d).Updates(updates).Error; err != nil {
+		log.Printf("webhooks: delivery %s: %v", d.ID, err)
+	}
+}
+
+// sendWebhook poste la charge utile signée ; toute réponse hors 2xx est un échec.
+func sendWebhook(ctx context.Context, hook db.Webhook, d db.WebhookDelivery) (int, string, error) {
+	mac := hmac.New(sha256.New, []byte(hook.Secret))
+	mac.Write(d.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "flotio-webhooks")
+	req.Header.Set(webhookEventHeader, d.Event)
+	req.Header.Set(webhookDeliveryHeader, d.ID)
+	req.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	res, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer res.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(res.Body, webhookResponseSize))
+	body := strings.ReplaceAll(strings.ToValidUTF8(string(raw), ""), "\x00", "")
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, body, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	return res.StatusCode, body, nil
+}
+
+// webhookBackoff retourne le délai avant la tentative suivant la n-ième (30s, 1m, 2m, ... 1h).
+func webhookBackoff(n int) time.Duration {
+	d := webhookBaseBackoff << (n - 1)
+	if d > webhookMaxBackoff || d <= 0 {
+		return webhookMaxBackoff
+	}
+	return d
+}
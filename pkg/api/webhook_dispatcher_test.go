@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flotio-dev/project-service/pkg/db"
+)
+
+func TestCheckWebhookAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		ok   bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", true},
+		{"127.0.0.1:80", false},
+		{"127.8.9.10:80", false},
+		{"[::1]:80", false},
+		{"10.1.2.3:80", false},
+		{"172.16.0.1:80", false},
+		{"192.168.1.1:80", false},
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:80", false},
+		{"[fd00::1]:80", false},
+		{"0.0.0.0:80", false},
+		{"[::]:80", false},
+		{"100.64.0.1:80", false},
+		{"224.0.0.1:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+		{"[::ffff:10.0.0.1]:80", false},
+		{"[64:ff9b::a00:1]:80", false},
+		{"not-an-address", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			err := checkWebhookAddr(tt.addr)
+			if (err == nil) != tt.ok {
+				t.Errorf("checkWebhookAddr(%q) = %v, want ok=%v", tt.addr, err, tt.ok)
+			}
+			if err != nil && !errors.Is(err, errWebhookAddr) {
+				t.Errorf("error %v does not wrap errWebhookAddr", err)
+			}
+		})
+	}
+}
+
+func TestNormalizeWebhook(t *testing.T) {
+	tests := []struct {
+		url, events string
+		want        string
+		wantErr     bool
+	}{
+		{"https://hooks.example.com/x", "build.created, build.finished,build.created", "build.created,build.finished", false},
+		{"http://hooks.example.com", "project.updated", "project.updated", false},
+		{"ftp://hooks.example.com", "build.created", "", true},
+		{"https://", "build.created", "", true},
+		{"https://hooks.example.com", "", "", true},
+		{"https://hooks.example.com", "envvar.changed,project.updated", "envvar.changed,project.updated", false},
+		{"https://hooks.example.com", "envvar.deleted", "", true},
+		{"http://127.0.0.1:8080/hook", "build.created", "", true},
+		{"http://[::1]/hook", "build.created", "", true},
+		{"http://169.254.169.254/latest/meta-data", "build.created", "", true},
+		{"http://10.0.0.5/hook", "build.created", "", true},
+		{"http://localhost:3000", "build.created", "", true},
+		{"http://api.LOCALHOST./x", "build.created", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url+" "+tt.events, func(t *testing.T) {
+			got, err := normalizeWebhook(tt.url, tt.events)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("normalizeWebhook = %q, %v; want %q, wantErr %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestSendWebhookBlocksLoopback vérifie que la connexion elle-même est refusée : un nom d'hôte
+// public au moment de la validation peut résoudre plus tard vers une adresse interne.
+func TestSendWebhookBlocksLoopback(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+	defer srv.Close()
+	hook := db.Webhook{URL: srv.URL, Secret: "s"}
+	_, _, err := sendWebhook(context.Background(), hook, db.WebhookDelivery{ID: "d1", Event: eventBuildCreated, Payload: db.JSON(`{}`)})
+	if !errors.Is(err, errWebhookAddr) {
+		t.Fatalf("sendWebhook err = %v, want errWebhookAddr", err)
+	}
+	if called {
+		t.Fatal("request reached the loopback server")
+	}
+}
+
+func TestWebhookClientDoesNotFollowRedirects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+	}))
+	defer srv.Close()
+	// même politique de redirection, sans le contrôle d'adresse pour joindre le serveur de test
+	client := &http.Client{Timeout: time.Second, CheckRedirect: webhookClient.CheckRedirect}
+	res, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("status = %d, want the redirect itself", res.StatusCode)
+	}
+}
@@ -0,0 +1,275 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/flotio-dev/project-service/pkg/auth"
+	"github.com/flotio-dev/project-service/pkg/db"
+	"github.com/flotio-dev/project-service/pkg/httpx"
+	"github.com/flotio-dev/project-service/pkg/middleware"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// Évènements pouvant être envoyés aux webhooks.
+const (
+	eventBuildCreated   = "build.created"
+	eventBuildFinished  = "build.finished"
+	eventProjectUpdated = "project.updated"
+	eventEnvVarChanged  = "envvar.changed"
+)
+
+var webhookEvents = []string{eventBuildCreated, eventBuildFinished, eventProjectUpdated, eventEnvVarChanged}
+
+// webhookEnvelope est le corps JSON envoyé aux webhooks.
+type webhookEnvelope struct {
+	Event     string    `json:"event"`
+	ProjectID string    `json:"project_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// webhookView expose le secret uniquement à la création.
+type webhookView struct {
+	db.Webhook
+	Secret string `json:"secret,omitempty"`
+}
+
+func (a *API) mountWebhooks(api *mux.Router) {
+	// GET /api/projects/{projectID}/webhooks
+	api.HandleFunc("/projects/{projectID}/webhooks", middleware.RequireScope(auth.ScopeWebhooksManage, func(w http.ResponseWriter, r *http.Request) {
+		p, ok := a.ownedProject(w, r, auth.RoleAdmin)
+		if !ok {
+			return
+		}
+		var hooks []db.Webhook
+		if err := a.DB.Where("project_id = ?", p.ID).Order("created_at ASC").Find(&hooks).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.OK(w, hooks)
+	})).Methods(http.MethodGet)
+
+	// POST /api/projects/{projectID}/webhooks {"url": "...", "events": "build.finished", "secret": "..."}
+	api.HandleFunc("/projects/{projectID}/webhooks", middleware.RequireScope(auth.ScopeWebhooksManage, func(w http.ResponseWriter, r *http.Request) {
+		sub, _ := middleware.GetValue[string](r, "sub")
+		p, ok := a.ownedProject(w, r, auth.RoleAdmin)
+		if !ok {
+			return
+		}
+		var in struct {
+			URL    string `json:"url"`
+			Events string `json:"events"`
+			Secret string `json:"secret"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			httpx.BadRequest(w, "invalid payload")
+			return
+		}
+		events, err := normalizeWebhook(in.URL, in.Events)
+		if err != nil {
+			httpx.BadRequest(w, err.Error())
+			return
+		}
+		secret := in.Secret
+		if secret == "" {
+			raw := make([]byte, 24)
+			if _, err := rand.Read(raw); err != nil {
+				httpx.InternalError(w, err.Error())
+				return
+			}
+			secret = "whsec_" + hex.EncodeToString(raw)
+		}
+		hook := db.Webhook{ProjectID: p.ID, CreatedBy: sub, URL: in.URL, Secret: secret, Events: events, Active: true}
+		if err := a.DB.Create(&hook).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.Created(w, webhookView{Webhook: hook, Secret: secret})
+	})).Methods(http.MethodPost)
+
+	// PATCH /api/projects/{projectID}/webhooks/{webhookID}
+	api.HandleFunc("/projects/{projectID}/webhooks/{webhookID}", middleware.RequireScope(auth.ScopeWebhooksManage, func(w http.ResponseWriter, r *http.Request) {
+		hook, ok := a.ownedWebhook(w, r, auth.RoleAdmin)
+		if !ok {
+			return
+		}
+		var in struct {
+			URL    *string `json:"url"`
+			Events *string `json:"events"`
+			Secret *string `json:"secret"`
+			Active *bool   `json:"active"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			httpx.BadRequest(w, "invalid payload")
+			return
+		}
+		if in.URL != nil {
+			hook.URL = *in.URL
+		}
+		if in.Events != nil {
+			hook.Events = *in.Events
+		}
+		if in.Secret != nil && *in.Secret != "" {
+			hook.Secret = *in.Secret
+		}
+		if in.Active != nil {
+			hook.Active = *in.Active
+		}
+		events, err := normalizeWebhook(hook.URL, hook.Events)
+		if err != nil {
+			httpx.BadRequest(w, err.Error())
+			return
+		}
+		hook.Events = events
+		if err := a.DB.Model(&hook).Select("url", "events", "secret", "active").Updates(&hook).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.OK(w, hook)
+	})).Methods(http.MethodPatch)
+
+	// DELETE /api/projects/{projectID}/webhooks/{webhookID}
+	api.HandleFunc("/projects/{projectID}/webhooks/{webhookID}", middleware.RequireScope(auth.ScopeWebhooksManage, func(w http.ResponseWriter, r *http.Request) {
+		hook, ok := a.ownedWebhook(w, r, auth.RoleAdmin)
+		if !ok {
+			return
+		}
+		err := a.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("webhook_id = ?", hook.ID).Delete(&db.WebhookDelivery{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&hook).Error
+		})
+		if err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.NoContent(w)
+	})).Methods(http.MethodDelete)
+
+	// GET /api/projects/{projectID}/webhooks/{webhookID}/deliveries?status=failed
+	api.HandleFunc("/projects/{projectID}/webhooks/{webhookID}/deliveries", middleware.RequireScope(auth.ScopeWebhooksManage, func(w http.ResponseWriter, r *http.Request) {
+		hook, ok := a.ownedWebhook(w, r, auth.RoleAdmin)
+		if !ok {
+			return
+		}
+		q := a.DB.Where("webhook_id = ?", hook.ID)
+		if st := r.URL.Query().Get("status"); st != "" {
+			q = q.Where("status = ?", st)
+		}
+		var ds []db.WebhookDelivery
+		if err := q.Order("created_at DESC").Limit(100).Find(&ds).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.OK(w, ds)
+	})).Methods(http.MethodGet)
+
+	// POST /api/projects/{projectID}/webhooks/{webhookID}/deliveries/{deliveryID}/redeliver
+	api.HandleFunc("/projects/{projectID}/webhooks/{webhookID}/deliveries/{deliveryID}/redeliver", middleware.RequireScope(auth.ScopeWebhooksManage, func(w http.ResponseWriter, r *http.Request) {
+		hook, ok := a.ownedWebhook(w, r, auth.RoleAdmin)
+		if !ok {
+			return
+		}
+		var d db.WebhookDelivery
+		if err := a.DB.First(&d, "id = ? AND webhook_id = ?", mux.Vars(r)["deliveryID"], hook.ID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				httpx.NotFound(w, "delivery not found")
+				return
+			}
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		now := time.Now()
+		re := db.WebhookDelivery{WebhookID: hook.ID, Event: d.Event, Payload: d.Payload, RedeliveryOf: &d.ID, Status: "pending", NextAttemptAt: &now}
+		if err := a.DB.Create(&re).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.Created(w, re)
+	})).Methods(http.MethodPost)
+}
+
+// normalizeWebhook valide l'URL et la liste d'évènements d'un webhook.
+func normalizeWebhook(rawURL, events string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("invalid url (http or https required)")
+	}
+	// les noms d'hôte sont revérifiés à la connexion (webhookClient)
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if ip, err := netip.ParseAddr(host); (err == nil && !publicAddr(ip)) || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return "", errors.New("invalid url (private or loopback address)")
+	}
+	var out []string
+	for _, ev := range strings.Split(events, ",") {
+		ev = strings.TrimSpace(ev)
+		if ev == "" || slices.Contains(out, ev) {
+			continue
+		}
+		if !slices.Contains(webhookEvents, ev) {
+			return "", fmt.Errorf("unknown event %q (expected one of %s)", ev, strings.Join(webhookEvents, ", "))
+		}
+		out = append(out, ev)
+	}
+	if len(out) == 0 {
+		return "", errors.New("events required")
+	}
+	return strings.Join(out, ","), nil
+}
+
+// emitWebhookEvent enregistre une livraison pour chaque webhook actif du projet abonné à event.
+// Appelée dans la transaction de la modification, la livraison n'existe que si elle est validée.
+func emitWebhookEvent(tx *gorm.DB, projectID, event string, data any) error {
+	var hooks []db.Webhook
+	if err := tx.Where("project_id = ? AND active", projectID).Find(&hooks).Error; err != nil {
+		return err
+	}
+	var payload db.JSON
+	now := time.Now()
+	for _, h := range hooks {
+		if !slices.Contains(strings.Split(h.Events, ","), event) {
+			continue
+		}
+		if payload == nil {
+			var err error
+			if payload, err = db.NewJSON(webhookEnvelope{Event: event, ProjectID: projectID, CreatedAt: now, Data: data}); err != nil {
+				return err
+			}
+		}
+		d := db.WebhookDelivery{WebhookID: h.ID, Event: event, Payload: payload, Status: "pending", NextAttemptAt: &now}
+		if err := tx.Create(&d).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ownedWebhook charge le webhook {webhookID} d'un projet sur lequel l'appelant a au moins le rôle min.
+func (a *API) ownedWebhook(w http.ResponseWriter, r *http.Request, min auth.Role) (db.Webhook, bool) {
+	var hook db.Webhook
+	p, ok := a.ownedProject(w, r, min)
+	if !ok {
+		return hook, false
+	}
+	if err := a.DB.First(&hook, "id = ? AND project_id = ?", mux.Vars(r)["webhookID"], p.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httpx.NotFound(w, "webhook not found")
+			return hook, false
+		}
+		httpx.InternalError(w, err.Error())
+		return hook, false
+	}
+	return hook, true
+}
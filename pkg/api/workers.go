@@ -0,0 +1,404 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/flotio-dev/project-service/pkg/auth"
+	"github.com/flotio-dev/project-service/pkg/db"
+	"github.com/flotio-dev/project-service/pkg/httpx"
+	"github.com/flotio-dev/project-service/pkg/middleware"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// workerScopes sont les scopes accordés à tout worker, limités aux builds qu'il a pris en charge.
+var workerScopes = []string{auth.ScopeBuildsRead, auth.ScopeBuildsWrite}
+
+// workerOnlineWindow : un worker vu depuis moins longtemps est considéré en ligne.
+const workerOnlineWindow = 2 * time.Minute
+
+// maxLogLinesPerRequest borne un envoi de logs.
+const maxLogLinesPerRequest = 1000
+
+type workerView struct {
+	db.Worker
+	Online bool   `json:"online"`
+	Token  string `json:"token,omitempty"` // uniquement à la création
+}
+
+// workerJob est un build pris en charge, avec de quoi récupérer les sources.
+type workerJob struct {
+	Build       db.Build `json:"build"`
+	Repository  *string  `json:"repository,omitempty"` // owner/repo
+	RootDir     *string  `json:"root_dir,omitempty"`
+	GithubToken *string  `json:"github_token,omitempty"`
+}
+
+// mountWorkers monte le registre des workers (côté utilisateurs).
+func (a *API) mountWorkers(api *mux.Router) {
+	// GET /api/workers : les workers de l'appelant, ou tous avec workers:admin
+	api.HandleFunc("/workers", middleware.RequireScope(auth.ScopeWorkersManage, func(w http.ResponseWriter, r *http.Request) {
+		sub, _ := middleware.GetValue[string](r, "sub")
+		q := a.DB.Order("last_seen_at DESC NULLS LAST")
+		if !middleware.HasScope(r, auth.ScopeWorkersAdmin) {
+			q = q.Where("created_by = ?", sub)
+		}
+		var ws []db.Worker
+		if err := q.Find(&ws).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		out := make([]workerView, len(ws))
+		for i, wk := range ws {
+			out[i] = workerView{Worker: wk, Online: wk.RevokedAt == nil && wk.LastSeenAt != nil && time.Since(*wk.LastSeenAt) < workerOnlineWindow}
+		}
+		httpx.OK(w, out)
+	})).Methods(http.MethodGet)
+
+	// POST /api/workers {"name": "mac-mini-1", "platforms": "IOS,MAC"} : crée un token de worker
+	api.HandleFunc("/workers", middleware.RequireScope(auth.ScopeWorkersManage, func(w http.ResponseWriter, r *http.Request) {
+		sub, _ := middleware.GetValue[string](r, "sub")
+		var in struct {
+			Name      string `json:"name"`
+			Platforms string `json:"platforms"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Name == "" {
+			httpx.BadRequest(w, "invalid payload (name required)")
+			return
+		}
+		pls, err := normalizePlatforms(in.Platforms)
+		if err != nil {
+			httpx.BadRequest(w, err.Error())
+			return
+		}
+		token, prefix, hash, err := auth.NewAPIToken(auth.WorkerTokenPrefix)
+		if err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		claims, _ := middleware.GetValue[jwt.MapClaims](r, "claims")
+		var groups db.JSON
+		if g := auth.GroupsClaim(claims); len(g) > 0 {
+			if groups, err = db.NewJSON(g); err != nil {
+				httpx.InternalError(w, err.Error())
+				return
+			}
+		}
+		wk := db.Worker{Name: in.Name, CreatedBy: &sub, CreatorGroups: groups, TokenHash: &hash, TokenPrefix: &prefix, Platforms: pls}
+		if err := a.DB.Create(&wk).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.Created(w, workerView{Worker: wk, Token: token})
+	})).Methods(http.MethodPost)
+
+	// DELETE /api/workers/{workerID} : révocation par son créateur, ou avec workers:admin
+	// (seul moyen de révoquer un worker client Keycloak)
+	api.HandleFunc("/workers/{workerID}", middleware.RequireScope(auth.ScopeWorkersManage, func(w http.ResponseWriter, r *http.Request) {
+		sub, _ := middleware.GetValue[string](r, "sub")
+		q := a.DB.Model(&db.Worker{}).Where("id = ? AND revoked_at IS NULL", mux.Vars(r)["workerID"])
+		if !middleware.HasScope(r, auth.ScopeWorkersAdmin) {
+			q = q.Where("created_by = ?", sub)
+		}
+		res := q.Update("revoked_at", time.Now())
+		if res.Error != nil {
+			httpx.InternalError(w, res.Error.Error())
+			return
+		}
+		if res.RowsAffected == 0 {
+			httpx.NotFound(w, "worker not found")
+			return
+		}
+		httpx.NoContent(w)
+	})).Methods(http.MethodDelete)
+}
+
+// mountWorkerRoutes monte les routes réservées aux workers (/api/worker).
+func (a *API) mountWorkerRoutes(wk *mux.Router) {
+	// POST /api/worker/heartbeat {"platforms": "ANDROID,LINUX", "version": "1.4.0"}
+	wk.HandleFunc("/heartbeat", func(w http.ResponseWriter, r *http.Request) {
+		id, _ := middleware.WorkerID(r)
+		var in struct {
+			Platforms *string `json:"platforms"`
+			Version   *string `json:"version"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			httpx.BadRequest(w, "invalid payload")
+			return
+		}
+		updates := map[string]any{"last_seen_at": time.Now()}
+		if in.Platforms != nil {
+			pls, err := normalizePlatforms(*in.Platforms)
+			if err != nil {
+				httpx.BadRequest(w, err.Error())
+				return
+			}
+			updates["platforms"] = pls
+		}
+		if in.Version != nil {
+			updates["version"] = *in.Version
+		}
+		var wkr db.Worker
+		if err := a.DB.Model(&db.Worker{ID: id}).Updates(updates).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		if err := a.DB.First(&wkr, "id = ?", id).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.OK(w, wkr)
+	}).Methods(http.MethodPost)
+
+	// POST /api/worker/builds/claim {"platforms": "ANDROID"} : 204 si aucun build en attente
+	wk.HandleFunc("/builds/claim", func(w http.ResponseWriter, r *http.Request) {
+		id, _ := middleware.WorkerID(r)
+		var in struct {
+			Platforms string `json:"platforms"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil && !errors.Is(err, io.EOF) {
+			httpx.BadRequest(w, "invalid payload")
+			return
+		}
+		var wkr db.Worker
+		if err := a.DB.First(&wkr, "id = ?", id).Error; err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		pls := in.Platforms
+		if pls == "" {
+			pls = wkr.Platforms
+		}
+		norm, err := normalizePlatforms(pls)
+		if err != nil || norm == "" {
+			httpx.BadRequest(w, "platforms required (in the request or declared by heartbeat)")
+			return
+		}
+		scope, err := a.workerScope(r, wkr)
+		if err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		job, err := a.claimBuild(r.Context(), wkr.ID, scope, splitPlatforms(&norm))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httpx.NoContent(w)
+			return
+		}
+		if err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.OK(w, job)
+	}).Methods(http.MethodPost)
+
+	// POST /api/worker/builds/{buildID}/logs {"lines": ["..."]}
+	wk.HandleFunc("/builds/{buildID}/logs", func(w http.ResponseWriter, r *http.Request) {
+		b, ok := a.ownedBuild(w, r, auth.RoleDeveloper)
+		if !ok {
+			return
+		}
+		var in struct {
+			Lines []string `json:"lines"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || len(in.Lines) == 0 {
+			httpx.BadRequest(w, "invalid payload (lines required)")
+			return
+		}
+		if len(in.Lines) > maxLogLinesPerRequest {
+			httpx.BadRequest(w, "too many lines (max 1000 per request)")
+			return
+		}
+		err := a.DB.Transaction(func(tx *gorm.DB) error {
+			// verrou du build : les numéros de séquence restent contigus entre envois concurrents
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&db.Build{}, "id = ?", b.ID).Error; err != nil {
+				return err
+			}
+			var last int
+			if err := tx.Model(&db.BuildLog{}).Where("build_id = ?", b.ID).Select("COALESCE(MAX(seq), 0)").Scan(&last).Error; err != nil {
+				return err
+			}
+			logs := make([]db.BuildLog, len(in.Lines))
+			for i, line := range in.Lines {
+				logs[i] = db.BuildLog{BuildID: b.ID, Seq: last + i + 1, Line: line}
+			}
+			return tx.Create(&logs).Error
+		})
+		if err != nil {
+			httpx.InternalError(w, err.Error())
+			return
+		}
+		httpx.NoContent(w)
+	}).Methods(http.MethodPost)
+
+	// PATCH /api/worker/builds/{buildID} {"status": "success"}
+	wk.HandleFunc("/builds/{buildID}", a.patchBuild).Methods(http.MethodPatch)
+}
+
+// claimBuild attribue au worker le plus ancien build en attente d'une de ses plateformes,
+// parmi les projets visibles par scope (voir workerScope).
+func (a *API) claimBuild(ctx context.Context, workerID string, scope auth.Identity, platforms []string) (workerJob, error) {
+	var job workerJob
+	err := a.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		q := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND platform IN ?", "pending", platforms).
+			Where("project_id IN (?)", visibleProjects(tx.Model(&db.Project{}).Select("id"), scope, ""))
+		var b db.Build
+		if err := q.Order("created_at ASC").First(&b).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := tx.Model(&b).Updates(map[string]any{"status": "running", "worker_id": workerID, "claimed_at": now}).Error; err != nil {
+			return err
+		}
+		var p db.Project
+		if err := tx.Select("id,github_repo,github_token,root_dir").First(&p, "id = ?", b.ProjectID).Error; err != nil {
+			return err
+		}
+		job = workerJob{Build: b, Repository: p.GithubRepo, RootDir: p.RootDir, GithubToken: p.GithubToken}
+		return nil
+	})
+	return job, err
+}
+
+// workerScope retourne l'identité dont le worker prend les builds, réduite aux groupes où elle est
+// au moins developer : celle de son créateur pour un worker enregistré, celle du compte de service
+// (claim groups du token client credentials) pour un client Keycloak. Le worker reçoit le token
+// GitHub des projets : il ne doit voir que ceux dont cette identité peut lancer les builds.
+func (a *API) workerScope(r *http.Request, wk db.Worker) (auth.Identity, error) {
+	if wk.CreatedBy != nil {
+		return a.workerCreator(wk)
+	}
+	claims, _ := middleware.GetValue[jwt.MapClaims](r, "claims")
+	return developerScope(a.GroupMapping.Identity(claims)), nil
+}
+
+// workerCreator retourne l'identité du créateur d'un worker enregistré, avec ses groupes à la
+// création du worker, réduite aux groupes où il est au moins developer.
+func (a *API) workerCreator(wk db.Worker) (auth.Identity, error) {
+	claims := jwt.MapClaims{"sub": *wk.CreatedBy}
+	if len(wk.CreatorGroups) > 0 {
+		var groups []string
+		if err := json.Unmarshal(wk.CreatorGroups, &groups); err != nil {
+			return auth.Identity{}, err
+		}
+		claims["groups"] = groups
+	}
+	return developerScope(a.GroupMapping.Identity(claims)), nil
+}
+
+// developerScope retire les groupes où id est moins que developer : visibleProjects ne retient
+// alors que les projets dont il peut lancer les builds.
+func developerScope(id auth.Identity) auth.Identity {
+	for g, role := range id.Groups {
+		if role < auth.RoleDeveloper {
+			delete(id.Groups, g)
+		}
+	}
+	return id
+}
+
+// requireWorker identifie le worker appelant : token de worker ou token client credentials
+// d'un client Keycloak déclaré dans WorkerClientIDs. Les autres appelants sont refusés.
+func (a *API) requireWorker(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := middleware.GetValue[jwt.MapClaims](r, "claims")
+		var wk db.Worker
+		var err error
+		switch {
+		case isWorkerToken(r, claims):
+			id, _ := claims["worker_id"].(string)
+			err = a.DB.First(&wk, "id = ?", id).Error
+		case a.workerClientID(claims) != "":
+			wk, err = a.clientWorker(r.Context(), a.workerClientID(claims))
+		default:
+			httpx.Forbidden(w, "worker credentials required")
+			return
+		}
+		if err != nil {
+			httpx.Unauthorized(w, "unknown worker")
+			return
+		}
+		if wk.RevokedAt != nil {
+			httpx.Unauthorized(w, "worker revoked")
+			return
+		}
+		now := time.Now()
+		if wk.LastSeenAt == nil || now.Sub(*wk.LastSeenAt) > 30*time.Second {
+			a.DB.Model(&wk).Update("last_seen_at", now)
+		}
+		next.ServeHTTP(w, middleware.WithWorker(r, wk.ID, workerScopes))
+	})
+}
+
+// isWorkerToken indique si l'appelant s'est authentifié avec un token de worker enregistré. Les
+// claims token_type et worker_id ne sont crues que si validateWorkerToken les a produites : un JWT
+// qui les porterait n'identifie aucun worker.
+func isWorkerToken(r *http.Request, claims jwt.MapClaims) bool {
+	return middleware.FromAPIToken(r) && claims["token_type"] == "worker"
+}
+
+// rejectWorkers refuse les workers sur les routes utilisateur.
+func (a *API) rejectWorkers(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := middleware.GetValue[jwt.MapClaims](r, "claims")
+		if isWorkerToken(r, claims) || a.workerClientID(claims) != "" {
+			httpx.Forbidden(w, "workers may only call /api/worker routes")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// workerTokenPolicy dérive la politique des routes workers : les clients workers sont des azp
+// acceptés et les claims requises (propres aux utilisateurs, ex: email_verified) ne s'appliquent pas.
+func (a *API) workerTokenPolicy() auth.TokenPolicy {
+	pol := a.TokenPolicy
+	if len(pol.AuthorizedParties) > 0 {
+		pol.AuthorizedParties = append(slices.Clone(pol.AuthorizedParties), a.WorkerClientIDs...)
+	}
+	pol.RequiredClaims = nil
+	return pol
+}
+
+// workerClientID retourne le client Keycloak (azp ou client_id) s'il est déclaré comme worker.
+func (a *API) workerClientID(claims jwt.MapClaims) string {
+	for _, k := range []string{"azp", "client_id"} {
+		if c, _ := claims[k].(string); c != "" && slices.Contains(a.WorkerClientIDs, c) {
+			return c
+		}
+	}
+	return ""
+}
+
+// clientWorker retourne le worker d'un client Keycloak, enregistré à sa première requête.
+func (a *API) clientWorker(ctx context.Context, clientID string) (db.Worker, error) {
+	wk := db.Worker{Name: clientID, ClientID: &clientID}
+	if err := a.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&wk).Error; err != nil {
+		return wk, err
+	}
+	err := a.DB.WithContext(ctx).First(&wk, "client_id = ?", clientID).Error
+	return wk, err
+}
+
+// validateWorkerToken authentifie un token de worker enregistré.
+func (a *API) validateWorkerToken(ctx context.Context, token string) (jwt.MapClaims, error) {
+	var wk db.Worker
+	if err := a.DB.WithContext(ctx).First(&wk, "token_hash = ?", auth.HashAPIToken(token)).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invalid token")
+		}
+		return nil, err
+	}
+	if wk.RevokedAt != nil {
+		return nil, errors.New("token revoked")
+	}
+	return jwt.MapClaims{"sub": "worker:" + wk.ID, "token_type": "worker", "worker_id": wk.ID}, nil
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/flotio-dev/project-service/pkg/auth"
+	"github.com/flotio-dev/project-service/pkg/db"
+	"github.com/flotio-dev/project-service/pkg/middleware"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestWorkerCreator(t *testing.T) {
+	a := &API{GroupMapping: auth.GroupMapping{
+		DefaultRole: auth.RoleDeveloper,
+		SubRoles:    map[string]auth.Role{"viewers": auth.RoleViewer, "admins": auth.RoleAdmin},
+	}}
+	sub := "user-1"
+	tests := []struct {
+		name   string
+		groups string
+		want   []string
+	}{
+		{"no groups", "", []string{}},
+		{"member groups", `["/acme","/beta/"]`, []string{"/acme", "/beta"}},
+		{"viewer groups are dropped", `["/acme/viewers","/beta/admins","/gamma"]`, []string{"/beta", "/gamma"}},
+		{"viewer and developer on same group", `["/acme/viewers","/acme"]`, []string{"/acme"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wk := db.Worker{CreatedBy: &sub}
+			if tt.groups != "" {
+				wk.CreatorGroups = db.JSON(tt.groups)
+			}
+			id, err := a.workerCreator(wk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id.Sub != sub {
+				t.Errorf("sub = %q", id.Sub)
+			}
+			if got := id.GroupPaths(); !slices.Equal(got, tt.want) {
+				t.Errorf("groups = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := a.workerCreator(db.Worker{CreatedBy: &sub, CreatorGroups: db.JSON(`{"bad":1}`)}); err == nil {
+		t.Error("malformed creator groups accepted")
+	}
+}
+
+func TestWorkerScopeClient(t *testing.T) {
+	a := &API{GroupMapping: auth.GroupMapping{
+		DefaultRole: auth.RoleDeveloper,
+		SubRoles:    map[string]auth.Role{"viewers": auth.RoleViewer},
+	}}
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   []string
+	}{
+		{"service account without groups sees nothing", jwt.MapClaims{"sub": "svc", "azp": "builder"}, []string{}},
+		{"service account groups", jwt.MapClaims{"sub": "svc", "groups": []any{"/acme", "/beta/viewers"}}, []string{"/acme"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := middleware.WithValue(httptest.NewRequest(http.MethodPost, "/api/worker/builds/claim", nil), "claims", tt.claims)
+			id, err := a.workerScope(r, db.Worker{ClientID: ptr("builder")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := id.GroupPaths(); !slices.Equal(got, tt.want) {
+				t.Errorf("groups = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkerClaimsFromJWT(t *testing.T) {
+	a := &API{}
+	forged := jwt.MapClaims{"sub": "alice", "token_type": "worker", "worker_id": "w1"}
+	tests := []struct {
+		name        string
+		fromAPI     bool
+		wantWorker  bool
+		wantUserAPI int // status de rejectWorkers
+	}{
+		{"jwt carrying worker claims is not a worker", false, false, http.StatusOK},
+		{"worker token validated in database", true, true, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := middleware.WithValue(httptest.NewRequest(http.MethodGet, "/api/projects", nil), "claims", forged)
+			if tt.fromAPI {
+				r = middleware.WithValue(r, "api_token", true)
+			}
+			if got := isWorkerToken(r, forged); got != tt.wantWorker {
+				t.Errorf("isWorkerToken = %v, want %v", got, tt.wantWorker)
+			}
+			w := httptest.NewRecorder()
+			a.rejectWorkers(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, r)
+			if w.Code != tt.wantUserAPI {
+				t.Errorf("rejectWorkers status = %d, want %d", w.Code, tt.wantUserAPI)
+			}
+		})
+	}
+
+	// un JWT forgé n'ouvre pas les routes workers
+	r := middleware.WithValue(httptest.NewRequest(http.MethodPost, "/api/worker/builds/claim", nil), "claims", forged)
+	w := httptest.NewRecorder()
+	a.requireWorker(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("forged worker claims accepted")
+	})).ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("requireWorker status = %d, want 403", w.Code)
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -0,0 +1,257 @@
+// Package appmeta extrait les métadonnées (identifiant, version, icône...) des paquets applicatifs.
+package appmeta
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"howett.net/plist"
+)
+
+// ErrInvalidPackage est retournée quand le fichier n'a pas la structure attendue.
+var ErrInvalidPackage = errors.New("appmeta: invalid package")
+
+// ErrUnsupported est retournée pour les types d'artefacts sans extraction.
+var ErrUnsupported = errors.New("appmeta: unsupported package kind")
+
+// Metadata décrit l'application contenue dans un artefact.
+type Metadata struct {
+	BundleID     string `json:"bundle_id"`
+	Name         string `json:"name,omitempty"`
+	VersionName  string `json:"version_name,omitempty"`   // CFBundleShortVersionString / versionName
+	VersionCode  string `json:"version_code,omitempty"`   // CFBundleVersion / versionCode
+	MinOSVersion string `json:"min_os_version,omitempty"` // MinimumOSVersion / minSdkVersion
+	Icon         []byte `json:"-"`                        // PNG, nil si introuvable
+}
+
+// Parse extrait les métadonnées d'un artefact selon son type (ipa, apk ou aab).
+func Parse(kind string, r io.ReaderAt, size int64) (Metadata, error) {
+	switch kind {
+	case "ipa":
+		return ParseIPA(r, size)
+	case "apk":
+		return ParseAPK(r, size)
+	case "aab":
+		return ParseAAB(r, size)
+	}
+	return Metadata{}, ErrUnsupported
+}
+
+// ParseIPA lit Payload/<App>.app/Info.plist d'une archive IPA.
+func ParseIPA(r io.ReaderAt, size int64) (Metadata, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return Metadata{}, ErrInvalidPackage
+	}
+	f := findInfoPlist(zr)
+	if f == nil {
+		return Metadata{}, ErrInvalidPackage
+	}
+	data, err := readZipFile(f, 4<<20)
+	if err != nil {
+		return Metadata{}, err
+	}
+	type primaryIcon struct {
+		Files []string `plist:"CFBundleIconFiles"`
+	}
+	var info struct {
+		BundleID      string   `plist:"CFBundleIdentifier"`
+		DisplayName   string   `plist:"CFBundleDisplayName"`
+		Name          string   `plist:"CFBundleName"`
+		ShortVersion  string   `plist:"CFBundleShortVersionString"`
+		BundleVersion string   `plist:"CFBundleVersion"`
+		MinimumOS     string   `plist:"MinimumOSVersion"`
+		IconFile      string   `plist:"CFBundleIconFile"`
+		IconFiles     []string `plist:"CFBundleIconFiles"`
+		Icons         struct {
+			Primary primaryIcon `plist:"CFBundlePrimaryIcon"`
+		} `plist:"CFBundleIcons"`
+	}
+	if _, err := plist.Unmarshal(data, &info); err != nil {
+		return Metadata{}, ErrInvalidPackage
+	}
+	m := Metadata{
+		BundleID:     info.BundleID,
+		Name:         info.DisplayName,
+		VersionName:  info.ShortVersion,
+		VersionCode:  info.BundleVersion,
+		MinOSVersion: info.MinimumOS,
+	}
+	if m.Name == "" {
+		m.Name = info.Name
+	}
+	if m.BundleID == "" {
+		return m, ErrInvalidPackage
+	}
+	names := append(append(info.Icons.Primary.Files, info.IconFiles...), "AppIcon")
+	if info.IconFile != "" {
+		names = append(names, info.IconFile)
+	}
+	m.Icon = largestPNG(zr, func(name string) bool {
+		dir, file := path.Split(name)
+		if dir != path.Dir(f.Name)+"/" || !strings.HasSuffix(file, ".png") {
+			return false
+		}
+		for _, n := range names {
+			if n != "" && strings.HasPrefix(file, strings.TrimSuffix(n, ".png")) {
+				return true
+			}
+		}
+		return false
+	})
+	return m, nil
+}
+
+// ParseAPK lit le AndroidManifest.xml binaire d'un APK.
+func ParseAPK(r io.ReaderAt, size int64) (Metadata, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return Metadata{}, ErrInvalidPackage
+	}
+	return parseAndroid(zr, "AndroidManifest.xml", "", parseAXML)
+}
+
+// ParseAAB lit le manifeste protobuf du module base d'un Android App Bundle.
+func ParseAAB(r io.ReaderAt, size int64) (Metadata, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return Metadata{}, ErrInvalidPackage
+	}
+	return parseAndroid(zr, "base/manifest/AndroidManifest.xml", "base/", parseProtoXML)
+}
+
+func parseAndroid(zr *zip.Reader, manifest, prefix string, decode func([]byte) ([]xmlElement, error)) (Metadata, error) {
+	var mf *zip.File
+	for _, f := range zr.File {
+		if f.Name == manifest {
+			mf = f
+			break
+		}
+	}
+	if mf == nil {
+		return Metadata{}, ErrInvalidPackage
+	}
+	data, err := readZipFile(mf, 8<<20)
+	if err != nil {
+		return Metadata{}, err
+	}
+	elems, err := decode(data)
+	if err != nil {
+		return Metadata{}, err
+	}
+	var m Metadata
+	for _, el := range elems {
+		switch el.Name {
+		case "manifest":
+			m.BundleID = el.Attrs["package"]
+			m.VersionCode = el.Attrs["versionCode"]
+			m.VersionName = literal(el.Attrs["versionName"])
+		case "uses-sdk":
+			if m.MinOSVersion == "" {
+				m.MinOSVersion = el.Attrs["minSdkVersion"]
+			}
+		case "application":
+			if m.Name == "" {
+				m.Name = literal(el.Attrs["label"])
+			}
+		}
+	}
+	if m.BundleID == "" {
+		return m, ErrInvalidPackage
+	}
+	m.Icon = androidIcon(zr, prefix)
+	return m, nil
+}
+
+// literal ignore les références de ressources non résolues (@7f0e001b).
+func literal(v string) string {
+	if strings.HasPrefix(v, "@") {
+		return ""
+	}
+	return v
+}
+
+var androidIconRe = regexp.MustCompile(`^res/(?:mipmap|drawable)-(l|m|tv|h|xh|xxh|xxxh)dpi(?:-v\d+)?/ic_launcher(_round)?\.png$`)
+
+// androidIcon choisit ic_launcher.png dans la densité la plus haute (les noms de ressources
+// ne sont pas résolus via resources.arsc, un APK aux ressources obfusquées n'aura pas d'icône).
+func androidIcon(zr *zip.Reader, prefix string) []byte {
+	rank := map[string]int{"l": 1, "m": 2, "tv": 3, "h": 4, "xh": 5, "xxh": 6, "xxxh": 7}
+	var best *zip.File
+	bestScore := 0
+	for _, f := range zr.File {
+		m := androidIconRe.FindStringSubmatch(strings.TrimPrefix(f.Name, prefix))
+		if m == nil || !strings.HasPrefix(f.Name, prefix) {
+			continue
+		}
+		score := rank[m[1]] * 2
+		if m[2] == "" {
+			score++ // préférer l'icône carrée à la ronde
+		}
+		if score > bestScore {
+			best, bestScore = f, score
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	data, err := readZipFile(best, 2<<20)
+	if err != nil {
+		return nil
+	}
+	return normalizePNG(data)
+}
+
+// largestPNG retourne la plus grande PNG acceptée par match, normalisée.
+func largestPNG(zr *zip.Reader, match func(string) bool) []byte {
+	var best *zip.File
+	for _, f := range zr.File {
+		if match(f.Name) && (best == nil || f.UncompressedSize64 > best.UncompressedSize64) {
+			best = f
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	data, err := readZipFile(best, 2<<20)
+	if err != nil {
+		return nil
+	}
+	return normalizePNG(data)
+}
+
+// findInfoPlist cherche Payload/*.app/Info.plist (et non les plists des frameworks embarqués).
+func findInfoPlist(zr *zip.Reader) *zip.File {
+	for _, f := range zr.File {
+		dir, file := path.Split(f.Name)
+		if file != "Info.plist" {
+			continue
+		}
+		parts := strings.Split(strings.Trim(dir, "/"), "/")
+		if len(parts) == 2 && parts[0] == "Payload" && strings.HasSuffix(parts[1], ".app") {
+			return f
+		}
+	}
+	return nil
+}
+
+// readZipFile lit une entrée d'archive en bornant sa taille décompressée.
+func readZipFile(f *zip.File, limit int64) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrInvalidPackage
+	}
+	return data, nil
+}
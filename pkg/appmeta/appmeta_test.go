@@ -0,0 +1,305 @@
+package appmeta
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"unicode/utf16"
+)
+
+// axmlAttr est un attribut à encoder dans un manifeste binaire de test.
+type axmlAttr struct {
+	name     uint32 // index dans le pool de chaînes
+	dataType byte
+	data     uint32
+}
+
+// buildAXML encode un XML binaire Android minimal: pool de chaînes UTF-16, table de ressources et éléments.
+func buildAXML(strs []string, resIDs []uint32, elems map[uint32][]axmlAttr, order []uint32) []byte {
+	le := binary.LittleEndian
+	var pool bytes.Buffer
+	var offsets []uint32
+	for _, s := range strs {
+		offsets = append(offsets, uint32(pool.Len()))
+		u := utf16.Encode([]rune(s))
+		binary.Write(&pool, le, uint16(len(u)))
+		binary.Write(&pool, le, u)
+		binary.Write(&pool, le, uint16(0))
+	}
+	for pool.Len()%4 != 0 {
+		pool.WriteByte(0)
+	}
+	var sp bytes.Buffer
+	start := 28 + 4*len(strs)
+	binary.Write(&sp, le, []uint16{chunkStringPool, 28})
+	binary.Write(&sp, le, []uint32{uint32(start + pool.Len()), uint32(len(strs)), 0, 0, uint32(start), 0})
+	binary.Write(&sp, le, offsets)
+	sp.Write(pool.Bytes())
+
+	var rm bytes.Buffer
+	binary.Write(&rm, le, []uint16{chunkResourceMap, 8})
+	binary.Write(&rm, le, uint32(8+4*len(resIDs)))
+	binary.Write(&rm, le, resIDs)
+
+	var body bytes.Buffer
+	body.Write(sp.Bytes())
+	body.Write(rm.Bytes())
+	for _, name := range order {
+		attrs := elems[name]
+		binary.Write(&body, le, []uint16{chunkStartElement, 16})
+		binary.Write(&body, le, []uint32{uint32(16 + 20 + 20*len(attrs)), 1, 0xffffffff})
+		binary.Write(&body, le, []uint32{0xffffffff, name})
+		binary.Write(&body, le, []uint16{20, 20, uint16(len(attrs)), 0, 0, 0})
+		for _, a := range attrs {
+			raw := uint32(0xffffffff)
+			if a.dataType == typeString {
+				raw = a.data
+			}
+			binary.Write(&body, le, []uint32{0xffffffff, a.name, raw})
+			binary.Write(&body, le, []uint16{8})
+			body.Write([]byte{0, a.dataType})
+			binary.Write(&body, le, a.data)
+		}
+	}
+	var out bytes.Buffer
+	binary.Write(&out, le, []uint16{chunkXML, 8})
+	binary.Write(&out, le, uint32(8+body.Len()))
+	out.Write(body.Bytes())
+	return out.Bytes()
+}
+
+// validAXML encode <manifest package versionCode versionName><uses-sdk minSdkVersion/><application label/>.
+func validAXML() []byte {
+	strs := []string{"versionCode", "versionName", "minSdkVersion", "label", "package", "manifest", "uses-sdk", "application", "dev.flotio.app", "1.2.3", "Flotio"}
+	resIDs := []uint32{0x0101021b, 0x0101021c, 0x0101020c, 0x01010001}
+	elems := map[uint32][]axmlAttr{
+		5: {{4, typeString, 8}, {0, typeIntDec, 42}, {1, typeString, 9}},
+		6: {{2, typeIntDec, 24}},
+		7: {{3, typeString, 10}},
+	}
+	return buildAXML(strs, resIDs, elems, []uint32{5, 6, 7})
+}
+
+// protobuf: encodage minimal des champs délimités et varint.
+func pbBytes(num int, b []byte) []byte {
+	out := binary.AppendUvarint(nil, uint64(num<<3|2))
+	out = binary.AppendUvarint(out, uint64(len(b)))
+	return append(out, b...)
+}
+
+func pbVarint(num int, v uint64) []byte {
+	return binary.AppendUvarint(binary.AppendUvarint(nil, uint64(num<<3)), v)
+}
+
+func pbAttr(name, value string) []byte {
+	return pbBytes(4, append(pbBytes(2, []byte(name)), pbBytes(3, []byte(value))...))
+}
+
+// pbIntAttr encode un attribut compilé (Item.prim.int_decimal_value) identifié par son resource id.
+func pbIntAttr(resID uint32, v uint64) []byte {
+	item := pbBytes(7, pbVarint(6, v))
+	return pbBytes(4, append(pbVarint(5, uint64(resID)), pbBytes(6, item)...))
+}
+
+func pbElement(name string, parts ...[]byte) []byte {
+	el := pbBytes(3, []byte(name))
+	for _, p := range parts {
+		el = append(el, p...)
+	}
+	return pbBytes(1, el)
+}
+
+// validProtoXML encode le même manifeste que validAXML au format aapt2.
+func validProtoXML() []byte {
+	sdk := pbBytes(5, pbElement("uses-sdk", pbIntAttr(0x0101020c, 24)))
+	app := pbBytes(5, pbElement("application", pbAttr("label", "Flotio")))
+	return pbElement("manifest", pbAttr("package", "dev.flotio.app"), pbIntAttr(0x0101021b, 42), pbAttr("versionName", "1.2.3"), sdk, app)
+}
+
+func zipOf(t *testing.T, files map[string][]byte) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestParseAndroid(t *testing.T) {
+	want := Metadata{BundleID: "dev.flotio.app", Name: "Flotio", VersionName: "1.2.3", VersionCode: "42", MinOSVersion: "24"}
+	tests := []struct {
+		kind  string
+		files map[string][]byte
+	}{
+		{"apk", map[string][]byte{"AndroidManifest.xml": validAXML()}},
+		{"aab", map[string][]byte{"base/manifest/AndroidManifest.xml": validProtoXML()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			r := zipOf(t, tt.files)
+			got, err := Parse(tt.kind, r, r.Size())
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got.BundleID != want.BundleID || got.Name != want.Name || got.VersionName != want.VersionName ||
+				got.VersionCode != want.VersionCode || got.MinOSVersion != want.MinOSVersion {
+				t.Errorf("Parse = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseMalformedPackage(t *testing.T) {
+	tests := []struct {
+		name string
+		kind string
+		data func(t *testing.T) *bytes.Reader
+		want error
+	}{
+		{"not a zip", "apk", func(t *testing.T) *bytes.Reader { return bytes.NewReader([]byte("PK\x03\x04garbage")) }, ErrInvalidPackage},
+		{"apk without manifest", "apk", func(t *testing.T) *bytes.Reader { return zipOf(t, map[string][]byte{"classes.dex": {1}}) }, ErrInvalidPackage},
+		{"aab manifest at apk path", "aab", func(t *testing.T) *bytes.Reader {
+			return zipOf(t, map[string][]byte{"AndroidManifest.xml": validProtoXML()})
+		}, ErrInvalidPackage},
+		{"apk with text manifest", "apk", func(t *testing.T) *bytes.Reader {
+			return zipOf(t, map[string][]byte{"AndroidManifest.xml": []byte(`<manifest package="x"/>`)})
+		}, ErrInvalidPackage},
+		{"manifest without package", "aab", func(t *testing.T) *bytes.Reader {
+			return zipOf(t, map[string][]byte{"base/manifest/AndroidManifest.xml": pbElement("manifest", pbAttr("versionName", "1"))})
+		}, ErrInvalidPackage},
+		{"ipa without Info.plist", "ipa", func(t *testing.T) *bytes.Reader {
+			return zipOf(t, map[string][]byte{"Payload/App.app/Frameworks/X.framework/Info.plist": {}})
+		}, ErrInvalidPackage},
+		{"unknown kind", "dmg", func(t *testing.T) *bytes.Reader { return bytes.NewReader(nil) }, ErrUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.data(t)
+			if _, err := Parse(tt.kind, r, r.Size()); !errors.Is(err, tt.want) {
+				t.Errorf("Parse err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAXMLMalformed(t *testing.T) {
+	le := binary.LittleEndian
+	valid := validAXML()
+	mutate := func(f func(b []byte)) []byte {
+		b := bytes.Clone(valid)
+		f(b)
+		return b
+	}
+	// positions des chunks dans validAXML
+	pool := 8
+	resMap := pool + int(le.Uint32(valid[pool+4:]))
+	elem := resMap + int(le.Uint32(valid[resMap+4:]))
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"short header", valid[:6]},
+		{"wrong root type", mutate(func(b []byte) { le.PutUint16(b, chunkStringPool) })},
+		{"chunk larger than file", mutate(func(b []byte) { le.PutUint32(b[pool+4:], uint32(len(valid))) })},
+		{"chunk smaller than header", mutate(func(b []byte) { le.PutUint32(b[elem+4:], 4) })},
+		{"string count overflows chunk", mutate(func(b []byte) { le.PutUint32(b[pool+8:], 1<<30) })},
+		{"string offset past chunk", mutate(func(b []byte) { le.PutUint32(b[pool+28:], 1<<20) })},
+		{"string length past chunk", mutate(func(b []byte) { le.PutUint16(b[pool+int(le.Uint32(b[pool+20:])):], 0x7fff) })},
+		{"utf-16 long length past chunk", mutate(func(b []byte) { le.PutUint16(b[pool+int(le.Uint32(b[pool+20:])):], 0xffff) })},
+		{"utf-8 pool past chunk", mutate(func(b []byte) {
+			le.PutUint32(b[pool+16:], 0x100)
+			b[pool+int(le.Uint32(b[pool+20:]))+1] = 0xff
+		})},
+		{"truncated string pool header", mutate(func(b []byte) { le.PutUint32(b[pool+4:], 16) })},
+		{"element extension past chunk", mutate(func(b []byte) { le.PutUint16(b[elem+2:], 0xfff0) })},
+		{"attribute count past chunk", mutate(func(b []byte) { le.PutUint16(b[elem+16+12:], 500) })},
+		{"attribute start past chunk", mutate(func(b []byte) { le.PutUint16(b[elem+16+8:], 0xffff) })},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseAXML(tt.data); !errors.Is(err, ErrInvalidPackage) {
+				t.Errorf("parseAXML err = %v, want ErrInvalidPackage", err)
+			}
+		})
+	}
+
+	// les index de chaînes hors du pool donnent des valeurs vides, sans panique
+	out := mutate(func(b []byte) { le.PutUint32(b[elem+16+4:], 9999) })
+	elems, err := parseAXML(out)
+	if err != nil || len(elems) == 0 || elems[0].Name != "" {
+		t.Errorf("out of range name: elems = %+v, err = %v", elems, err)
+	}
+}
+
+func TestParseProtoXMLMalformed(t *testing.T) {
+	valid := validProtoXML()
+	deep := pbElement("leaf")
+	for i := 0; i < 70; i++ {
+		deep = pbElement("n", pbBytes(5, deep))
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated varint key", []byte{0x80}},
+		{"truncated varint value", []byte{0x08, 0x80}},
+		{"length past end", []byte{0x0a, 0x10, 'a'}},
+		{"huge length", append([]byte{0x0a}, binary.AppendUvarint(nil, 1<<62)...)},
+		{"truncated fixed64", []byte{0x09, 1, 2, 3}},
+		{"truncated fixed32", []byte{0x0d, 1, 2}},
+		{"group wire type", []byte{0x0b}},
+		{"invalid element body", pbBytes(1, []byte{0x1a, 0x05, 'x'})},
+		{"invalid attribute", pbElement("manifest", pbBytes(4, []byte{0x12, 0x7f}))},
+		{"invalid child", pbElement("manifest", pbBytes(5, []byte{0x0a, 0x7f}))},
+		{"too deep", deep},
+		{"truncated document", valid[:len(valid)-3]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseProtoXML(tt.data); !errors.Is(err, ErrInvalidPackage) {
+				t.Errorf("parseProtoXML err = %v, want ErrInvalidPackage", err)
+			}
+		})
+	}
+}
+
+// TestTruncatedManifests vérifie qu'aucune troncature d'un manifeste valide ne provoque de panique.
+func TestTruncatedManifests(t *testing.T) {
+	decoders := map[string]struct {
+		data   []byte
+		decode func([]byte) ([]xmlElement, error)
+	}{
+		"axml":  {validAXML(), parseAXML},
+		"proto": {validProtoXML(), parseProtoXML},
+	}
+	for name, d := range decoders {
+		t.Run(name, func(t *testing.T) {
+			for n := 0; n < len(d.data); n++ {
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							t.Fatalf("panic on %d bytes: %v", n, r)
+						}
+					}()
+					d.decode(d.data[:n])
+					// octet corrompu à la position n
+					b := bytes.Clone(d.data)
+					b[n] ^= 0xff
+					d.decode(b)
+				}()
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package appmeta
+
+import (
+	"encoding/binary"
+	"strconv"
+	"unicode/utf16"
+)
+
+// Types de chunks du format XML binaire d'Android (AndroidManifest.xml dans un APK).
+const (
+	chunkStringPool   = 0x0001
+	chunkXML          = 0x0003
+	chunkResourceMap  = 0x0180
+	chunkStartElement = 0x0102
+)
+
+// Types de valeurs typées des attributs.
+const (
+	typeReference = 0x01
+	typeString    = 0x03
+	typeIntDec    = 0x10
+	typeIntHex    = 0x11
+	typeBoolean   = 0x12
+)
+
+// Identifiants des attributs android:* utilisés quand les noms ont été obfusqués.
+var androidAttrIDs = map[uint32]string{
+	0x01010001: "label",
+	0x01010002: "icon",
+	0x0101020c: "minSdkVersion",
+	0x0101021b: "versionCode",
+	0x0101021c: "versionName",
+}
+
+// xmlElement est un élément de manifeste réduit à son nom et à ses attributs textuels.
+type xmlElement struct {
+	Name  string
+	Attrs map[string]string
+}
+
+// parseAXML décode un XML binaire Android et retourne ses éléments dans l'ordre du document.
+func parseAXML(data []byte) ([]xmlElement, error) {
+	if len(data) < 8 || binary.LittleEndian.Uint16(data) != chunkXML {
+		return nil, ErrInvalidPackage
+	}
+	var (
+		strings []string
+		resMap  []uint32
+		elems   []xmlElement
+	)
+	str := func(i uint32) string {
+		if int(i) < len(strings) {
+			return strings[i]
+		}
+		return ""
+	}
+	off := int(binary.LittleEndian.Uint16(data[2:]))
+	for off+8 <= len(data) {
+		typ := binary.LittleEndian.Uint16(data[off:])
+		hdr := int(binary.LittleEndian.Uint16(data[off+2:]))
+		size := int(binary.LittleEndian.Uint32(data[off+4:]))
+		if size < 8 || off+size > len(data) {
+			return nil, ErrInvalidPackage
+		}
+		chunk := data[off : off+size]
+		switch typ {
+		case chunkStringPool:
+			var err error
+			if strings, err = parseStringPool(chunk); err != nil {
+				return nil, err
+			}
+		case chunkResourceMap:
+			for i := hdr; i+4 <= size; i += 4 {
+				resMap = append(resMap, binary.LittleEndian.Uint32(chunk[i:]))
+			}
+		case chunkStartElement:
+			if hdr+20 > size {
+				return nil, ErrInvalidPackage
+			}
+			ext := chunk[hdr:]
+			name := str(binary.LittleEndian.Uint32(ext[4:]))
+			attrStart := int(binary.LittleEndian.Uint16(ext[8:]))
+			attrSize := int(binary.LittleEndian.Uint16(ext[10:]))
+			attrCount := int(binary.LittleEndian.Uint16(ext[12:]))
+			el := xmlElement{Name: name, Attrs: map[string]string{}}
+			for i := 0; i < attrCount; i++ {
+				a := hdr + attrStart + i*attrSize
+				if a+20 > size {
+					return nil, ErrInvalidPackage
+				}
+				nameIdx := binary.LittleEndian.Uint32(chunk[a+4:])
+				attrName := str(nameIdx)
+				if int(nameIdx) < len(resMap) {
+					if n, ok := androidAttrIDs[resMap[nameIdx]]; ok {
+						attrName = n
+					}
+				}
+				raw := binary.LittleEndian.Uint32(chunk[a+8:])
+				dataType := chunk[a+15]
+				value := binary.LittleEndian.Uint32(chunk[a+16:])
+				switch dataType {
+				case typeString:
+					el.Attrs[attrName] = str(value)
+				case typeIntDec, typeIntHex:
+					el.Attrs[attrName] = strconv.FormatInt(int64(int32(value)), 10)
+				case typeBoolean:
+					el.Attrs[attrName] = strconv.FormatBool(value != 0)
+				case typeReference:
+					el.Attrs[attrName] = "@" + strconv.FormatUint(uint64(value), 16)
+				default:
+					if raw != 0xffffffff {
+						el.Attrs[attrName] = str(raw)
+					}
+				}
+			}
+			elems = append(elems, el)
+		}
+		off += size
+	}
+	return elems, nil
+}
+
+// parseStringPool décode un chunk ResStringPool (UTF-8 ou UTF-16).
+func parseStringPool(chunk []byte) ([]string, error) {
+	if len(chunk) < 28 {
+		return nil, ErrInvalidPackage
+	}
+	count := int(binary.LittleEndian.Uint32(chunk[8:]))
+	flags := binary.LittleEndian.Uint32(chunk[16:])
+	start := int(binary.LittleEndian.Uint32(chunk[20:]))
+	hdr := int(binary.LittleEndian.Uint16(chunk[2:]))
+	utf8 := flags&0x100 != 0
+	if hdr+count*4 > len(chunk) || start > len(chunk) {
+		return nil, ErrInvalidPackage
+	}
+	out := make([]string, count)
+	for i := 0; i < count; i++ {
+		p := start + int(binary.LittleEndian.Uint32(chunk[hdr+i*4:]))
+		if p >= len(chunk) {
+			return nil, ErrInvalidPackage
+		}
+		if utf8 {
+			// longueur en caractères puis en octets, chacune sur 1 ou 2 octets
+			_, n := axmlLen8(chunk, p)
+			p += n
+			l, n := axmlLen8(chunk, p)
+			p += n
+			if p+l > len(chunk) {
+				return nil, ErrInvalidPackage
+			}
+			out[i] = string(chunk[p : p+l])
+			continue
+		}
+		if p+2 > len(chunk) {
+			return nil, ErrInvalidPackage
+		}
+		l := int(binary.LittleEndian.Uint16(chunk[p:]))
+		p += 2
+		if l&0x8000 != 0 {
+			if p+2 > len(chunk) {
+				return nil, ErrInvalidPackage
+			}
+			l = (l&0x7fff)<<16 | int(binary.LittleEndian.Uint16(chunk[p:]))
+			p += 2
+		}
+		if p+l*2 > len(chunk) {
+			return nil, ErrInvalidPackage
+		}
+		u := make([]uint16, l)
+		for j := range u {
+			u[j] = binary.LittleEndian.Uint16(chunk[p+j*2:])
+		}
+		out[i] = string(utf16.Decode(u))
+	}
+	return out, nil
+}
+
+func axmlLen8(b []byte, p int) (int, int) {
+	if p >= len(b) {
+		return 0, 1
+	}
+	if b[p]&0x80 != 0 && p+1 < len(b) {
+		return int(b[p]&0x7f)<<8 | int(b[p+1]), 2
+	}
+	return int(b[p]), 1
+}
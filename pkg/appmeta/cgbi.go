@@ -0,0 +1,95 @@
+package appmeta
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// normalizePNG convertit une PNG "CgBI" (optimisée par Xcode, illisible hors d'iOS) en PNG standard.
+// Les PNG ordinaires sont retournées telles quelles; nil si le format n'est pas supporté.
+// Les pixels restent en alpha prémultiplié, ce qui n'assombrit que les bords semi-transparents.
+func normalizePNG(data []byte) []byte {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil
+	}
+	var (
+		cgbi bool
+		ihdr []byte
+		idat bytes.Buffer
+	)
+	for p := len(pngSignature); p+12 <= len(data); {
+		l := int(binary.BigEndian.Uint32(data[p:]))
+		if l < 0 || p+12+l > len(data) {
+			return nil
+		}
+		typ := string(data[p+4 : p+8])
+		body := data[p+8 : p+8+l]
+		switch typ {
+		case "CgBI":
+			cgbi = true
+		case "IHDR":
+			ihdr = body
+		case "IDAT":
+			idat.Write(body)
+		}
+		p += 12 + l
+	}
+	if !cgbi {
+		return data
+	}
+	// seul le RGBA 8 bits non entrelacé est produit par Xcode
+	if len(ihdr) != 13 || ihdr[8] != 8 || ihdr[9] != 6 || ihdr[12] != 0 {
+		return nil
+	}
+	width := int(binary.BigEndian.Uint32(ihdr[0:]))
+	height := int(binary.BigEndian.Uint32(ihdr[4:]))
+	stride := 1 + width*4
+	if width <= 0 || height <= 0 || width > 4096 || height > 4096 {
+		return nil
+	}
+	raw, err := io.ReadAll(io.LimitReader(flate.NewReader(&idat), int64(stride*height)+1))
+	if err != nil || len(raw) != stride*height {
+		return nil
+	}
+	// BGRA -> RGBA: les filtres PNG travaillent canal par canal, l'échange reste valide sur les données filtrées
+	for y := 0; y < height; y++ {
+		row := raw[y*stride+1 : (y+1)*stride]
+		for x := 0; x+3 < len(row); x += 4 {
+			row[x], row[x+2] = row[x+2], row[x]
+		}
+	}
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	if _, err := zw.Write(raw); err != nil {
+		return nil
+	}
+	if err := zw.Close(); err != nil {
+		return nil
+	}
+	var out bytes.Buffer
+	out.Write(pngSignature)
+	writePNGChunk(&out, "IHDR", ihdr)
+	writePNGChunk(&out, "IDAT", z.Bytes())
+	writePNGChunk(&out, "IEND", nil)
+	return out.Bytes()
+}
+
+func writePNGChunk(w *bytes.Buffer, typ string, body []byte) {
+	var hdr [8]byte
+	binary.BigEndian.PutUint32(hdr[:], uint32(len(body)))
+	copy(hdr[4:], typ)
+	w.Write(hdr[:])
+	w.Write(body)
+	crc := crc32.NewIEEE()
+	crc.Write(hdr[4:])
+	crc.Write(body)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	w.Write(sum[:])
+}
@@ -0,0 +1,169 @@
+package appmeta
+
+import (
+	"encoding/binary"
+	"strconv"
+)
+
+// Un AAB stocke ses manifestes au format protobuf aapt.pb.XmlNode (Resources.proto d'aapt2).
+// Seuls les champs utiles à l'extraction des métadonnées sont décodés.
+
+type pbField struct {
+	num    int
+	varint uint64
+	bytes  []byte
+}
+
+// pbDecode découpe un message protobuf en champs (types varint, 64 bits, délimité, 32 bits).
+func pbDecode(b []byte) ([]pbField, error) {
+	var out []pbField
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, ErrInvalidPackage
+		}
+		b = b[n:]
+		f := pbField{num: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				return nil, ErrInvalidPackage
+			}
+			f.varint, b = v, b[n:]
+		case 1:
+			if len(b) < 8 {
+				return nil, ErrInvalidPackage
+			}
+			f.varint, b = binary.LittleEndian.Uint64(b), b[8:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return nil, ErrInvalidPackage
+			}
+			f.bytes, b = b[n:n+int(l)], b[n+int(l):]
+		case 5:
+			if len(b) < 4 {
+				return nil, ErrInvalidPackage
+			}
+			f.varint, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		default:
+			return nil, ErrInvalidPackage
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+// parseProtoXML retourne les éléments d'un XmlNode dans l'ordre du document.
+func parseProtoXML(data []byte) ([]xmlElement, error) {
+	var elems []xmlElement
+	var walk func(node []byte, depth int) error
+	walk = func(node []byte, depth int) error {
+		if depth > 64 {
+			return ErrInvalidPackage
+		}
+		fields, err := pbDecode(node)
+		if err != nil {
+			return err
+		}
+		for _, f := range fields {
+			if f.num != 1 { // XmlNode.element
+				continue
+			}
+			ef, err := pbDecode(f.bytes)
+			if err != nil {
+				return err
+			}
+			el := xmlElement{Attrs: map[string]string{}}
+			var children [][]byte
+			for _, e := range ef {
+				switch e.num {
+				case 3: // XmlElement.name
+					el.Name = string(e.bytes)
+				case 4: // XmlElement.attribute
+					name, value, err := protoAttr(e.bytes)
+					if err != nil {
+						return err
+					}
+					el.Attrs[name] = value
+				case 5: // XmlElement.child
+					children = append(children, e.bytes)
+				}
+			}
+			elems = append(elems, el)
+			for _, c := range children {
+				if err := walk(c, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(data, 0); err != nil {
+		return nil, err
+	}
+	return elems, nil
+}
+
+// protoAttr décode un XmlAttribute: nom, valeur textuelle ou primitive compilée.
+func protoAttr(b []byte) (string, string, error) {
+	fields, err := pbDecode(b)
+	if err != nil {
+		return "", "", err
+	}
+	var (
+		name, value string
+		resID       uint32
+		item        []byte
+	)
+	for _, f := range fields {
+		switch f.num {
+		case 2:
+			name = string(f.bytes)
+		case 3:
+			value = string(f.bytes)
+		case 5:
+			resID = uint32(f.varint)
+		case 6:
+			item = f.bytes
+		}
+	}
+	if n, ok := androidAttrIDs[resID]; ok && name == "" {
+		name = n
+	}
+	if value == "" && item != nil {
+		value = protoItemValue(item)
+	}
+	return name, value, nil
+}
+
+// protoItemValue lit Item.prim (entiers, booléens) ou Item.ref.
+func protoItemValue(b []byte) string {
+	fields, err := pbDecode(b)
+	if err != nil {
+		return ""
+	}
+	for _, f := range fields {
+		switch f.num {
+		case 1: // ref
+			rf, _ := pbDecode(f.bytes)
+			for _, r := range rf {
+				if r.num == 2 {
+					return "@" + strconv.FormatUint(r.varint, 16)
+				}
+			}
+		case 7: // prim
+			pf, _ := pbDecode(f.bytes)
+			for _, p := range pf {
+				switch p.num {
+				case 6, 7: // int_decimal_value, int_hexadecimal_value
+					return strconv.FormatInt(int64(int32(p.varint)), 10)
+				case 8: // boolean_value
+					return strconv.FormatBool(p.varint != 0)
+				}
+			}
+		}
+	}
+	return ""
+}
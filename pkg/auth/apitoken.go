@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Préfixes des tokens opaques : un bearer sans l'un d'eux est traité comme un JWT.
+const (
+	APITokenPrefix    = "flt_" // tokens personnels
+	WorkerTokenPrefix = "flw_" // tokens des workers de build
+)
+
+// NewAPIToken génère un token opaque avec le préfixe donné. Seuls son hash et son préfixe
+// d'affichage sont stockés.
+func NewAPIToken(prefix string) (token, displayPrefix, hash string, err error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", "", err
+	}
+	token = prefix + hex.EncodeToString(raw)
+	return token, token[:len(prefix)+8], HashAPIToken(token), nil
+}
+
+// HashAPIToken retourne l'empreinte SHA-256 stockée d'un token d'API.
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIToken indique si le bearer est un token opaque (personnel ou worker).
+func IsAPIToken(s string) bool {
+	return strings.HasPrefix(s, APITokenPrefix) || strings.HasPrefix(s, WorkerTokenPrefix)
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// DevIssuer est un émetteur de tokens intégré pour le développement local. Sa clé
+// ES256 est générée au démarrage : les tokens ne survivent pas à un redémarrage.
+type DevIssuer struct {
+	URL string
+	kid string
+	key *ecdsa.PrivateKey
+
+	mu      sync.Mutex
+	revoked map[string]bool // jti révoqués
+}
+
+func NewDevIssuer(url string) (*DevIssuer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	kid := make([]byte, 8)
+	if _, err := rand.Read(kid); err != nil {
+		return nil, err
+	}
+	return &DevIssuer{URL: strings.TrimRight(url, "/"), kid: "dev-" + hex.EncodeToString(kid), key: key, revoked: map[string]bool{}}, nil
+}
+
+// JWKS retourne le document JWKS public de l'émetteur.
+func (d *DevIssuer) JWKS() map[string]any {
+	size := (d.key.Curve.Params().BitSize + 7) / 8
+	b := base64.RawURLEncoding.EncodeToString
+	return map[string]any{"keys": []map[string]any{{
+		"kty": "EC",
+		"kid": d.kid,
+		"use": "sig",
+		"alg": "ES256",
+		"crv": "P-256",
+		"x":   b(d.key.X.FillBytes(make([]byte, size))),
+		"y":   b(d.key.Y.FillBytes(make([]byte, size))),
+	}}}
+}
+
+// Mint signe un token pour claims ; iss, jti, iat et exp sont fixés par l'émetteur.
+func (d *DevIssuer) Mint(claims jwt.MapClaims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	claims["jti"] = hex.EncodeToString(jti)
+	claims["iss"] = d.URL
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
+	tk := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	tk.Header["kid"] = d.kid
+	return tk.SignedString(d.key)
+}
+
+// TrustDevIssuer ajoute l'émetteur de développement aux issuers de confiance, avec sa
+// clé en mémoire (aucun téléchargement de JWKS). À appeler avant Run.
+func (p *JWKSProvider) TrustDevIssuer(d *DevIssuer) {
+	p.issuers[d.URL] = &keySet{
+		issuer: Issuer{URL: d.URL},
+		keys:   map[string]publicKey{d.kid: {key: &d.key.PublicKey, alg: "ES256"}},
+		loaded: true,
+		static: true,
+	}
+}
+
+// Introspect joue le rôle d'un endpoint RFC 7662 : actif si le token a été émis ici,
+// n'a pas expiré et n'a pas été révoqué.
+func (d *DevIssuer) Introspect(token string) Introspection {
+	claims, ok := d.parse(token)
+	if !ok {
+		return Introspection{}
+	}
+	jti, _ := claims["jti"].(string)
+	d.mu.Lock()
+	revoked := d.revoked[jti]
+	d.mu.Unlock()
+	if revoked {
+		return Introspection{}
+	}
+	res := Introspection{Active: true}
+	res.Sub, _ = claims["sub"].(string)
+	res.Username, _ = claims["preferred_username"].(string)
+	if groups := GroupsClaim(claims); groups != nil {
+		res.Groups = groups
+	} else {
+		res.Groups = []string{}
+	}
+	if exp, ok := numericClaim(claims, "exp"); ok {
+		res.Exp = exp
+	}
+	return res
+}
+
+// Revoke révoque un token émis ici (sans effet sur les autres tokens).
+func (d *DevIssuer) Revoke(token string) {
+	if claims, ok := d.parse(token); ok {
+		if jti, _ := claims["jti"].(string); jti != "" {
+			d.mu.Lock()
+			d.revoked[jti] = true
+			d.mu.Unlock()
+		}
+	}
+}
+
+func (d *DevIssuer) parse(token string) (jwt.MapClaims, bool) {
+	parser := &jwt.Parser{ValidMethods: []string{"ES256"}}
+	var claims jwt.MapClaims
+	t, err := parser.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return &d.key.PublicKey, nil
+	})
+	return claims, err == nil && t.Valid
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// openIDConfiguration est le sous-ensemble utile du document de découverte OIDC.
+type openIDConfiguration struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// discoverJWKSURL lit {issuer}/.well-known/openid-configuration et retourne jwks_uri.
+// Le document doit annoncer le même issuer (OpenID Connect Discovery §4.3).
+func discoverJWKSURL(ctx context.Context, client *http.Client, issuer string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("openid discovery failed: %s", resp.Status)
+	}
+	var c openIDConfiguration
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJWKSSize)).Decode(&c); err != nil {
+		return "", err
+	}
+	if strings.TrimRight(c.Issuer, "/") != strings.TrimRight(issuer, "/") {
+		return "", fmt.Errorf("openid discovery: issuer mismatch %q", c.Issuer)
+	}
+	if c.JWKSURI == "" {
+		return "", errors.New("openid discovery: missing jwks_uri")
+	}
+	return c.JWKSURI, nil
+}
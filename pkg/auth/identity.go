@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Role est le rôle d'un utilisateur sur un projet, du moins au plus privilégié.
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleViewer
+	RoleDeveloper
+	RoleAdmin
+)
+
+var roleNames = map[Role]string{RoleViewer: "viewer", RoleDeveloper: "developer", RoleAdmin: "admin"}
+
+func (r Role) String() string { return roleNames[r] }
+
+// ParseRole lit un nom de rôle (viewer, developer, admin).
+func ParseRole(s string) (Role, error) {
+	for r, name := range roleNames {
+		if strings.EqualFold(strings.TrimSpace(s), name) {
+			return r, nil
+		}
+	}
+	return RoleNone, fmt.Errorf("unknown role %q (expected viewer, developer or admin)", s)
+}
+
+// NormalizeGroupPath ramène un groupe Keycloak à un chemin canonique : "/parent/child",
+// sans slash final ni segment vide. Un nom nu ("team") devient "/team". Retourne "" si vide.
+func NormalizeGroupPath(g string) string {
+	g = strings.TrimSpace(g)
+	if strings.Trim(g, "/") == "" {
+		return ""
+	}
+	return path.Clean("/" + g)
+}
+
+// GroupMapping convertit les groupes des claims en rôles. Un sous-groupe dont le nom figure
+// dans SubRoles (ex: "/org/team/admins" avec admins=admin) donne ce rôle sur le groupe parent ;
+// tout autre groupe donne DefaultRole.
+type GroupMapping struct {
+	SubRoles    map[string]Role
+	DefaultRole Role
+}
+
+// ParseGroupRoles lit une liste "sous-groupe=rôle" séparée par des virgules.
+func ParseGroupRoles(s string) (map[string]Role, error) {
+	out := map[string]Role{}
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e == "" {
+			continue
+		}
+		name, role, ok := strings.Cut(e, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid group role mapping %q (expected subgroup=role)", e)
+		}
+		r, err := ParseRole(role)
+		if err != nil {
+			return nil, err
+		}
+		out[strings.TrimSpace(name)] = r
+	}
+	return out, nil
+}
+
+// Identity est l'appelant authentifié : son sub et le rôle obtenu sur chaque groupe.
+type Identity struct {
+	Sub    string
+	Groups map[string]Role // chemin normalisé → rôle le plus élevé
+}
+
+// GroupsClaim retourne les valeurs brutes du claim "groups" (tableau ou chaîne).
+func GroupsClaim(claims jwt.MapClaims) []string {
+	var raw []string
+	switch v := claims["groups"].(type) {
+	case []any:
+		for _, it := range v {
+			if s, ok := it.(string); ok {
+				raw = append(raw, s)
+			}
+		}
+	case []string:
+		raw = v
+	case string:
+		raw = []string{v}
+	}
+	return raw
+}
+
+// Identity extrait l'identité des claims (claim "groups", tableau ou chaîne).
+func (m GroupMapping) Identity(claims jwt.MapClaims) Identity {
+	id := Identity{Groups: map[string]Role{}}
+	id.Sub, _ = claims["sub"].(string)
+	for _, g := range GroupsClaim(claims) {
+		g = NormalizeGroupPath(g)
+		if g == "" {
+			continue
+		}
+		role := m.DefaultRole
+		if r, ok := m.SubRoles[path.Base(g)]; ok && path.Dir(g) != "/" {
+			g, role = path.Dir(g), r
+		}
+		if role > id.Groups[g] {
+			id.Groups[g] = role
+		}
+	}
+	return id
+}
+
+// GroupPaths retourne les groupes de l'appelant, triés.
+func (id Identity) GroupPaths() []string {
+	out := make([]string, 0, len(id.Groups))
+	for g := range id.Groups {
+		out = append(out, g)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// GroupRole retourne le rôle sur un groupe, hérité des groupes ancêtres
+// (un membre de /org a accès aux projets de /org/team).
+func (id Identity) GroupRole(group string) Role {
+	best := RoleNone
+	for g := NormalizeGroupPath(group); g != "" && g != "/"; g = path.Dir(g) {
+		best = max(best, id.Groups[g])
+	}
+	return best
+}
+
+// ProjectRole retourne le rôle sur un projet : admin pour son propriétaire, sinon celui
+// obtenu via son groupe.
+func (id Identity) ProjectRole(ownerSub string, groupID *string) Role {
+	if id.Sub != "" && ownerSub == id.Sub {
+		return RoleAdmin
+	}
+	if groupID == nil {
+		return RoleNone
+	}
+	return id.GroupRole(*groupID)
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxIntrospectionCache borne le nombre de tokens actifs gardés en cache.
+const maxIntrospectionCache = 10000
+
+// Introspection est la réponse d'un endpoint RFC 7662.
+type Introspection struct {
+	Active   bool   `json:"active"`
+	Sub      string `json:"sub,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	Username string `json:"username,omitempty"`
+	Exp      int64  `json:"exp,omitempty"`
+	// Groups vaut nil si l'issuer ne renvoie pas de claim "groups" (mapper non configuré),
+	// et une liste vide si l'appelant n'appartient plus à aucun groupe.
+	Groups []string `json:"groups"`
+}
+
+// Introspector vérifie auprès de l'issuer (RFC 7662) qu'un token n'a pas été révoqué.
+// Seules les réponses actives sont mises en cache, au plus CacheTTL et jamais au-delà de exp.
+type Introspector struct {
+	URL          string
+	ClientID     string
+	ClientSecret string
+	CacheTTL     time.Duration
+
+	client *http.Client
+	mu     sync.Mutex
+	cache  map[[32]byte]cachedIntrospection
+}
+
+type cachedIntrospection struct {
+	result    Introspection
+	expiresAt time.Time
+}
+
+func NewIntrospector(endpoint, clientID, clientSecret string, cacheTTL time.Duration) *Introspector {
+	return &Introspector{
+		URL:          endpoint,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		CacheTTL:     cacheTTL,
+		client:       &http.Client{Timeout: fetchTimeout},
+		cache:        make(map[[32]byte]cachedIntrospection),
+	}
+}
+
+// Introspect retourne l'état du token. Une erreur signifie que l'état est inconnu (issuer injoignable).
+func (in *Introspector) Introspect(ctx context.Context, token string) (Introspection, error) {
+	key := sha256.Sum256([]byte(token))
+	now := time.Now()
+	in.mu.Lock()
+	if c, ok := in.cache[key]; ok && now.Before(c.expiresAt) {
+		in.mu.Unlock()
+		return c.result, nil
+	}
+	in.mu.Unlock()
+
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, in.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Introspection{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if in.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(in.ClientID), url.QueryEscape(in.ClientSecret))
+	}
+	resp, err := in.client.Do(req)
+	if err != nil {
+		return Introspection{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Introspection{}, fmt.Errorf("introspection failed: %s", resp.Status)
+	}
+	var res Introspection
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&res); err != nil {
+		return Introspection{}, err
+	}
+	expiresAt := now.Add(in.CacheTTL)
+	if res.Exp != 0 && time.Unix(res.Exp, 0).Before(expiresAt) {
+		expiresAt = time.Unix(res.Exp, 0)
+	}
+	if !res.Active || !now.Before(expiresAt) {
+		return res, nil
+	}
+	in.mu.Lock()
+	if len(in.cache) >= maxIntrospectionCache {
+		for k, c := range in.cache {
+			if !now.Before(c.expiresAt) {
+				delete(in.cache, k)
+			}
+		}
+		if len(in.cache) >= maxIntrospectionCache {
+			clear(in.cache)
+		}
+	}
+	in.cache[key] = cachedIntrospection{result: res, expiresAt: expiresAt}
+	in.mu.Unlock()
+	return res, nil
+}
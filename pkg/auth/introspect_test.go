@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestIntrospect(t *testing.T) {
+	future := time.Now().Add(time.Hour).Unix()
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantErr    bool
+		wantActive bool
+		wantGroups []string
+		wantHits   int32 // requêtes reçues par l'issuer pour deux appels
+	}{
+		{"active is cached", http.StatusOK, fmt.Sprintf(`{"active":true,"sub":"alice","exp":%d,"groups":["/acme"]}`, future), false, true, []string{"/acme"}, 1},
+		{"active without groups", http.StatusOK, `{"active":true,"sub":"alice"}`, false, true, nil, 1},
+		{"active with no groups left", http.StatusOK, `{"active":true,"sub":"alice","groups":[]}`, false, true, []string{}, 1},
+		{"inactive is not cached", http.StatusOK, `{"active":false}`, false, false, nil, 2},
+		{"already expired is not cached", http.StatusOK, `{"active":true,"exp":1}`, false, true, nil, 2},
+		{"server error", http.StatusInternalServerError, `{"active":true}`, true, false, nil, 2},
+		{"unauthorized client", http.StatusUnauthorized, `{"error":"invalid_client"}`, true, false, nil, 2},
+		{"malformed body", http.StatusOK, `{"active":`, true, false, nil, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				// identifiants encodés en form-urlencoded (RFC 6749 §2.3.1)
+				if user, pass, ok := r.BasicAuth(); !ok || user != "svc" || pass != "s3cr%25t" {
+					t.Errorf("basic auth = %q, %q, %v", user, pass, ok)
+				}
+				if got := r.PostFormValue("token"); got != "tok" {
+					t.Errorf("token = %q", got)
+				}
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+
+			in := NewIntrospector(srv.URL, "svc", "s3cr%t", time.Minute)
+			for i := range 2 {
+				res, err := in.Introspect(context.Background(), "tok")
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("call %d: err = %v, wantErr %v", i, err, tt.wantErr)
+				}
+				if res.Active != tt.wantActive {
+					t.Errorf("call %d: active = %v", i, res.Active)
+				}
+				if !slices.Equal(res.Groups, tt.wantGroups) || (res.Groups == nil) != (tt.wantGroups == nil) {
+					t.Errorf("call %d: groups = %#v, want %#v", i, res.Groups, tt.wantGroups)
+				}
+			}
+			if got := hits.Load(); got != tt.wantHits {
+				t.Errorf("issuer hit %d times, want %d", got, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestIntrospectCacheExpiry(t *testing.T) {
+	exp := time.Now().Add(10 * time.Second).Unix()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"active":true,"exp":%d}`, exp)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		max  time.Time
+	}{
+		{"capped at exp", time.Hour, time.Unix(exp, 0)},
+		{"capped at ttl", time.Second, time.Now().Add(2 * time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := NewIntrospector(srv.URL, "", "", tt.ttl)
+			if _, err := in.Introspect(context.Background(), "tok"); err != nil {
+				t.Fatal(err)
+			}
+			c, ok := in.cache[sha256.Sum256([]byte("tok"))]
+			if !ok {
+				t.Fatal("active result not cached")
+			}
+			if c.expiresAt.After(tt.max) {
+				t.Errorf("cached until %s, want at most %s", c.expiresAt, tt.max)
+			}
+			// une entrée expirée n'est plus servie
+			in.cache[sha256.Sum256([]byte("tok"))] = cachedIntrospection{result: Introspection{Active: false}, expiresAt: time.Now()}
+			if res, err := in.Introspect(context.Background(), "tok"); err != nil || !res.Active {
+				t.Errorf("expired entry served: %+v, %v", res, err)
+			}
+		})
+	}
+}
+
+func TestDevIssuerIntrospect(t *testing.T) {
+	d, err := NewDevIssuer("http://localhost:8080/dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		groups any
+		want   []string
+	}{
+		{"groups", []string{"/acme"}, []string{"/acme"}},
+		{"no groups", nil, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := jwt.MapClaims{"sub": "alice"}
+			if tt.groups != nil {
+				claims["groups"] = tt.groups
+			}
+			tok, err := d.Mint(claims, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res := d.Introspect(tok)
+			if !res.Active || res.Sub != "alice" {
+				t.Fatalf("introspection = %+v", res)
+			}
+			if res.Groups == nil || !slices.Equal(res.Groups, tt.want) {
+				t.Errorf("groups = %#v, want %#v", res.Groups, tt.want)
+			}
+			d.Revoke(tok)
+			if d.Introspect(tok).Active {
+				t.Error("revoked token still active")
+			}
+		})
+	}
+}
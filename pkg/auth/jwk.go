@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// jwk est une clé publique d'un document JWKS (RFC 7517).
+type jwk struct {
+	Kty string   `json:"kty"`
+	Kid string   `json:"kid"`
+	Use string   `json:"use"`
+	Alg string   `json:"alg"`
+	N   string   `json:"n"`   // RSA
+	E   string   `json:"e"`   // RSA
+	Crv string   `json:"crv"` // EC, OKP
+	X   string   `json:"x"`   // EC, OKP
+	Y   string   `json:"y"`   // EC
+	X5c []string `json:"x5c"` // chaîne de certificats (base64 standard, DER)
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// publicKey est une clé de vérification avec l'algorithme éventuellement imposé par le JWKS.
+type publicKey struct {
+	key crypto.PublicKey
+	alg string
+}
+
+// signingMethods sont les algorithmes acceptés (jamais HS* ni none).
+var signingMethods = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+// parseJWK convertit une clé JWKS. Si x5c est présent, la chaîne doit être cohérente
+// (chaque certificat signé par le suivant) et le certificat feuille porter la même clé.
+func parseJWK(k jwk) (publicKey, error) {
+	var key crypto.PublicKey
+	var err error
+	switch k.Kty {
+	case "RSA":
+		if k.N != "" && k.E != "" {
+			key, err = rsaKey(k.N, k.E)
+		}
+	case "EC":
+		if k.X != "" && k.Y != "" {
+			key, err = ecKey(k.Crv, k.X, k.Y)
+		}
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return publicKey{}, fmt.Errorf("unsupported OKP curve %q", k.Crv)
+		}
+		var x []byte
+		if x, err = base64.RawURLEncoding.DecodeString(k.X); err == nil && len(x) != ed25519.PublicKeySize {
+			err = errors.New("invalid Ed25519 key size")
+		}
+		key = ed25519.PublicKey(x)
+	default:
+		return publicKey{}, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+	if err != nil {
+		return publicKey{}, err
+	}
+	if len(k.X5c) > 0 {
+		leaf, err := verifyX5c(k.X5c)
+		if err != nil {
+			return publicKey{}, err
+		}
+		if key == nil {
+			key = leaf
+		} else if eq, ok := key.(interface{ Equal(crypto.PublicKey) bool }); !ok || !eq.Equal(leaf) {
+			return publicKey{}, errors.New("x5c certificate does not match key parameters")
+		}
+	}
+	if key == nil {
+		return publicKey{}, errors.New("missing key parameters")
+	}
+	return publicKey{key: key, alg: k.Alg}, nil
+}
+
+func rsaKey(nB64, eB64 string) (*rsa.PublicKey, error) {
+	nBytes, err := jwt.DecodeSegment(nB64)
+	if err != nil {
+		return nil, err
+	}
+	eBytes, err := jwt.DecodeSegment(eB64)
+	if err != nil {
+		return nil, err
+	}
+	e := 0
+	for _, b := range eBytes {
+		e = e<<8 + int(b)
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nBytes), E: e}, nil
+}
+
+func ecKey(crv, xB64, yB64 string) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	var check ecdh.Curve
+	switch crv {
+	case "P-256":
+		curve, check = elliptic.P256(), ecdh.P256()
+	case "P-384":
+		curve, check = elliptic.P384(), ecdh.P384()
+	case "P-521":
+		curve, check = elliptic.P521(), ecdh.P521()
+	default:
+		return nil, fmt.Errorf("unsupported EC curve %q", crv)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(xB64)
+	if err != nil {
+		return nil, err
+	}
+	y, err := base64.RawURLEncoding.DecodeString(yB64)
+	if err != nil {
+		return nil, err
+	}
+	size := (curve.Params().BitSize + 7) / 8
+	if len(x) != size || len(y) != size {
+		return nil, errors.New("invalid EC coordinates size")
+	}
+	// le point doit appartenir à la courbe
+	if _, err := check.NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+		return nil, err
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+}
+
+// verifyX5c vérifie la cohérence d'une chaîne x5c et retourne la clé du certificat feuille.
+func verifyX5c(chain []string) (crypto.PublicKey, error) {
+	certs := make([]*x509.Certificate, len(chain))
+	for i, c := range chain {
+		der, err := base64.StdEncoding.DecodeString(c)
+		if err != nil {
+			return nil, err
+		}
+		if certs[i], err = x509.ParseCertificate(der); err != nil {
+			return nil, err
+		}
+	}
+	for i := 0; i+1 < len(certs); i++ {
+		if err := certs[i].CheckSignatureFrom(certs[i+1]); err != nil {
+			return nil, fmt.Errorf("x5c chain: %w", err)
+		}
+	}
+	return certs[0].PublicKey, nil
+}
+
+// compatible vérifie que l'algorithme du token correspond à la clé (type, courbe, alg imposé).
+func (k publicKey) compatible(alg string) bool {
+	if k.alg != "" && k.alg != alg {
+		return false
+	}
+	switch key := k.key.(type) {
+	case *rsa.PublicKey:
+		switch alg {
+		case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512":
+			return true
+		}
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return alg == "ES256"
+		case elliptic.P384():
+			return alg == "ES384"
+		case elliptic.P521():
+			return alg == "ES512"
+		}
+	case ed25519.PublicKey:
+		return alg == "EdDSA"
+	}
+	return false
+}
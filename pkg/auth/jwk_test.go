@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// newCert crée un certificat pour pub, signé par parent/parentKey (auto-signé si parent est nil).
+func newCert(t *testing.T, cn string, pub crypto.PublicKey, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, string) {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  parent == nil,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	if parent == nil {
+		parent = tmpl
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, pub, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, base64.StdEncoding.EncodeToString(der)
+}
+
+func TestParseJWK(t *testing.T) {
+	p256, p384 := newECKey(t, elliptic.P256()), newECKey(t, elliptic.P384())
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	// chaîne feuille (P-256) <- CA ; une seconde CA n'a rien signé
+	ca, caB64 := newCert(t, "ca", &p384.PublicKey, nil, p384)
+	otherCAKey := newECKey(t, elliptic.P384())
+	_, otherCAB64 := newCert(t, "other ca", &otherCAKey.PublicKey, nil, otherCAKey)
+	_, leafB64 := newCert(t, "leaf", &p256.PublicKey, ca, p384)
+	_, rsaLeafB64 := newCert(t, "rsa leaf", &rsaPriv.PublicKey, ca, p384)
+
+	offCurve := ecJWK("", p256)
+	offCurve.Y = offCurve.X
+
+	tests := []struct {
+		name    string
+		jwk     jwk
+		want    crypto.PublicKey
+		wantErr bool
+	}{
+		{"EC P-256", ecJWK("k", p256), &p256.PublicKey, false},
+		{"EC P-384", ecJWK("k", p384), &p384.PublicKey, false},
+		{"EC point off curve", offCurve, nil, true},
+		{"EC unknown curve", jwk{Kty: "EC", Crv: "secp256k1", X: ecJWK("", p256).X, Y: ecJWK("", p256).Y}, nil, true},
+		{"EC coordinates of another curve", jwk{Kty: "EC", Crv: "P-384", X: ecJWK("", p256).X, Y: ecJWK("", p256).Y}, nil, true},
+		{"EdDSA", jwk{Kty: "OKP", Crv: "Ed25519", X: b64(edPub)}, edPub, false},
+		{"EdDSA short key", jwk{Kty: "OKP", Crv: "Ed25519", X: b64(edPub[:16])}, nil, true},
+		{"OKP X25519", jwk{Kty: "OKP", Crv: "X25519", X: b64(edPub)}, nil, true},
+		{"RSA", jwk{Kty: "RSA", N: b64(rsaPriv.N.Bytes()), E: "AQAB"}, &rsaPriv.PublicKey, false},
+		{"x5c only", jwk{Kty: "EC", X5c: []string{leafB64, caB64}}, &p256.PublicKey, false},
+		{"x5c matching parameters", func() jwk {
+			k := ecJWK("k", p256)
+			k.X5c = []string{leafB64, caB64}
+			return k
+		}(), &p256.PublicKey, false},
+		{"x5c RSA leaf", jwk{Kty: "RSA", N: b64(rsaPriv.N.Bytes()), E: "AQAB", X5c: []string{rsaLeafB64, caB64}}, &rsaPriv.PublicKey, false},
+		{"x5c of another key", func() jwk {
+			k := ecJWK("k", p384)
+			k.X5c = []string{leafB64, caB64}
+			return k
+		}(), nil, true},
+		{"x5c bad chain", jwk{Kty: "EC", X5c: []string{leafB64, otherCAB64}}, nil, true},
+		{"x5c not base64", jwk{Kty: "EC", X5c: []string{"%%%"}}, nil, true},
+		{"x5c not a certificate", jwk{Kty: "EC", X5c: []string{base64.StdEncoding.EncodeToString([]byte("nope"))}}, nil, true},
+		{"missing parameters", jwk{Kty: "EC"}, nil, true},
+		{"unknown key type", jwk{Kty: "oct"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseJWK(tt.jwk)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseJWK() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.want != nil && !tt.want.(interface{ Equal(crypto.PublicKey) bool }).Equal(got.key) {
+				t.Errorf("parseJWK() key = %T %v, want %v", got.key, got.key, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublicKeyCompatible(t *testing.T) {
+	p256, p521 := newECKey(t, elliptic.P256()), newECKey(t, elliptic.P521())
+	edPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		key  publicKey
+		alg  string
+		want bool
+	}{
+		{"P-256 ES256", publicKey{key: &p256.PublicKey}, "ES256", true},
+		{"P-256 ES384 curve mismatch", publicKey{key: &p256.PublicKey}, "ES384", false},
+		{"P-521 ES512", publicKey{key: &p521.PublicKey}, "ES512", true},
+		{"P-521 ES256 curve mismatch", publicKey{key: &p521.PublicKey}, "ES256", false},
+		{"Ed25519 EdDSA", publicKey{key: edPub}, "EdDSA", true},
+		{"Ed25519 ES256", publicKey{key: edPub}, "ES256", false},
+		{"RSA PS256", publicKey{key: &rsaPriv.PublicKey}, "PS256", true},
+		{"RSA ES256", publicKey{key: &rsaPriv.PublicKey}, "ES256", false},
+		{"alg pinned by JWKS", publicKey{key: &rsaPriv.PublicKey, alg: "RS256"}, "RS512", false},
+		{"EC with RSA alg", publicKey{key: &p256.PublicKey}, "RS256", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.key.compatible(tt.alg); got != tt.want {
+				t.Errorf("compatible(%s) = %v, want %v", tt.alg, got, tt.want)
+			}
+		})
+	}
+}
+
+// newIssuer sert le document de découverte OIDC (annonçant issuer, ou sa propre URL si vide) et un JWKS.
+func newIssuer(t *testing.T, issuer string, keys ...jwk) *httptest.Server {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	if issuer == "" {
+		issuer = srv.URL
+	}
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(openIDConfiguration{Issuer: issuer, JWKSURI: srv.URL + "/certs"})
+	})
+	mux.HandleFunc("/certs", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jwks{Keys: keys})
+	})
+	return srv
+}
+
+func TestMultiIssuerDiscovery(t *testing.T) {
+	ecPriv := newECKey(t, elliptic.P256())
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec := newIssuer(t, "", ecJWK("ec1", ecPriv))
+	ed := newIssuer(t, "", jwk{Kty: "OKP", Crv: "Ed25519", Kid: "ed1", X: base64.RawURLEncoding.EncodeToString(edPub)})
+	liar := newIssuer(t, "https://idp.example", ecJWK("ec1", ecPriv))
+	p := NewJWKSProvider([]Issuer{{URL: ec.URL}, {URL: ed.URL + "/"}, {URL: liar.URL}})
+
+	signEdDSA := func(iss, kid string) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{"iss": iss, "sub": "u1", "exp": time.Now().Add(time.Minute).Unix()})
+		tok.Header["kid"] = kid
+		s, err := tok.SignedString(edPriv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"EC issuer", signES256(t, ec.URL, "ec1", ecPriv), ""},
+		{"EdDSA issuer", signEdDSA(ed.URL, "ed1"), ""},
+		{"issuer with trailing slash", signEdDSA(ed.URL+"/", "ed1"), ""},
+		{"key of another issuer", signES256(t, ed.URL, "ec1", ecPriv), ErrCodeUnknownKey},
+		{"unknown issuer", signES256(t, "https://evil.example", "ec1", ecPriv), ErrCodeUntrustedIssuer},
+		{"discovery announces another issuer", signES256(t, liar.URL, "ec1", ecPriv), ErrCodeUnknownKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := p.ValidateToken(context.Background(), tt.token, TokenPolicy{})
+			assertCode(t, err, tt.want)
+		})
+	}
+}
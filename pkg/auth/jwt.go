@@ -2,152 +2,322 @@ package auth
 
 import (
 	"context"
-	"crypto/rsa"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"math/big"
+	"io"
+	"log"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/sync/singleflight"
 )
 
-// Simple JWKS cache
-type jwksKey struct {
-	Kty string `json:"kty"`
-	Kid string `json:"kid"`
-	N   string `json:"n"`
-	E   string `json:"e"`
+// Issuer est un émetteur de tokens de confiance. Sans JWKSURL, l'URL des clés
+// est découverte via {URL}/.well-known/openid-configuration.
+type Issuer struct {
+	URL     string
+	JWKSURL string
 }
 
-type jwks struct {
-	Keys []jwksKey `json:"keys"`
+// Paramètres du cache JWKS.
+const (
+	defaultKeysTTL  = 10 * time.Minute // sans Cache-Control max-age
+	minKeysTTL      = time.Minute
+	maxKeysTTL      = 24 * time.Hour
+	kidMissInterval = 30 * time.Second // intervalle minimal entre deux rafraîchissements sur kid inconnu
+	fetchTimeout    = 10 * time.Second
+	maxJWKSSize     = 1 << 20
+)
+
+// keySet est le cache JWKS d'un issuer. Les clés restent servies après expiration
+// tant qu'aucun rafraîchissement n'a abouti (Keycloak indisponible).
+type keySet struct {
+	issuer      Issuer
+	mu          sync.RWMutex
+	jwksURL     string
+	keys        map[string]publicKey
+	anonymous   []publicKey // clés sans kid
+	loaded      bool
+	static      bool      // clés fournies en mémoire, jamais rafraîchies
+	refreshAt   time.Time // rafraîchissement anticipé, au 4/5 de la durée de cache
+	lastAttempt time.Time
+	pending     chan struct{} // fermé à la fin du rafraîchissement en cours, nil sinon
 }
 
+// JWKSProvider valide les JWT émis par un ou plusieurs issuers de confiance.
 type JWKSProvider struct {
-	url       string
-	mu        sync.RWMutex
-	keys      map[string]*rsa.PublicKey
-	expiresAt time.Time
-	ttl       time.Duration
-	issuer    string
+	issuers map[string]*keySet
+	client  *http.Client
+	group   singleflight.Group
+}
+
+func NewJWKSProvider(issuers []Issuer) *JWKSProvider {
+	p := &JWKSProvider{
+		issuers: make(map[string]*keySet),
+		client: &http.Client{
+			Timeout: fetchTimeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 3 {
+					return errors.New("too many redirects")
+				}
+				return nil
+			},
+		},
+	}
+	for _, iss := range issuers {
+		iss.URL = strings.TrimRight(iss.URL, "/")
+		p.issuers[iss.URL] = &keySet{issuer: iss, jwksURL: iss.JWKSURL, keys: make(map[string]publicKey)}
+	}
+	return p
 }
 
-func NewJWKSProvider(url, issuer string) *JWKSProvider {
-	return &JWKSProvider{url: url, keys: make(map[string]*rsa.PublicKey), ttl: 10 * time.Minute, issuer: issuer}
+// Issuers retourne les URLs des issuers de confiance.
+func (p *JWKSProvider) Issuers() []string {
+	out := make([]string, 0, len(p.issuers))
+	for iss := range p.issuers {
+		out = append(out, iss)
+	}
+	return out
+}
+
+// Run rafraîchit les clés en arrière-plan avant leur expiration, jusqu'à l'annulation de ctx.
+func (p *JWKSProvider) Run(ctx context.Context) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	for {
+		for _, ks := range p.issuers {
+			if ks.static {
+				continue
+			}
+			ks.mu.RLock()
+			// sans marteler un issuer en échec
+			due := !ks.loaded || time.Now().After(ks.refreshAt)
+			recent := time.Since(ks.lastAttempt) < kidMissInterval
+			ks.mu.RUnlock()
+			if due && !recent {
+				if err := p.refresh(ctx, ks); err != nil {
+					log.Printf("jwks refresh for %s failed, serving cached keys: %v", ks.issuer.URL, err)
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
-func (p *JWKSProvider) getKey(kid string) (*rsa.PublicKey, error) {
-	p.mu.RLock()
-	if k, ok := p.keys[kid]; ok && time.Now().Before(p.expiresAt) {
-		p.mu.RUnlock()
+func (p *JWKSProvider) getKey(ctx context.Context, ks *keySet, kid, alg string) (publicKey, error) {
+	if k, ok := ks.lookup(kid, alg); ok {
 		return k, nil
 	}
-	p.mu.RUnlock()
-	if err := p.refresh(context.Background()); err != nil {
-		return nil, err
+	// kid inconnu : un seul rafraîchissement par intervalle, quel que soit le nombre de tokens
+	ks.mu.RLock()
+	throttled := ks.static || time.Since(ks.lastAttempt) < kidMissInterval
+	pending := ks.pending
+	ks.mu.RUnlock()
+	switch {
+	case !throttled:
+		if err := p.refresh(ctx, ks); err != nil {
+			log.Printf("jwks refresh for %s failed: %v", ks.issuer.URL, err)
+		}
+	case pending != nil:
+		// rafraîchissement en cours (premier chargement, rotation) : on attend son résultat
+		select {
+		case <-pending:
+		case <-ctx.Done():
+		}
 	}
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-	if k, ok := p.keys[kid]; ok {
+	// relu même sans rafraîchissement : un appel concurrent a pu charger la clé entre-temps
+	if k, ok := ks.lookup(kid, alg); ok {
 		return k, nil
 	}
-	return nil, fmt.Errorf("key %s not found in JWKS", kid)
+	if kid == "" {
+		return publicKey{}, fmt.Errorf("no unique %s key without kid in JWKS", alg)
+	}
+	return publicKey{}, fmt.Errorf("key %s not found in JWKS", kid)
 }
 
-func (p *JWKSProvider) refresh(ctx context.Context) error {
-	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
+// lookup cherche la clé par kid ; sans kid, seule une clé compatible unique est acceptée.
+func (ks *keySet) lookup(kid, alg string) (publicKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if kid != "" {
+		k, ok := ks.keys[kid]
+		return k, ok
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("jwks fetch failed: %s", resp.Status)
+	var found []publicKey
+	for _, k := range ks.anonymous {
+		if k.compatible(alg) {
+			found = append(found, k)
+		}
 	}
-	var j jwks
-	if err := json.NewDecoder(resp.Body).Decode(&j); err != nil {
-		return err
+	for _, k := range ks.keys {
+		if k.compatible(alg) {
+			found = append(found, k)
+		}
 	}
-	keys := make(map[string]*rsa.PublicKey)
-	for _, k := range j.Keys {
-		if k.Kty != "RSA" || k.N == "" || k.E == "" || k.Kid == "" {
-			continue
+	if len(found) != 1 {
+		return publicKey{}, false
+	}
+	return found[0], true
+}
+
+// refresh recharge le JWKS d'un issuer ; les appels concurrents partagent la même requête.
+// En cas d'échec, les clés en cache sont conservées. La requête partagée garde les valeurs de ctx
+// mais pas son annulation : un appelant annulé cesse seulement de l'attendre.
+func (p *JWKSProvider) refresh(ctx context.Context, ks *keySet) error {
+	ch := p.group.DoChan(ks.issuer.URL, func() (any, error) {
+		ks.mu.Lock()
+		ks.lastAttempt = time.Now()
+		ks.pending = make(chan struct{})
+		url := ks.jwksURL
+		ks.mu.Unlock()
+		defer func() {
+			ks.mu.Lock()
+			close(ks.pending)
+			ks.pending = nil
+			ks.mu.Unlock()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), fetchTimeout)
+		defer cancel()
+		if url == "" {
+			var err error
+			if url, err = discoverJWKSURL(ctx, p.client, ks.issuer.URL); err != nil {
+				return nil, err
+			}
 		}
-		pub, err := parseRSAPublicKeyFromModExp(k.N, k.E)
+		j, ttl, err := p.fetchJWKS(ctx, url)
 		if err != nil {
-			continue
+			return nil, err
 		}
-		keys[k.Kid] = pub
+		keys := make(map[string]publicKey)
+		var anonymous []publicKey
+		for _, k := range j.Keys {
+			if k.Use != "" && k.Use != "sig" {
+				continue
+			}
+			pub, err := parseJWK(k)
+			if err != nil {
+				continue
+			}
+			if k.Kid == "" {
+				anonymous = append(anonymous, pub)
+			} else {
+				keys[k.Kid] = pub
+			}
+		}
+		ks.mu.Lock()
+		ks.jwksURL = url
+		ks.keys = keys
+		ks.anonymous = anonymous
+		ks.loaded = true
+		ks.refreshAt = time.Now().Add(ttl / 5 * 4)
+		ks.mu.Unlock()
+		return nil, nil
+	})
+	select {
+	case res := <-ch:
+		return res.Err
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	p.mu.Lock()
-	p.keys = keys
-	p.expiresAt = time.Now().Add(p.ttl)
-	p.mu.Unlock()
-	return nil
 }
 
-func parseRSAPublicKeyFromModExp(nB64URL, eB64URL string) (*rsa.PublicKey, error) {
-	nBytes, err := jwt.DecodeSegment(nB64URL)
+// fetchJWKS télécharge un JWKS et retourne sa durée de cache (Cache-Control max-age).
+func (p *JWKSProvider) fetchJWKS(ctx context.Context, url string) (jwks, time.Duration, error) {
+	var j jwks
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, err
+		return j, 0, err
 	}
-	eBytes, err := jwt.DecodeSegment(eB64URL)
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
 	if err != nil {
-		return nil, err
+		return j, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return j, 0, fmt.Errorf("jwks fetch failed: %s", resp.Status)
 	}
-	n := new(big.Int).SetBytes(nBytes)
-	e := 0
-	for _, b := range eBytes {
-		e = e<<8 + int(b)
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJWKSSize)).Decode(&j); err != nil {
+		return j, 0, err
 	}
-	return &rsa.PublicKey{N: n, E: e}, nil
+	return j, cacheTTL(resp.Header.Get("Cache-Control")), nil
 }
 
-// ValidateToken parse et valide un JWT signé par Keycloak via JWKS.
-func (p *JWKSProvider) ValidateToken(tokenStr string, audience string) (*jwt.Token, jwt.MapClaims, error) {
+// cacheTTL lit max-age, borné entre minKeysTTL et maxKeysTTL.
+func cacheTTL(cacheControl string) time.Duration {
+	ttl := defaultKeysTTL
+	for _, d := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+		if strings.EqualFold(name, "max-age") {
+			if secs, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil {
+				ttl = time.Duration(secs) * time.Second
+			}
+		}
+	}
+	return min(max(ttl, minKeysTTL), maxKeysTTL)
+}
+
+// ValidateToken parse et valide un JWT signé par l'un des issuers de confiance, puis
+// applique la politique de claims. ctx borne le rechargement du JWKS sur kid inconnu.
+// Les erreurs sont des *TokenError.
+func (p *JWKSProvider) ValidateToken(ctx context.Context, tokenStr string, policy TokenPolicy) (*jwt.Token, jwt.MapClaims, error) {
 	if tokenStr == "" {
-		return nil, nil, errors.New("empty token")
+		return nil, nil, tokenErr(ErrCodeMalformed, "empty token")
 	}
-	parser := &jwt.Parser{ValidMethods: []string{jwt.SigningMethodRS256.Name}}
+	// les claims temporelles sont vérifiées par la politique, avec sa tolérance d'horloge
+	parser := &jwt.Parser{SkipClaimsValidation: true}
 	var claims jwt.MapClaims
 	token, err := parser.ParseWithClaims(tokenStr, &claims, func(t *jwt.Token) (interface{}, error) {
+		alg := t.Method.Alg()
+		if !slices.Contains(signingMethods, alg) {
+			return nil, tokenErr(ErrCodeUnsupportedAlg, "signing method %s is not allowed", alg)
+		}
+		// l'issuer (non encore vérifié) sélectionne le jeu de clés : une signature
+		// valide prouve ensuite qu'il n'a pas été falsifié
+		iss, _ := claims["iss"].(string)
+		ks, ok := p.issuers[strings.TrimRight(iss, "/")]
+		if !ok {
+			return nil, tokenErr(ErrCodeUntrustedIssuer, "untrusted issuer %q", iss)
+		}
 		kid, _ := t.Header["kid"].(string)
-		if kid == "" {
-			return nil, errors.New("missing kid")
+		k, err := p.getKey(ctx, ks, kid, alg)
+		if err != nil {
+			return nil, tokenErr(ErrCodeUnknownKey, "%s", err.Error())
+		}
+		if !k.compatible(alg) {
+			return nil, tokenErr(ErrCodeUnsupportedAlg, "key %s cannot verify %s", kid, alg)
 		}
-		return p.getKey(kid)
+		return k.key, nil
 	})
-	if err != nil || !token.Valid {
-		return nil, nil, errors.New("invalid token")
-	}
-	// issuer
-	if iss, _ := claims["iss"].(string); p.issuer != "" && iss != p.issuer {
-		return nil, nil, errors.New("invalid issuer")
-	}
-	// aud (optional)
-	if audience != "" {
-		switch aud := claims["aud"].(type) {
-		case string:
-			if aud != audience {
-				return nil, nil, errors.New("invalid audience")
-			}
-		case []any:
-			ok := false
-			for _, v := range aud {
-				if s, _ := v.(string); s == audience {
-					ok = true
-					break
-				}
-			}
-			if !ok {
-				return nil, nil, errors.New("invalid audience")
-			}
+	if err != nil {
+		var te *TokenError
+		var ve *jwt.ValidationError
+		switch {
+		case errors.As(err, &te):
+			return nil, nil, te
+		case errors.As(err, &ve) && ve.Errors&jwt.ValidationErrorMalformed != 0:
+			return nil, nil, tokenErr(ErrCodeMalformed, "malformed token")
+		default:
+			return nil, nil, tokenErr(ErrCodeInvalidSignature, "invalid signature")
 		}
 	}
+	if !token.Valid {
+		return nil, nil, tokenErr(ErrCodeInvalidSignature, "invalid signature")
+	}
+	if err := policy.Check(claims, time.Now()); err != nil {
+		return nil, nil, err
+	}
 	return token, claims, nil
 }
 
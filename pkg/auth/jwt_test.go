@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// jwksServer sert un JWKS modifiable et compte les requêtes reçues.
+type jwksServer struct {
+	*httptest.Server
+	hits atomic.Int32
+
+	mu     sync.Mutex
+	keys   []jwk
+	status int
+	delay  time.Duration
+}
+
+func newJWKSServer(t *testing.T, keys ...jwk) *jwksServer {
+	s := &jwksServer{keys: keys, status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.hits.Add(1)
+		s.mu.Lock()
+		keys, status, delay := s.keys, s.status, s.delay
+		s.mu.Unlock()
+		time.Sleep(delay)
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=60")
+		_ = json.NewEncoder(w).Encode(jwks{Keys: keys})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) set(status int, delay time.Duration, keys ...jwk) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status, s.delay = status, delay
+	if keys != nil {
+		s.keys = keys
+	}
+}
+
+// ecJWK retourne la clé publique EC de key au format JWKS.
+func ecJWK(kid string, key *ecdsa.PrivateKey) jwk {
+	size := (key.Curve.Params().BitSize + 7) / 8
+	return jwk{
+		Kty: "EC", Kid: kid, Use: "sig",
+		Crv: key.Curve.Params().Name,
+		X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size))),
+		Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size))),
+	}
+}
+
+func newECKey(t *testing.T, curve elliptic.Curve) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// signES256 signe un token de l'issuer iss avec la clé kid.
+func signES256(t *testing.T, iss, kid string, key *ecdsa.PrivateKey) string {
+	tok := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{"iss": iss, "sub": "u1", "exp": time.Now().Add(time.Minute).Unix()})
+	tok.Header["kid"] = kid
+	s, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestJWKSSingleflight(t *testing.T) {
+	key := newECKey(t, elliptic.P256())
+	srv := newJWKSServer(t, ecJWK("k1", key))
+	srv.set(http.StatusOK, 100*time.Millisecond)
+	p := NewJWKSProvider([]Issuer{{URL: "https://idp.example", JWKSURL: srv.URL}})
+	token := signES256(t, "https://idp.example", "k1", key)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := p.ValidateToken(context.Background(), token, TokenPolicy{})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("ValidateToken() error = %v", err)
+		}
+	}
+	if got := srv.hits.Load(); got != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", got)
+	}
+}
+
+func TestJWKSKidMissThrottle(t *testing.T) {
+	k1, k2 := newECKey(t, elliptic.P256()), newECKey(t, elliptic.P256())
+	srv := newJWKSServer(t, ecJWK("k1", k1))
+	p := NewJWKSProvider([]Issuer{{URL: "https://idp.example", JWKSURL: srv.URL}})
+	ks := p.issuers["https://idp.example"]
+	validate := func(kid string, key *ecdsa.PrivateKey) error {
+		_, _, err := p.ValidateToken(context.Background(), signES256(t, "https://idp.example", kid, key), TokenPolicy{})
+		return err
+	}
+
+	if err := validate("k1", k1); err != nil {
+		t.Fatal(err)
+	}
+	// rotation côté issuer juste après le chargement : le kid inconnu ne déclenche pas de requête
+	srv.set(http.StatusOK, 0, ecJWK("k1", k1), ecJWK("k2", k2))
+	for range 5 {
+		assertCode(t, validate("k2", k2), ErrCodeUnknownKey)
+	}
+	if got := srv.hits.Load(); got != 1 {
+		t.Fatalf("JWKS fetched %d times within the kid-miss interval, want 1", got)
+	}
+
+	// l'intervalle écoulé, un kid inconnu recharge le JWKS une fois
+	ks.mu.Lock()
+	ks.lastAttempt = time.Now().Add(-kidMissInterval - time.Second)
+	ks.mu.Unlock()
+	if err := validate("k2", k2); err != nil {
+		t.Fatalf("rotated key: %v", err)
+	}
+	assertCode(t, validate("k3", k2), ErrCodeUnknownKey)
+	if got := srv.hits.Load(); got != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", got)
+	}
+}
+
+func TestJWKSBackgroundRefresh(t *testing.T) {
+	k1, k2 := newECKey(t, elliptic.P256()), newECKey(t, elliptic.P256())
+	srv := newJWKSServer(t, ecJWK("k1", k1))
+	p := NewJWKSProvider([]Issuer{{URL: "https://idp.example", JWKSURL: srv.URL}})
+	ks := p.issuers["https://idp.example"]
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+	// Run charge les clés dès son démarrage
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.hits.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	ks.mu.RLock()
+	loaded, refreshAt := ks.loaded, ks.refreshAt
+	ks.mu.RUnlock()
+	if !loaded {
+		t.Fatal("keys not loaded by Run")
+	}
+	// max-age=60 : rafraîchissement anticipé aux 4/5
+	if d := time.Until(refreshAt); d < 40*time.Second || d > 48*time.Second {
+		t.Errorf("refresh scheduled in %v, want ~48s", d)
+	}
+
+	// issuer en panne : les clés en cache restent servies
+	srv.set(http.StatusInternalServerError, 0)
+	if err := p.refresh(context.Background(), ks); err == nil {
+		t.Fatal("refresh against a failing issuer succeeded")
+	}
+	if _, _, err := p.ValidateToken(context.Background(), signES256(t, "https://idp.example", "k1", k1), TokenPolicy{}); err != nil {
+		t.Errorf("cached key rejected while the issuer is down: %v", err)
+	}
+
+	// de retour : un rafraîchissement remplace le jeu de clés
+	srv.set(http.StatusOK, 0, ecJWK("k2", k2))
+	if err := p.refresh(context.Background(), ks); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ks.lookup("k1", "ES256"); ok {
+		t.Error("removed key k1 still cached")
+	}
+	if _, ok := ks.lookup("k2", "ES256"); !ok {
+		t.Error("new key k2 not cached")
+	}
+}
+
+func TestJWKSRefreshCancelled(t *testing.T) {
+	key := newECKey(t, elliptic.P256())
+	srv := newJWKSServer(t, ecJWK("k1", key))
+	srv.set(http.StatusOK, 500*time.Millisecond)
+	p := NewJWKSProvider([]Issuer{{URL: "https://idp.example", JWKSURL: srv.URL}})
+	ks := p.issuers["https://idp.example"]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err := p.ValidateToken(ctx, signES256(t, "https://idp.example", "k1", key), TokenPolicy{})
+	assertCode(t, err, ErrCodeUnknownKey)
+	if d := time.Since(start); d > 300*time.Millisecond {
+		t.Errorf("cancelled request waited %v for the JWKS", d)
+	}
+	// la requête partagée n'est pas annulée avec l'appelant
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok := ks.lookup("k1", "ES256"); ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("shared JWKS fetch was cancelled with the request")
+}
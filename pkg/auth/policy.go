@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Codes d'erreur de validation des tokens, retournés dans le champ "error" des réponses 401.
+const (
+	ErrCodeMalformed        = "token_malformed"
+	ErrCodeUnsupportedAlg   = "unsupported_algorithm"
+	ErrCodeUntrustedIssuer  = "untrusted_issuer"
+	ErrCodeUnknownKey       = "unknown_key"
+	ErrCodeInvalidSignature = "invalid_signature"
+	ErrCodeExpired          = "token_expired"
+	ErrCodeNotYetValid      = "token_not_yet_valid"
+	ErrCodeInvalidAudience  = "invalid_audience"
+	ErrCodeInvalidAZP       = "invalid_authorized_party"
+	ErrCodeMissingClaim     = "missing_required_claim"
+)
+
+// TokenError est une erreur de validation portant un code stable.
+type TokenError struct {
+	Code    string
+	Message string
+}
+
+func (e *TokenError) Error() string { return e.Message }
+
+func tokenErr(code, format string, args ...any) *TokenError {
+	return &TokenError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// TokenPolicy décrit les claims attendues d'un JWT en plus de sa signature.
+type TokenPolicy struct {
+	// Audiences acceptées : aud doit en contenir au moins une (vide = non vérifié)
+	Audiences []string
+	// Clients autorisés (azp) ; vide = non vérifié
+	AuthorizedParties []string
+	// Tolérance d'horloge appliquée à exp, nbf et iat
+	ClockSkew time.Duration
+	// Claims requises : valeur vide = présente et ni vide ni false, sinon égalité (ex: email_verified=true)
+	RequiredClaims map[string]string
+}
+
+// ParseRequiredClaims lit une liste "claim" ou "claim=valeur" séparée par des virgules.
+func ParseRequiredClaims(s string) map[string]string {
+	out := map[string]string{}
+	for _, e := range strings.Split(s, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(e), "=")
+		if name = strings.TrimSpace(name); name != "" {
+			out[name] = strings.TrimSpace(value)
+		}
+	}
+	return out
+}
+
+// Check vérifie les claims temporelles puis audience, azp et claims requises.
+func (pol TokenPolicy) Check(claims jwt.MapClaims, now time.Time) error {
+	skew := int64(pol.ClockSkew / time.Second)
+	unix := now.Unix()
+	exp, ok := numericClaim(claims, "exp")
+	if !ok {
+		return tokenErr(ErrCodeMissingClaim, "missing exp claim")
+	}
+	if unix > exp+skew {
+		return tokenErr(ErrCodeExpired, "token expired")
+	}
+	if nbf, ok := numericClaim(claims, "nbf"); ok && unix < nbf-skew {
+		return tokenErr(ErrCodeNotYetValid, "token not valid yet")
+	}
+	if iat, ok := numericClaim(claims, "iat"); ok && unix < iat-skew {
+		return tokenErr(ErrCodeNotYetValid, "token issued in the future")
+	}
+	if len(pol.Audiences) > 0 && !slices.ContainsFunc(audiences(claims), func(a string) bool { return slices.Contains(pol.Audiences, a) }) {
+		return tokenErr(ErrCodeInvalidAudience, "invalid audience")
+	}
+	if len(pol.AuthorizedParties) > 0 {
+		azp, _ := claims["azp"].(string)
+		if !slices.Contains(pol.AuthorizedParties, azp) {
+			return tokenErr(ErrCodeInvalidAZP, "invalid authorized party %q", azp)
+		}
+	}
+	for name, want := range pol.RequiredClaims {
+		v, ok := claims[name]
+		got := ""
+		if ok && v != nil {
+			got = fmt.Sprint(v)
+		}
+		if want == "" && (got == "" || got == "false") || want != "" && got != want {
+			return tokenErr(ErrCodeMissingClaim, "required claim %s not satisfied", name)
+		}
+	}
+	return nil
+}
+
+func numericClaim(claims jwt.MapClaims, name string) (int64, bool) {
+	switch v := claims[name].(type) {
+	case float64:
+		return int64(v), true
+	case int64:
+		return v, true
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func audiences(claims jwt.MapClaims) []string {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []any:
+		out := make([]string, 0, len(aud))
+		for _, v := range aud {
+			if s, ok := v.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// RoleClients retourne les clients dont les rôles resource_access valent scopes : les audiences et
+// parties autorisées configurées, ou à défaut le client émetteur du token (azp).
+func (p TokenPolicy) RoleClients(claims map[string]any) []string {
+	if len(p.Audiences) > 0 || len(p.AuthorizedParties) > 0 {
+		out := append([]string{}, p.Audiences...)
+		for _, c := range p.AuthorizedParties {
+			if !slices.Contains(out, c) {
+				out = append(out, c)
+			}
+		}
+		return out
+	}
+	if azp, _ := claims["azp"].(string); azp != "" {
+		return []string{azp}
+	}
+	return nil
+}
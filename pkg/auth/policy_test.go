@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	unix := now.Unix()
+	pol := TokenPolicy{
+		Audiences:         []string{"flotio-api"},
+		AuthorizedParties: []string{"flotio-web"},
+		ClockSkew:         30 * time.Second,
+		RequiredClaims:    map[string]string{"email_verified": "true", "email": ""},
+	}
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"exp": float64(unix + 60), "iat": float64(unix), "nbf": float64(unix),
+			"aud": []any{"account", "flotio-api"}, "azp": "flotio-web",
+			"email": "dev@flotio.dev", "email_verified": true,
+		}
+	}
+	tests := []struct {
+		name   string
+		mutate func(c jwt.MapClaims)
+		want   string // code attendu, vide si valide
+	}{
+		{"valid", func(c jwt.MapClaims) {}, ""},
+		{"json.Number exp", func(c jwt.MapClaims) { c["exp"] = json.Number("1800000060") }, ""},
+		{"int64 exp", func(c jwt.MapClaims) { c["exp"] = unix + 60 }, ""},
+		{"audience as string", func(c jwt.MapClaims) { c["aud"] = "flotio-api" }, ""},
+		{"expired within skew", func(c jwt.MapClaims) { c["exp"] = float64(unix - 30) }, ""},
+		{"expired beyond skew", func(c jwt.MapClaims) { c["exp"] = float64(unix - 31) }, ErrCodeExpired},
+		{"missing exp", func(c jwt.MapClaims) { delete(c, "exp") }, ErrCodeMissingClaim},
+		{"non numeric exp", func(c jwt.MapClaims) { c["exp"] = "tomorrow" }, ErrCodeMissingClaim},
+		{"nbf within skew", func(c jwt.MapClaims) { c["nbf"] = float64(unix + 30) }, ""},
+		{"nbf in the future", func(c jwt.MapClaims) { c["nbf"] = float64(unix + 31) }, ErrCodeNotYetValid},
+		{"iat in the future", func(c jwt.MapClaims) { c["iat"] = float64(unix + 31) }, ErrCodeNotYetValid},
+		{"wrong audience", func(c jwt.MapClaims) { c["aud"] = []any{"account"} }, ErrCodeInvalidAudience},
+		{"missing audience", func(c jwt.MapClaims) { delete(c, "aud") }, ErrCodeInvalidAudience},
+		{"wrong azp", func(c jwt.MapClaims) { c["azp"] = "other" }, ErrCodeInvalidAZP},
+		{"missing azp", func(c jwt.MapClaims) { delete(c, "azp") }, ErrCodeInvalidAZP},
+		{"required claim false", func(c jwt.MapClaims) { c["email_verified"] = false }, ErrCodeMissingClaim},
+		{"required claim missing", func(c jwt.MapClaims) { delete(c, "email") }, ErrCodeMissingClaim},
+		{"required claim empty", func(c jwt.MapClaims) { c["email"] = "" }, ErrCodeMissingClaim},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			tt.mutate(c)
+			assertCode(t, pol.Check(c, now), tt.want)
+		})
+	}
+
+	// une politique vide ne vérifie que les claims temporelles
+	if err := (TokenPolicy{}).Check(jwt.MapClaims{"exp": float64(unix + 1)}, now); err != nil {
+		t.Errorf("empty policy: %v", err)
+	}
+}
+
+func TestParseRequiredClaims(t *testing.T) {
+	got := ParseRequiredClaims(" email_verified = true, email ,, groups=")
+	want := map[string]string{"email_verified": "true", "email": "", "groups": ""}
+	if len(got) != len(want) {
+		t.Fatalf("ParseRequiredClaims = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("ParseRequiredClaims[%q] = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestValidateTokenErrors(t *testing.T) {
+	d, err := NewDevIssuer("http://localhost:8080/dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewJWKSProvider(nil)
+	p.TrustDevIssuer(d)
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	sign := func(method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+		tk := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			tk.Header["kid"] = kid
+		}
+		s, err := tk.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	claims := func(extra jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{"iss": d.URL, "sub": "u1", "aud": "flotio-api", "azp": "flotio-web", "exp": time.Now().Add(time.Minute).Unix()}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+	pol := TokenPolicy{Audiences: []string{"flotio-api"}, AuthorizedParties: []string{"flotio-web"}, RequiredClaims: map[string]string{"sub": ""}}
+	minted, err := d.Mint(claims(nil), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"valid", minted, ""},
+		{"empty", "", ErrCodeMalformed},
+		{"not a jwt", "abc", ErrCodeMalformed},
+		{"garbage segments", "a.b.c", ErrCodeMalformed},
+		{"hmac", sign(jwt.SigningMethodHS256, d.kid, []byte("secret"), claims(nil)), ErrCodeUnsupportedAlg},
+		{"none", sign(jwt.SigningMethodNone, d.kid, jwt.UnsafeAllowNoneSignatureType, claims(nil)), ErrCodeUnsupportedAlg},
+		{"rsa alg on ec key", func() string {
+			// en-tête réécrit en RS256 : la clé EC du kid ne peut pas le vérifier
+			_, rest, _ := strings.Cut(sign(jwt.SigningMethodES256, d.kid, d.key, claims(nil)), ".")
+			return base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","kid":"`+d.kid+`","typ":"JWT"}`)) + "." + rest
+		}(), ErrCodeUnsupportedAlg},
+		{"untrusted issuer", sign(jwt.SigningMethodES256, d.kid, d.key, claims(jwt.MapClaims{"iss": "https://evil.example"})), ErrCodeUntrustedIssuer},
+		{"unknown kid", sign(jwt.SigningMethodES256, "rotated", d.key, claims(nil)), ErrCodeUnknownKey},
+		{"forged signature", sign(jwt.SigningMethodES256, d.kid, other, claims(nil)), ErrCodeInvalidSignature},
+		{"expired", sign(jwt.SigningMethodES256, d.kid, d.key, claims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})), ErrCodeExpired},
+		{"not yet valid", sign(jwt.SigningMethodES256, d.kid, d.key, claims(jwt.MapClaims{"nbf": time.Now().Add(time.Hour).Unix()})), ErrCodeNotYetValid},
+		{"wrong audience", sign(jwt.SigningMethodES256, d.kid, d.key, claims(jwt.MapClaims{"aud": "other"})), ErrCodeInvalidAudience},
+		{"wrong azp", sign(jwt.SigningMethodES256, d.kid, d.key, claims(jwt.MapClaims{"azp": "other"})), ErrCodeInvalidAZP},
+		{"missing sub", sign(jwt.SigningMethodES256, d.kid, d.key, claims(jwt.MapClaims{"sub": ""})), ErrCodeMissingClaim},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got, err := p.ValidateToken(context.Background(), tt.token, pol)
+			assertCode(t, err, tt.want)
+			if err == nil && got["sub"] != "u1" {
+				t.Errorf("claims = %v", got)
+			}
+		})
+	}
+}
+
+func assertCode(t *testing.T, err error, want string) {
+	t.Helper()
+	if want == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	var te *TokenError
+	if !errors.As(err, &te) {
+		t.Fatalf("error %v is not a *TokenError (want %s)", err, want)
+	}
+	if te.Code != want {
+		t.Fatalf("code = %s (%v), want %s", te.Code, te.Message, want)
+	}
+}
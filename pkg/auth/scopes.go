@@ -0,0 +1,78 @@
+package auth
+
+import "strings"
+
+// Scopes exigés par les routes de l'API.
+const (
+	ScopeProjectsRead   = "projects:read"
+	ScopeProjectsWrite  = "projects:write"
+	ScopeBuildsRead     = "builds:read"
+	ScopeBuildsTrigger  = "builds:trigger"
+	ScopeBuildsWrite    = "builds:write" // statut, artefacts, liens d'installation (workers)
+	ScopeChannelsRead   = "channels:read"
+	ScopeChannelsWrite  = "channels:write"
+	ScopeEnvVarsRead    = "envvars:read"
+	ScopeEnvVarsWrite   = "envvars:write"
+	ScopeEnvVarsReveal  = "envvars:reveal" // valeurs en clair des variables
+	ScopeWebhooksManage = "webhooks:manage"
+	ScopeTokensManage   = "tokens:manage"
+	ScopeWorkersManage  = "workers:manage" // workers de build créés par l'appelant
+	ScopeWorkersAdmin   = "workers:admin"  // tous les workers, y compris ceux des clients Keycloak
+)
+
+// KnownScopes liste les scopes acceptés à la création d'un token d'API.
+var KnownScopes = []string{
+	ScopeProjectsRead, ScopeProjectsWrite,
+	ScopeBuildsRead, ScopeBuildsTrigger, ScopeBuildsWrite,
+	ScopeChannelsRead, ScopeChannelsWrite,
+	ScopeEnvVarsRead, ScopeEnvVarsWrite, ScopeEnvVarsReveal,
+	ScopeWebhooksManage, ScopeTokensManage, ScopeWorkersManage, ScopeWorkersAdmin,
+}
+
+// ScopesFromClaims rassemble les scopes accordés par un token : claim "scope" (chaîne séparée
+// par des espaces) ou "scp" (liste), rôles Keycloak de realm_access et rôles des clients de
+// resource_access listés dans clients (ceux de ce service, voir TokenPolicy.RoleClients).
+func ScopesFromClaims(claims map[string]any, clients ...string) []string {
+	var out []string
+	if s, ok := claims["scope"].(string); ok {
+		out = append(out, strings.Fields(s)...)
+	}
+	out = append(out, stringList(claims["scp"])...)
+	if ra, ok := claims["realm_access"].(map[string]any); ok {
+		out = append(out, stringList(ra["roles"])...)
+	}
+	if res, ok := claims["resource_access"].(map[string]any); ok {
+		for _, id := range clients {
+			if c, ok := res[id].(map[string]any); ok {
+				out = append(out, stringList(c["roles"])...)
+			}
+		}
+	}
+	return out
+}
+
+// HasScope indique si required est couvert par granted ("*" et "projects:*" sont des jokers).
+func HasScope(granted []string, required string) bool {
+	resource, _, _ := strings.Cut(required, ":")
+	for _, g := range granted {
+		if g == required || g == "*" || g == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
+
+func stringList(v any) []string {
+	var out []string
+	switch l := v.(type) {
+	case []any:
+		for _, it := range l {
+			if s, _ := it.(string); s != "" {
+				out = append(out, s)
+			}
+		}
+	case []string:
+		out = append(out, l...)
+	}
+	return out
+}
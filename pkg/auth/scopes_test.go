@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestScopesFromClaims(t *testing.T) {
+	claims := map[string]any{
+		"azp":          "flotio-web",
+		"scope":        "openid projects:read",
+		"scp":          []any{"builds:read"},
+		"realm_access": map[string]any{"roles": []any{"channels:read"}},
+		"resource_access": map[string]any{
+			"flotio-api":   map[string]any{"roles": []any{"builds:trigger"}},
+			"flotio-web":   map[string]any{"roles": []any{"envvars:read"}},
+			"other-client": map[string]any{"roles": []any{"*"}},
+		},
+	}
+	tests := []struct {
+		name   string
+		policy TokenPolicy
+		want   []string
+	}{
+		{"configured audience", TokenPolicy{Audiences: []string{"flotio-api"}},
+			[]string{"openid", "projects:read", "builds:read", "channels:read", "builds:trigger"}},
+		{"audience and authorized party", TokenPolicy{Audiences: []string{"flotio-api"}, AuthorizedParties: []string{"flotio-web", "flotio-api"}},
+			[]string{"openid", "projects:read", "builds:read", "channels:read", "builds:trigger", "envvars:read"}},
+		{"azp when nothing configured", TokenPolicy{},
+			[]string{"openid", "projects:read", "builds:read", "channels:read", "envvars:read"}},
+		{"unrelated client", TokenPolicy{Audiences: []string{"unknown"}},
+			[]string{"openid", "projects:read", "builds:read", "channels:read"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ScopesFromClaims(claims, tt.policy.RoleClients(claims)...)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ScopesFromClaims = %v, want %v", got, tt.want)
+			}
+			if HasScope(got, "builds:write") {
+				t.Errorf("roles of other-client leaked: %v", got)
+			}
+		})
+	}
+
+	// sans azp ni configuration, aucun rôle client n'est retenu
+	noAzp := map[string]any{"resource_access": claims["resource_access"]}
+	if got := ScopesFromClaims(noAzp, TokenPolicy{}.RoleClients(noAzp)...); len(got) != 0 {
+		t.Errorf("ScopesFromClaims without azp = %v, want none", got)
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		granted  []string
+		required string
+		want     bool
+	}{
+		{[]string{"projects:read"}, "projects:read", true},
+		{[]string{"projects:read"}, "projects:write", false},
+		{[]string{"projects:*"}, "projects:write", true},
+		{[]string{"projects:*"}, "builds:read", false},
+		{[]string{"*"}, "tokens:manage", true},
+		{nil, "projects:read", false},
+		{[]string{"projects"}, "projects:read", false},
+	}
+	for _, tt := range tests {
+		if got := HasScope(tt.granted, tt.required); got != tt.want {
+			t.Errorf("HasScope(%v, %q) = %v, want %v", tt.granted, tt.required, got, tt.want)
+		}
+	}
+}
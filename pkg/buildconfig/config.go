@@ -0,0 +1,273 @@
+// Package buildconfig lit et valide le fichier flotio.yaml décrivant les builds d'un dépôt.
+package buildconfig
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName est le nom du fichier de configuration lu à la racine du projet.
+const FileName = "flotio.yaml"
+
+// LatestVersion est la version de schéma la plus récente supportée.
+const LatestVersion = 1
+
+// Platforms supportées, dans l'ordre d'affichage (même casse que db.Build.Platform).
+var Platforms = []string{"IOS", "ANDROID", "LINUX", "WINDOWS", "MAC"}
+
+// Config est la configuration résolue (valeurs par défaut appliquées).
+type Config struct {
+	Version       int      `json:"version"`
+	Platforms     []string `json:"platforms"`
+	EnvCategories []string `json:"env_categories"`
+	Flavors       []Flavor `json:"flavors"`
+}
+
+// Flavor est une variante de build (ex: staging, production).
+type Flavor struct {
+	Name          string   `json:"name"`
+	Platforms     []string `json:"platforms"`
+	EnvCategories []string `json:"env_categories"`
+}
+
+// Issue est une erreur de validation localisée dans le fichier.
+type Issue struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+func (i Issue) String() string {
+	if i.Path != "" {
+		return fmt.Sprintf("line %d: %s: %s", i.Line, i.Path, i.Message)
+	}
+	return fmt.Sprintf("line %d: %s", i.Line, i.Message)
+}
+
+// ValidationError regroupe toutes les erreurs trouvées dans le fichier.
+type ValidationError struct {
+	Issues []Issue
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Issues))
+	for i, it := range e.Issues {
+		msgs[i] = it.String()
+	}
+	return "invalid " + FileName + ": " + strings.Join(msgs, "; ")
+}
+
+var yamlLineRe = regexp.MustCompile(`line (\d+): (.*)`)
+
+// Parse lit un fichier flotio.yaml et retourne la configuration résolue.
+// Les erreurs de syntaxe et de schéma sont retournées sous forme de *ValidationError.
+func Parse(data []byte) (*Config, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, syntaxError(err)
+	}
+	if doc.Kind == 0 || len(doc.Content) == 0 {
+		return nil, &ValidationError{Issues: []Issue{{Line: 1, Column: 1, Message: "empty file"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, &ValidationError{Issues: []Issue{{Line: root.Line, Column: root.Column, Message: "expected a mapping at top level"}}}
+	}
+
+	v := &validator{}
+	version := LatestVersion
+	if n := lookup(root, "version"); n == nil {
+		v.add(root, "version", "required")
+	} else if i, err := strconv.Atoi(n.Value); n.Kind != yaml.ScalarNode || err != nil {
+		v.add(n, "version", "must be an integer")
+	} else {
+		version = i
+	}
+	var cfg *Config
+	switch version {
+	case 1:
+		cfg = v.v1(root)
+	default:
+		v.add(lookup(root, "version"), "version", fmt.Sprintf("unsupported schema version %d (latest is %d)", version, LatestVersion))
+	}
+	if len(v.issues) > 0 {
+		sort.SliceStable(v.issues, func(i, j int) bool { return v.issues[i].Line < v.issues[j].Line })
+		return nil, &ValidationError{Issues: v.issues}
+	}
+	return cfg, nil
+}
+
+// syntaxError convertit une erreur yaml ("yaml: line 3: ...") en ValidationError.
+func syntaxError(err error) error {
+	var te *yaml.TypeError
+	msgs := []string{err.Error()}
+	if errors.As(err, &te) {
+		msgs = te.Errors
+	}
+	ve := &ValidationError{}
+	for _, m := range msgs {
+		it := Issue{Line: 1, Message: strings.TrimPrefix(m, "yaml: ")}
+		if sm := yamlLineRe.FindStringSubmatch(m); sm != nil {
+			it.Line, _ = strconv.Atoi(sm[1])
+			it.Message = sm[2]
+		}
+		ve.Issues = append(ve.Issues, it)
+	}
+	return ve
+}
+
+type validator struct {
+	issues []Issue
+}
+
+func (v *validator) add(n *yaml.Node, path, msg string) {
+	it := Issue{Path: path, Message: msg}
+	if n != nil {
+		it.Line, it.Column = n.Line, n.Column
+	}
+	v.issues = append(v.issues, it)
+}
+
+// v1 valide le schéma version 1:
+//
+//	version: 1
+//	platforms: [android, ios]
+//	env_categories: [firebase-config]
+//	flavors:
+//	  - name: production
+//	    platforms: [android]
+//	    env_categories: [push-notification]
+func (v *validator) v1(root *yaml.Node) *Config {
+	cfg := &Config{Version: 1}
+	v.checkKeys(root, "", "version", "platforms", "env_categories", "flavors")
+
+	if n := lookup(root, "platforms"); n == nil {
+		v.add(root, "platforms", "required")
+	} else {
+		cfg.Platforms = v.platforms(n, "platforms")
+		if len(cfg.Platforms) == 0 && n.Kind == yaml.SequenceNode {
+			v.add(n, "platforms", "at least one platform required")
+		}
+	}
+	if n := lookup(root, "env_categories"); n != nil {
+		cfg.EnvCategories = v.strings(n, "env_categories")
+	}
+
+	if n := lookup(root, "flavors"); n != nil {
+		if n.Kind != yaml.SequenceNode {
+			v.add(n, "flavors", "must be a list")
+		} else {
+			seen := map[string]bool{}
+			for i, fn := range n.Content {
+				path := fmt.Sprintf("flavors[%d]", i)
+				if fn.Kind != yaml.MappingNode {
+					v.add(fn, path, "must be a mapping")
+					continue
+				}
+				v.checkKeys(fn, path, "name", "platforms", "env_categories")
+				f := Flavor{Platforms: cfg.Platforms, EnvCategories: cfg.EnvCategories}
+				if nn := lookup(fn, "name"); nn == nil || nn.Kind != yaml.ScalarNode || nn.Value == "" {
+					v.add(fn, path+".name", "required")
+				} else if seen[nn.Value] {
+					v.add(nn, path+".name", fmt.Sprintf("duplicate flavor %q", nn.Value))
+				} else {
+					seen[nn.Value] = true
+					f.Name = nn.Value
+				}
+				if pn := lookup(fn, "platforms"); pn != nil {
+					f.Platforms = v.platforms(pn, path+".platforms")
+					for _, pl := range f.Platforms {
+						if !slices.Contains(cfg.Platforms, pl) {
+							v.add(pn, path+".platforms", fmt.Sprintf("platform %s is not declared at top level", pl))
+						}
+					}
+				}
+				if en := lookup(fn, "env_categories"); en != nil {
+					f.EnvCategories = v.strings(en, path+".env_categories")
+				}
+				cfg.Flavors = append(cfg.Flavors, f)
+			}
+		}
+	}
+	if len(cfg.Flavors) == 0 {
+		cfg.Flavors = []Flavor{{Name: "default", Platforms: cfg.Platforms, EnvCategories: cfg.EnvCategories}}
+	}
+	if cfg.EnvCategories == nil {
+		cfg.EnvCategories = []string{}
+	}
+	return cfg
+}
+
+// checkKeys signale les clés inconnues d'un mapping.
+func (v *validator) checkKeys(m *yaml.Node, path string, allowed ...string) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		k := m.Content[i]
+		if !slices.Contains(allowed, k.Value) {
+			p := k.Value
+			if path != "" {
+				p = path + "." + k.Value
+			}
+			v.add(k, p, "unknown field")
+		}
+	}
+}
+
+func (v *validator) strings(n *yaml.Node, path string) []string {
+	if n.Kind != yaml.SequenceNode {
+		v.add(n, path, "must be a list of strings")
+		return nil
+	}
+	out := []string{}
+	for i, it := range n.Content {
+		if it.Kind != yaml.ScalarNode || it.Value == "" {
+			v.add(it, fmt.Sprintf("%s[%d]", path, i), "must be a non-empty string")
+			continue
+		}
+		out = append(out, it.Value)
+	}
+	return out
+}
+
+func (v *validator) platforms(n *yaml.Node, path string) []string {
+	if n.Kind != yaml.SequenceNode {
+		v.add(n, path, "must be a list of strings")
+		return nil
+	}
+	var out []string
+	for i, it := range n.Content {
+		p := fmt.Sprintf("%s[%d]", path, i)
+		if it.Kind != yaml.ScalarNode || it.Value == "" {
+			v.add(it, p, "must be a non-empty string")
+			continue
+		}
+		pl := strings.ToUpper(it.Value)
+		if !slices.Contains(Platforms, pl) {
+			v.add(it, p, fmt.Sprintf("unknown platform %q", it.Value))
+			continue
+		}
+		if slices.Contains(out, pl) {
+			v.add(it, p, fmt.Sprintf("duplicate platform %q", it.Value))
+			continue
+		}
+		out = append(out, pl)
+	}
+	return out
+}
+
+// lookup retourne la valeur associée à une clé d'un mapping.
+func lookup(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package buildconfig
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse([]byte(`version: 1
+platforms: [android, ios]
+env_categories: [firebase]
+flavors:
+  - name: staging
+  - name: production
+    platforms: [android]
+    env_categories: [push]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Config{
+		Version:       1,
+		Platforms:     []string{"ANDROID", "IOS"},
+		EnvCategories: []string{"firebase"},
+		Flavors: []Flavor{
+			{Name: "staging", Platforms: []string{"ANDROID", "IOS"}, EnvCategories: []string{"firebase"}},
+			{Name: "production", Platforms: []string{"ANDROID"}, EnvCategories: []string{"push"}},
+		},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("got %+v, want %+v", cfg, want)
+	}
+}
+
+func TestParseDefaultFlavor(t *testing.T) {
+	cfg, err := Parse([]byte("version: 1\nplatforms: [linux]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Flavors) != 1 || cfg.Flavors[0].Name != "default" || !reflect.DeepEqual(cfg.Flavors[0].Platforms, []string{"LINUX"}) {
+		t.Fatalf("unexpected flavors %+v", cfg.Flavors)
+	}
+	if cfg.EnvCategories == nil {
+		t.Fatal("env_categories should default to an empty list")
+	}
+}
+
+func TestParseIssues(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []Issue
+	}{
+		{
+			name: "empty",
+			in:   "",
+			want: []Issue{{Line: 1, Column: 1, Message: "empty file"}},
+		},
+		{
+			name: "not a mapping",
+			in:   "- a\n",
+			want: []Issue{{Line: 1, Column: 1, Message: "expected a mapping at top level"}},
+		},
+		{
+			name: "missing fields",
+			in:   "foo: 1\n",
+			want: []Issue{
+				{Line: 1, Column: 1, Path: "version", Message: "required"},
+				{Line: 1, Column: 1, Path: "foo", Message: "unknown field"},
+				{Line: 1, Column: 1, Path: "platforms", Message: "required"},
+			},
+		},
+		{
+			name: "unsupported version",
+			in:   "version: 2\nplatforms: [ios]\n",
+			want: []Issue{{Line: 1, Column: 10, Path: "version", Message: "unsupported schema version 2 (latest is 1)"}},
+		},
+		{
+			name: "version not an integer",
+			in:   "version: one\nplatforms: [ios]\n",
+			want: []Issue{{Line: 1, Column: 10, Path: "version", Message: "must be an integer"}},
+		},
+		{
+			// les erreurs pointent l'élément fautif, même après un élément invalide
+			name: "platform positions after an invalid item",
+			in:   "version: 1\nplatforms:\n  - {}\n  - ios\n  - symbian\n  - IOS\n",
+			want: []Issue{
+				{Line: 3, Column: 5, Path: "platforms[0]", Message: "must be a non-empty string"},
+				{Line: 5, Column: 5, Path: "platforms[2]", Message: `unknown platform "symbian"`},
+				{Line: 6, Column: 5, Path: "platforms[3]", Message: `duplicate platform "IOS"`},
+			},
+		},
+		{
+			name: "empty platforms",
+			in:   "version: 1\nplatforms: []\n",
+			want: []Issue{{Line: 2, Column: 12, Path: "platforms", Message: "at least one platform required"}},
+		},
+		{
+			name: "flavor errors",
+			in: `version: 1
+platforms: [ios]
+flavors:
+  - name: a
+  - name: a
+  - platforms: [android]
+  - x
+`,
+			want: []Issue{
+				{Line: 5, Column: 11, Path: "flavors[1].name", Message: `duplicate flavor "a"`},
+				{Line: 6, Column: 5, Path: "flavors[2].name", Message: "required"},
+				{Line: 6, Column: 16, Path: "flavors[2].platforms", Message: "platform ANDROID is not declared at top level"},
+				{Line: 7, Column: 5, Path: "flavors[3]", Message: "must be a mapping"},
+			},
+		},
+		{
+			name: "syntax error",
+			in:   "version: 1\n\tplatforms: [ios]\n",
+			want: []Issue{{Line: 2, Message: "found a tab character that violates indentation"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.in))
+			var ve *ValidationError
+			if !errors.As(err, &ve) {
+				t.Fatalf("expected a ValidationError, got %v", err)
+			}
+			if !reflect.DeepEqual(ve.Issues, tt.want) {
+				t.Fatalf("got %+v\nwant %+v", ve.Issues, tt.want)
+			}
+		})
+	}
+}
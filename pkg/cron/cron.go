@@ -0,0 +1,177 @@
+// Package cron interprète les expressions cron à 5 champs (minute heure jour mois jour-de-semaine).
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule est une expression cron compilée : un bit par valeur autorisée de chaque champ.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// jour du mois et jour de semaine restreints tous les deux : l'un OU l'autre suffit
+	domOrDow bool
+}
+
+// allHours est le champ heure d'une expression "*" (toutes les heures).
+const allHours = 1<<24 - 1
+
+type field struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minutes = field{0, 59, nil}
+	hours   = field{0, 23, nil}
+	doms    = field{1, 31, nil}
+	months  = field{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dows = field{0, 7, map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}}
+)
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse compile une expression ("0 2 * * 1-5", "*/15 * * * *", "@daily").
+func Parse(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if m, ok := macros[strings.ToLower(expr)]; ok {
+		expr = m
+	}
+	parts := strings.Fields(expr)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("cron: expected 5 fields, got %d", len(parts))
+	}
+	var s Schedule
+	var err error
+	for i, f := range []struct {
+		dst *uint64
+		def field
+	}{{&s.minute, minutes}, {&s.hour, hours}, {&s.dom, doms}, {&s.month, months}, {&s.dow, dows}} {
+		if *f.dst, err = parseField(parts[i], f.def); err != nil {
+			return nil, err
+		}
+	}
+	// 7 = dimanche
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	// comme cron (vixie), un champ commençant par "*" ("*/2") n'est pas considéré comme restreint
+	s.domOrDow = !strings.HasPrefix(parts[2], "*") && !strings.HasPrefix(parts[4], "*")
+	return &s, nil
+}
+
+func parseField(s string, f field) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(s, ",") {
+		rng, step := item, 1
+		if i := strings.IndexByte(item, '/'); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("cron: invalid step in %q", item)
+			}
+			rng, step = item[:i], n
+		}
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			var err error
+			a, b, isRange := strings.Cut(rng, "-")
+			if lo, err = f.value(a); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(b); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				hi = f.max // "5/10" : de 5 jusqu'au max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("cron: invalid range %q", rng)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("cron: value %q out of range [%d-%d]", s, f.min, f.max)
+	}
+	return n, nil
+}
+
+// Next retourne la première occurrence strictement postérieure à t, dans le fuseau de t.
+// Les heures sautées au passage à l'heure d'été sont ignorées; l'heure répétée au passage à
+// l'heure d'hiver ne déclenche qu'une fois les expressions à heure fixe. Retourne le temps zéro
+// si aucune date ne correspond dans les cinq ans (ex: 30 février).
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute)
+	after := wallClock(t)
+	t = t.Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = forward(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc))
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = forward(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc))
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = forward(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc))
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 || (s.hour != allHours && !wallClock(t).After(after)) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// forward retourne next s'il est postérieur à t. Une heure locale inexistante (passage à
+// l'heure d'été) peut être normalisée avant t : on avance alors jusqu'à l'heure suivante.
+func forward(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return t.Add(time.Duration(60-t.Minute()) * time.Minute)
+}
+
+// wallClock retourne l'heure affichée par t, sans décalage horaire.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domOrDow {
+		return dom || dow
+	}
+	return dom && dow
+}
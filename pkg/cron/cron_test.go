@@ -0,0 +1,188 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s unavailable: %v", name, err)
+	}
+	return loc
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{"* * * * *", false},
+		{"*/15 2-4 1,15 jan-mar mon-fri", false},
+		{"0 0 * * 7", false},
+		{"5/10 * * * *", false},
+		{"@daily", false},
+		{"@WEEKLY", false},
+		{"", true},
+		{"* * * *", true},
+		{"* * * * * *", true},
+		{"60 * * * *", true},
+		{"* 24 * * *", true},
+		{"* * 0 * *", true},
+		{"* * 32 * *", true},
+		{"* * * 13 *", true},
+		{"* * * * 8", true},
+		{"*/0 * * * *", true},
+		{"5-1 * * * *", true},
+		{"* * * foo *", true},
+		{"@every 5m", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			if _, err := Parse(tt.expr); (err != nil) != tt.wantErr {
+				t.Errorf("Parse(%q) err = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	utc := time.UTC
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want []time.Time // occurrences successives
+	}{
+		{"every minute", "* * * * *", time.Date(2026, 1, 1, 10, 0, 30, 0, utc),
+			[]time.Time{time.Date(2026, 1, 1, 10, 1, 0, 0, utc), time.Date(2026, 1, 1, 10, 2, 0, 0, utc)}},
+		{"strictly after", "0 10 * * *", time.Date(2026, 1, 1, 10, 0, 0, 0, utc),
+			[]time.Time{time.Date(2026, 1, 2, 10, 0, 0, 0, utc)}},
+		{"step with start", "5/20 * * * *", time.Date(2026, 1, 1, 10, 6, 0, 0, utc),
+			[]time.Time{time.Date(2026, 1, 1, 10, 25, 0, 0, utc), time.Date(2026, 1, 1, 10, 45, 0, 0, utc), time.Date(2026, 1, 1, 11, 5, 0, 0, utc)}},
+		{"month rollover", "0 0 1 * *", time.Date(2026, 12, 15, 0, 0, 0, 0, utc),
+			[]time.Time{time.Date(2027, 1, 1, 0, 0, 0, 0, utc), time.Date(2027, 2, 1, 0, 0, 0, 0, utc)}},
+		{"day 31 skips short months", "0 0 31 * *", time.Date(2026, 1, 31, 12, 0, 0, 0, utc),
+			[]time.Time{time.Date(2026, 3, 31, 0, 0, 0, 0, utc), time.Date(2026, 5, 31, 0, 0, 0, 0, utc)}},
+		{"leap day", "0 0 29 2 *", time.Date(2026, 1, 1, 0, 0, 0, 0, utc),
+			[]time.Time{time.Date(2028, 2, 29, 0, 0, 0, 0, utc)}},
+		{"february 30 never matches", "0 0 30 2 *", time.Date(2026, 1, 1, 0, 0, 0, 0, utc),
+			[]time.Time{{}}},
+		// 2026-03-13 est un vendredi
+		{"dow only", "0 9 * * fri", time.Date(2026, 3, 1, 0, 0, 0, 0, utc),
+			[]time.Time{time.Date(2026, 3, 6, 9, 0, 0, 0, utc), time.Date(2026, 3, 13, 9, 0, 0, 0, utc)}},
+		{"dom only", "0 9 13 * *", time.Date(2026, 3, 1, 0, 0, 0, 0, utc),
+			[]time.Time{time.Date(2026, 3, 13, 9, 0, 0, 0, utc), time.Date(2026, 4, 13, 9, 0, 0, 0, utc)}},
+		{"dom or dow when both restricted", "0 9 13 * 5", time.Date(2026, 3, 1, 0, 0, 0, 0, utc),
+			[]time.Time{time.Date(2026, 3, 6, 9, 0, 0, 0, utc), time.Date(2026, 3, 13, 9, 0, 0, 0, utc), time.Date(2026, 3, 20, 9, 0, 0, 0, utc)}},
+		{"dom and dow when dom starts with star", "0 9 */2 * 5", time.Date(2026, 3, 1, 0, 0, 0, 0, utc),
+			[]time.Time{time.Date(2026, 3, 13, 9, 0, 0, 0, utc), time.Date(2026, 3, 27, 9, 0, 0, 0, utc)}},
+		{"dow 7 is sunday", "0 0 * * 7", time.Date(2026, 3, 2, 0, 0, 0, 0, utc),
+			[]time.Time{time.Date(2026, 3, 8, 0, 0, 0, 0, utc)}},
+		{"dow range to 7", "0 0 * * 6-7", time.Date(2026, 3, 2, 0, 0, 0, 0, utc),
+			[]time.Time{time.Date(2026, 3, 7, 0, 0, 0, 0, utc), time.Date(2026, 3, 8, 0, 0, 0, 0, utc), time.Date(2026, 3, 14, 0, 0, 0, 0, utc)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cur := tt.from
+			for i, want := range tt.want {
+				got := s.Next(cur)
+				if !got.Equal(want) {
+					t.Fatalf("occurrence %d: Next(%s) = %s, want %s", i, cur, got, want)
+				}
+				cur = got
+			}
+		})
+	}
+}
+
+func TestNextDST(t *testing.T) {
+	paris := mustLoad(t, "Europe/Paris")
+	ny := mustLoad(t, "America/New_York")
+	cest := time.FixedZone("CEST", 2*3600)
+	cet := time.FixedZone("CET", 3600)
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want []time.Time
+	}{
+		// 2026-03-29 : 02:00 CET -> 03:00 CEST, 02:30 n'existe pas ce jour-là
+		{"skipped hour is not run", "30 2 * * *", time.Date(2026, 3, 28, 12, 0, 0, 0, paris),
+			[]time.Time{time.Date(2026, 3, 30, 2, 30, 0, 0, cest)}},
+		{"hour after gap", "30 3 * * *", time.Date(2026, 3, 29, 0, 0, 0, 0, paris),
+			[]time.Time{time.Date(2026, 3, 29, 3, 30, 0, 0, cest)}},
+		{"hourly across gap", "0 * * * *", time.Date(2026, 3, 29, 1, 30, 0, 0, paris),
+			[]time.Time{time.Date(2026, 3, 29, 3, 0, 0, 0, cest), time.Date(2026, 3, 29, 4, 0, 0, 0, cest)}},
+		// 2026-10-25 : 03:00 CEST -> 02:00 CET, l'heure 02:xx est jouée deux fois
+		{"repeated hour runs once", "30 2 * * *", time.Date(2026, 10, 25, 1, 59, 0, 0, paris),
+			[]time.Time{time.Date(2026, 10, 25, 2, 30, 0, 0, cest), time.Date(2026, 10, 26, 2, 30, 0, 0, cet)}},
+		{"repeated hour from midnight", "30 2 * * *", time.Date(2026, 10, 25, 0, 0, 0, 0, paris),
+			[]time.Time{time.Date(2026, 10, 25, 2, 30, 0, 0, cet), time.Date(2026, 10, 26, 2, 30, 0, 0, cet)}},
+		{"wildcard hour follows real time", "0 * * * *", time.Date(2026, 10, 25, 1, 30, 0, 0, paris),
+			[]time.Time{
+				time.Date(2026, 10, 25, 2, 0, 0, 0, cest),
+				time.Date(2026, 10, 25, 2, 0, 0, 0, cet),
+				time.Date(2026, 10, 25, 3, 0, 0, 0, cet),
+			}},
+		{"daily keeps wall clock", "0 9 * * *", time.Date(2026, 3, 7, 10, 0, 0, 0, ny),
+			[]time.Time{time.Date(2026, 3, 8, 9, 0, 0, 0, ny), time.Date(2026, 3, 9, 9, 0, 0, 0, ny)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cur := tt.from
+			for i, want := range tt.want {
+				got := s.Next(cur)
+				if !got.Equal(want) {
+					t.Fatalf("occurrence %d: Next(%s) = %s, want %s", i, cur, got, want)
+				}
+				if got.Location() != cur.Location() {
+					t.Errorf("occurrence %d: location %s, want %s", i, got.Location(), cur.Location())
+				}
+				cur = got
+			}
+		})
+	}
+}
+
+// TestNextAlwaysAdvances parcourt une année dans des fuseaux aux transitions atypiques
+// (minuit inexistant, décalage d'une demi-heure) : chaque occurrence doit progresser.
+func TestNextAlwaysAdvances(t *testing.T) {
+	zones := []string{"Europe/Paris", "America/New_York", "America/Santiago", "America/Havana", "Australia/Lord_Howe", "Asia/Kolkata"}
+	exprs := map[string]time.Duration{
+		"@daily":     49 * time.Hour,
+		"30 2 * * *": 49 * time.Hour,
+		"15 0 * * *": 49 * time.Hour,
+		"0 * * * *":  2 * time.Hour,
+	}
+	for _, zone := range zones {
+		loc := mustLoad(t, zone)
+		for expr, maxGap := range exprs {
+			t.Run(zone+"/"+expr, func(t *testing.T) {
+				s, err := Parse(expr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				cur := time.Date(2026, 1, 1, 0, 0, 0, 0, loc)
+				end := cur.AddDate(1, 0, 0)
+				for cur.Before(end) {
+					next := s.Next(cur)
+					if !next.After(cur) || next.Sub(cur) > maxGap {
+						t.Fatalf("Next(%s) = %s", cur, next)
+					}
+					cur = next
+				}
+			})
+		}
+	}
+}
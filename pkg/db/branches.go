@@ -0,0 +1,22 @@
+package db
+
+import "gorm.io/gorm"
+
+// migratePullRequestBranches fusionne les branches en double d'une même pull request (livraisons
+// concurrentes) dans la plus ancienne, puis pose l'index unique qui sert de cible à l'upsert.
+func migratePullRequestBranches(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		const dups = `SELECT id, first_value(id) OVER (PARTITION BY project_id, pull_request ORDER BY created_at, id) AS keep
+			FROM branches WHERE pull_request IS NOT NULL`
+		if err := tx.Exec(`UPDATE builds b SET branch_id = d.keep FROM (` + dups + `) d
+			WHERE b.branch_id = d.id AND d.id <> d.keep`).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`DELETE FROM branches br USING (` + dups + `) d
+			WHERE br.id = d.id AND d.id <> d.keep`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_branch_project_pull_request
+			ON branches (project_id, pull_request) WHERE pull_request IS NOT NULL`).Error
+	})
+}
@@ -0,0 +1,39 @@
+package db
+
+import "gorm.io/gorm"
+
+// BuildCounter est le compteur de numéros de build d'un projet. La ligne est verrouillée
+// par l'upsert pendant la transaction de création du build : pas de doublon ni de trou.
+type BuildCounter struct {
+	ProjectID string `gorm:"type:uuid;primaryKey"`
+	Last      int64  `gorm:"not null;default:0"`
+}
+
+// BeforeCreate attribue le prochain numéro de build du projet.
+func (b *Build) BeforeCreate(tx *gorm.DB) error {
+	if b.Number != 0 {
+		return nil
+	}
+	return tx.Session(&gorm.Session{NewDB: true}).Raw(`INSERT INTO build_counters (project_id, last) VALUES (?, 1)
+		ON CONFLICT (project_id) DO UPDATE SET last = build_counters.last + 1
+		RETURNING last`, b.ProjectID).Scan(&b.Number).Error
+}
+
+// migrateBuildNumbers numérote les builds existants et aligne les compteurs.
+func migrateBuildNumbers(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`UPDATE builds b SET number = s.n FROM (
+				SELECT id, row_number() OVER (PARTITION BY project_id ORDER BY created_at, id)
+					+ COALESCE((SELECT max(number) FROM builds m WHERE m.project_id = builds.project_id), 0) AS n
+				FROM builds WHERE number = 0
+			) s WHERE b.id = s.id`).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`INSERT INTO build_counters (project_id, last)
+				SELECT project_id, max(number) FROM builds GROUP BY project_id
+			ON CONFLICT (project_id) DO UPDATE SET last = GREATEST(build_counters.last, excluded.last)`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_build_project_number ON builds (project_id, number)`).Error
+	})
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/flotio-dev/project-service/pkg/db/dbtest"
+)
+
+func TestBuildNumbers(t *testing.T) {
+	gdb, fake := dbtest.Open(t)
+	// compteur par projet, incrémenté comme le ferait l'upsert sur build_counters
+	var mu sync.Mutex
+	last := map[string]int64{}
+	fake.Handle(`INSERT INTO build_counters`, func(q dbtest.Query) dbtest.Result {
+		mu.Lock()
+		defer mu.Unlock()
+		project := q.Args[0].(string)
+		last[project]++
+		return dbtest.Rows([]string{"last"}, []any{last[project]})
+	})
+
+	create := func(b *Build) {
+		t.Helper()
+		if err := gdb.Create(b).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	var p1 []int64
+	for range 3 {
+		b := Build{ProjectID: "p1"}
+		create(&b)
+		p1 = append(p1, b.Number)
+	}
+	other := Build{ProjectID: "p2"}
+	create(&other)
+	imported := Build{ProjectID: "p1", Number: 42}
+	create(&imported)
+
+	if p1[0] != 1 || p1[1] != 2 || p1[2] != 3 {
+		t.Errorf("p1 numbers = %v, want [1 2 3]", p1)
+	}
+	if other.Number != 1 {
+		t.Errorf("p2 number = %d, want 1 (one counter per project)", other.Number)
+	}
+	if imported.Number != 42 {
+		t.Errorf("explicit number overwritten: %d", imported.Number)
+	}
+	upserts := fake.Queries(`INSERT INTO build_counters`)
+	if len(upserts) != 4 {
+		t.Fatalf("%d counter upserts, want 4 (none for an explicit number)", len(upserts))
+	}
+	// l'upsert et l'insertion du build sont dans la même transaction : le verrou de la ligne
+	// du compteur est tenu jusqu'au commit
+	want := []string{`^BEGIN$`, `^INSERT INTO build_counters`, `^INSERT INTO "builds"`, `^COMMIT$`}
+	qs := fake.Queries(`^(BEGIN|COMMIT|INSERT)`)
+	for i, pattern := range want {
+		if i >= len(qs) || !regexp.MustCompile(pattern).MatchString(qs[i].SQL) {
+			t.Fatalf("statements = %v, want the sequence %q", qs, want)
+		}
+	}
+}
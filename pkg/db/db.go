@@ -14,15 +14,40 @@ func Connect(databaseURL string) (*gorm.DB, error) {
 }
 
 func AutoMigrate(db *gorm.DB) error {
-	return db.AutoMigrate(
+	err := db.AutoMigrate(
 		&Project{},
 		&ProjectStats{},
 		&ProjectUsage{},
 		&Branch{},
 		&EnvVar{},
 		&Build{},
+		&BuildCounter{},
 		&BuildLog{},
+		&BuildArtifact{},
+		&InstallLink{},
+		&Channel{},
+		&ChannelRelease{},
+		&Schedule{},
+		&ScheduleRun{},
+		&Webhook{},
+		&WebhookDelivery{},
+		&OutboxEvent{},
+		&APIToken{},
+		&Worker{},
+		&ImportJob{},
+		&ImportJobItem{},
+		&RateLimitBucket{},
 	)
+	if err != nil {
+		return err
+	}
+	if err := migrateBuildNumbers(db); err != nil {
+		return err
+	}
+	if err := migratePullRequestBranches(db); err != nil {
+		return err
+	}
+	return migrateGroupPaths(db)
 }
 
 func Must(db *gorm.DB, err error) *gorm.DB {
@@ -0,0 +1,176 @@
+// Package dbtest ouvre une connexion GORM (dialecte Postgres) sur une base factice, pour tester
+// les requêtes sans serveur : chaque requête est enregistrée et reçoit la réponse du dernier
+// Handle dont le motif correspond (aucune ligne sinon).
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Query est une requête reçue par la base.
+type Query struct {
+	SQL  string
+	Args []any
+}
+
+// Result est la réponse à une requête : des lignes pour un SELECT ou un RETURNING,
+// RowsAffected pour un Exec, ou une erreur.
+type Result struct {
+	Columns      []string
+	Rows         [][]any
+	RowsAffected int64
+	Err          error
+}
+
+// Rows construit un Result à partir de colonnes et de lignes.
+func Rows(columns []string, rows ...[]any) Result {
+	return Result{Columns: columns, Rows: rows, RowsAffected: int64(len(rows))}
+}
+
+type handler struct {
+	re *regexp.Regexp
+	fn func(Query) Result
+}
+
+// DB enregistre les requêtes et y répond.
+type DB struct {
+	mu       sync.Mutex
+	handlers []handler
+	queries  []Query
+}
+
+// Open retourne une connexion GORM sur une base factice.
+func Open(t testing.TB) (*gorm.DB, *DB) {
+	t.Helper()
+	fake := &DB{}
+	conn := sql.OpenDB(connector{fake})
+	t.Cleanup(func() { conn.Close() })
+	gdb, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{
+		Logger:               logger.Discard,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gdb, fake
+}
+
+// Handle répond aux requêtes correspondant à pattern (expression régulière) ;
+// les derniers handlers enregistrés sont prioritaires.
+func (d *DB) Handle(pattern string, fn func(Query) Result) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers = append(d.handlers, handler{regexp.MustCompile(pattern), fn})
+}
+
+// Return répond toujours res aux requêtes correspondant à pattern.
+func (d *DB) Return(pattern string, res Result) {
+	d.Handle(pattern, func(Query) Result { return res })
+}
+
+// Queries retourne les requêtes reçues correspondant à pattern, dans l'ordre.
+func (d *DB) Queries(pattern string) []Query {
+	re := regexp.MustCompile(pattern)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var out []Query
+	for _, q := range d.queries {
+		if re.MatchString(q.SQL) {
+			out = append(out, q)
+		}
+	}
+	return out
+}
+
+func (d *DB) run(query string, args []driver.NamedValue) Result {
+	q := Query{SQL: query, Args: make([]any, len(args))}
+	for i, a := range args {
+		q.Args[i] = a.Value
+	}
+	d.mu.Lock()
+	d.queries = append(d.queries, q)
+	var fn func(Query) Result
+	for i := len(d.handlers) - 1; i >= 0; i-- {
+		if d.handlers[i].re.MatchString(query) {
+			fn = d.handlers[i].fn
+			break
+		}
+	}
+	d.mu.Unlock()
+	if fn == nil {
+		return Result{}
+	}
+	return fn(q)
+}
+
+type connector struct{ db *DB }
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return &conn{c.db}, nil }
+func (c connector) Driver() driver.Driver                        { return fakeDriver{c.db} }
+
+type fakeDriver struct{ db *DB }
+
+func (d fakeDriver) Open(string) (driver.Conn, error) { return &conn{d.db}, nil }
+
+type conn struct{ db *DB }
+
+func (c *conn) Prepare(string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("dbtest: prepared statements are not supported")
+}
+func (c *conn) Close() error              { return nil }
+func (c *conn) Begin() (driver.Tx, error) { return c, nil }
+func (c *conn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	c.db.run("BEGIN", nil)
+	return c, nil
+}
+func (c *conn) Commit() error   { c.db.run("COMMIT", nil); return nil }
+func (c *conn) Rollback() error { c.db.run("ROLLBACK", nil); return nil }
+
+// CheckNamedValue accepte tous les arguments tels quels.
+func (c *conn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+func (c *conn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res := c.db.run(query, args)
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	return driver.RowsAffected(res.RowsAffected), nil
+}
+
+func (c *conn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	res := c.db.run(query, args)
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	return &rows{columns: res.Columns, values: res.Rows}, nil
+}
+
+type rows struct {
+	columns []string
+	values  [][]any
+}
+
+func (r *rows) Columns() []string { return r.columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	for i, v := range r.values[0] {
+		dest[i] = v
+	}
+	r.values = r.values[1:]
+	return nil
+}
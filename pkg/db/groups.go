@@ -0,0 +1,20 @@
+package db
+
+import "gorm.io/gorm"
+
+// migrateGroupPaths ramène les groupes enregistrés au chemin canonique "/parent/child"
+// (voir auth.NormalizeGroupPath) : slash initial, ni slash final ni segment vide.
+func migrateGroupPaths(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, table := range []string{"projects", "import_jobs"} {
+			if err := tx.Exec(`UPDATE ` + table + ` SET group_id = NULL WHERE btrim(group_id, '/ ') = ''`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`UPDATE ` + table + ` SET group_id = '/' || regexp_replace(btrim(group_id, '/ '), '/+', '/', 'g')
+				WHERE group_id IS NOT NULL AND group_id <> '/' || regexp_replace(btrim(group_id, '/ '), '/+', '/', 'g')`).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// JSON est une colonne jsonb sérialisée telle quelle dans les réponses API.
+type JSON json.RawMessage
+
+// NewJSON encode v en JSON.
+func NewJSON(v any) (JSON, error) {
+	b, err := json.Marshal(v)
+	return JSON(b), err
+}
+
+// Value implémente driver.Valuer.
+func (j JSON) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+// Scan implémente sql.Scanner.
+func (j *JSON) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*j = nil
+	case []byte:
+		*j = append((*j)[:0], v...)
+	case string:
+		*j = JSON(v)
+	default:
+		return errors.New("db: unsupported type for JSON column")
+	}
+	return nil
+}
+
+// MarshalJSON implémente json.Marshaler.
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+// UnmarshalJSON implémente json.Unmarshaler.
+func (j *JSON) UnmarshalJSON(data []byte) error {
+	*j = append((*j)[:0], data...)
+	return nil
+}
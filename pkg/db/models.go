@@ -13,11 +13,22 @@ type Project struct {
 	UserID       string  `gorm:"index;not null" json:"user_id"`   // Keycloak sub
 	GroupID      *string `gorm:"index" json:"group_id,omitempty"` // Groupe optionnel
 	Name         string  `gorm:"not null" json:"name"`
-	GithubToken  *string `json:"github_token,omitempty"`
+	GithubToken  *string `json:"-"`                                  // jamais sérialisé (lu par les workers et les appels GitHub)
 	GithubRepo   *string `gorm:"index" json:"github_repo,omitempty"` // owner/repo
 	GithubURL    *string `json:"github_url,omitempty"`               // https://github.com/owner/repo
 	Subscription *int    `json:"subscription_used,omitempty"`        // nombre d'abonnements utilisés
 
+	// Plateformes buildées pour chaque pull request (ex: "ANDROID,IOS"), vide = pas de preview
+	PreviewPlatforms *string `gorm:"size:128" json:"preview_platforms,omitempty"`
+
+	// Monorepo: dossier du projet dans le dépôt (vide = racine) et globs relatifs à ce dossier
+	// (ex: "lib/**,pubspec.yaml") qu'un push doit toucher pour déclencher un build
+	RootDir     *string `json:"root_dir,omitempty"`
+	PathFilters *string `json:"path_filters,omitempty"`
+
+	// Clé publique identifiant l'application auprès de l'update checker
+	AppKey *string `gorm:"size:64;uniqueIndex" json:"app_key,omitempty"`
+
 	Stats   []ProjectStats `gorm:"foreignKey:ProjectID" json:"-"`
 	Usages  []ProjectUsage `gorm:"foreignKey:ProjectID" json:"-"`
 	Branches []Branch      `gorm:"foreignKey:ProjectID" json:"-"`
@@ -58,6 +69,13 @@ type Branch struct {
 
 	ProjectID string `gorm:"type:uuid;index;not null" json:"project_id"`
 	Name      string `gorm:"not null" json:"name"`
+
+	// Branche éphémère créée pour une pull request, supprimée à sa fermeture
+	// (unique par projet : idx_branch_project_pull_request)
+	PullRequest *int    `gorm:"index" json:"pull_request,omitempty"`
+	Ephemeral   bool    `gorm:"not null;default:false" json:"ephemeral"`
+	HeadSHA     *string `gorm:"size:64" json:"head_sha,omitempty"`
+	CommentID   *int64  `json:"-"` // commentaire GitHub mis à jour avec les liens de téléchargement
 }
 
 // EnvVar représente une variable d'environnement (texte ou fichier)
@@ -84,13 +102,34 @@ type Build struct {
 	UpdatedAt time.Time `gorm:"not null;default:now()" json:"updated_at"`
 
 	ProjectID string  `gorm:"type:uuid;index;not null" json:"project_id"`
+	Number    int64   `gorm:"not null;default:0" json:"number"` // séquentiel par projet (BuildCounter)
 	BranchID  *string `gorm:"type:uuid;index" json:"branch_id,omitempty"`
 	Platform  string  `gorm:"index;size:16" json:"platform"`                          // IOS, ANDROID, LINUX, WINDOWS, MAC
-	Status    string  `gorm:"index;size:16;not null;default:'pending'" json:"status"` // pending, running, success, failed
+	Status    string  `gorm:"index;size:16;not null;default:'pending'" json:"status"` // pending, running, success, failed, cancelled
+	CommitSHA *string `gorm:"size:64;index" json:"commit_sha,omitempty"`
+
+	// Planification à l'origine du build (null si déclenché autrement)
+	ScheduleID *string `gorm:"type:uuid;index" json:"schedule_id,omitempty"`
+
+	// Worker ayant pris le build en charge
+	WorkerID  *string    `gorm:"type:uuid;index" json:"worker_id,omitempty"`
+	ClaimedAt *time.Time `json:"claimed_at,omitempty"`
 
 	DownloadURL string `gorm:"not null" json:"download_url"`
 
-	Logs []BuildLog `gorm:"foreignKey:BuildID" json:"-"`
+	// Configuration flotio.yaml résolue au commit du build (null si absente)
+	Config JSON `gorm:"type:jsonb" json:"config,omitempty"`
+
+	// Métadonnées extraites du paquet (IPA, APK ou AAB) après l'upload
+	AppID        *string `gorm:"size:255;index" json:"app_id,omitempty"` // bundle id / package
+	AppName      *string `json:"app_name,omitempty"`
+	VersionName  *string `gorm:"size:64" json:"version_name,omitempty"`
+	VersionCode  *string `gorm:"size:32" json:"version_code,omitempty"`
+	MinOSVersion *string `gorm:"size:32" json:"min_os_version,omitempty"` // iOS: MinimumOSVersion, Android: minSdkVersion
+	IconKey      *string `json:"-"`                                       // clé de l'icône PNG dans le stockage
+
+	Logs      []BuildLog      `gorm:"foreignKey:BuildID" json:"-"`
+	Artifacts []BuildArtifact `gorm:"foreignKey:BuildID" json:"artifacts,omitempty"`
 }
 
 // BuildLog stocke des lignes de log séquentielles pour un build
@@ -104,3 +143,224 @@ type BuildLog struct {
 	Seq  int    `gorm:"not null;index" json:"seq"`
 	Line string `gorm:"type:text;not null" json:"line"`
 }
+
+// BuildArtifact est un fichier produit par un build et conservé dans le stockage d'artefacts
+type BuildArtifact struct {
+	ID        string    `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"not null;default:now()" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null;default:now()" json:"updated_at"`
+
+	BuildID     string `gorm:"type:uuid;index;not null" json:"build_id"`
+	Kind        string `gorm:"index;size:16;not null;default:''" json:"kind"` // apk, aab, ipa, dmg, msi, appimage, symbols, report
+	Filename    string `gorm:"not null" json:"filename"`
+	ContentType string `gorm:"size:128" json:"content_type"`
+	Size        int64  `gorm:"not null;default:0" json:"size"`
+	SHA256      string `gorm:"size:64" json:"sha256"`
+	Status      string `gorm:"index;size:16;not null;default:'uploading'" json:"status"` // uploading, ready
+
+	StorageKey string  `gorm:"not null" json:"-"`
+	UploadID   *string `json:"-"` // upload multipart en cours
+}
+
+// InstallLink est un lien public d'installation OTA d'un build, protégé par un token secret
+type InstallLink struct {
+	ID        string    `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"not null;default:now()" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null;default:now()" json:"updated_at"`
+
+	BuildID   string     `gorm:"type:uuid;index;not null" json:"build_id"`
+	CreatedBy string     `gorm:"not null" json:"created_by"` // Keycloak sub
+	TokenHash string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Channel est un canal de distribution d'un projet (internal, beta, production...)
+type Channel struct {
+	ID        string    `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"not null;default:now()" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null;default:now()" json:"updated_at"`
+
+	ProjectID string `gorm:"type:uuid;not null;uniqueIndex:idx_channel_project_name" json:"project_id"`
+	Name      string `gorm:"size:64;not null;uniqueIndex:idx_channel_project_name" json:"name"`
+
+	// Version minimale supportée par plateforme ({"ANDROID": "1.4.0"}) : en dessous la mise à jour est obligatoire
+	MinVersions JSON `gorm:"type:jsonb" json:"min_versions,omitempty"`
+}
+
+// ChannelRelease est l'historique (audit) des promotions et rollbacks d'un canal.
+// Le build courant d'un canal pour une plateforme est la dernière promotion non annulée.
+type ChannelRelease struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+
+	ChannelID  string     `gorm:"type:uuid;index;not null" json:"channel_id"`
+	Platform   string     `gorm:"index;size:16;not null" json:"platform"`
+	Action     string     `gorm:"size:16;not null" json:"action"` // promote, rollback
+	BuildID    *string    `gorm:"type:uuid" json:"build_id"`      // build courant après l'action
+	ActorSub   string     `gorm:"not null" json:"actor_sub"`
+	Note       string     `json:"note,omitempty"`
+	RevertedAt *time.Time `json:"reverted_at,omitempty"` // promotion annulée par un rollback
+}
+
+// Schedule déclenche périodiquement (cron) les builds d'une branche d'un projet.
+type Schedule struct {
+	ID        string    `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"not null;default:now()" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null;default:now()" json:"updated_at"`
+
+	ProjectID string `gorm:"type:uuid;index;not null" json:"project_id"`
+	CreatedBy string `gorm:"not null" json:"created_by"` // Keycloak sub
+
+	Cron      string `gorm:"size:128;not null" json:"cron"`                   // ex: "0 2 * * 1-5"
+	TimeZone  string `gorm:"size:64;not null;default:'UTC'" json:"time_zone"` // ex: Europe/Paris
+	Branch    string `gorm:"not null" json:"branch"`
+	Platforms string `gorm:"size:128;not null" json:"platforms"` // ex: "ANDROID,IOS"
+	Enabled   bool   `gorm:"not null;default:true" json:"enabled"`
+
+	NextRunAt *time.Time `gorm:"index" json:"next_run_at,omitempty"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+}
+
+// ScheduleRun est l'historique des déclenchements d'une planification.
+type ScheduleRun struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"not null;default:now()" json:"created_at"`
+
+	ScheduleID   string    `gorm:"type:uuid;index;not null" json:"schedule_id"`
+	ScheduledFor time.Time `gorm:"not null" json:"scheduled_for"`
+	Status       string    `gorm:"size:16;not null" json:"status"` // queued, skipped, failed
+	CommitSHA    *string   `gorm:"size:64" json:"commit_sha,omitempty"`
+	Builds       int       `gorm:"not null;default:0" json:"builds"`
+	Message      string    `json:"message,omitempty"`
+}
+
+// Webhook est un endpoint HTTP notifié des évènements d'un projet.
+type Webhook struct {
+	ID        string    `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"not null;default:now()" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null;default:now()" json:"updated_at"`
+
+	ProjectID string `gorm:"type:uuid;index;not null" json:"project_id"`
+	CreatedBy string `gorm:"not null" json:"created_by"` // Keycloak sub
+	URL       string `gorm:"not null" json:"url"`
+	Secret    string `gorm:"not null" json:"-"`               // clé HMAC-SHA256 des livraisons
+	Events    string `gorm:"size:255;not null" json:"events"` // ex: "build.created,build.finished"
+	Active    bool   `gorm:"not null;default:true" json:"active"`
+}
+
+// WebhookDelivery est une livraison d'évènement à un webhook, avec ses tentatives.
+type WebhookDelivery struct {
+	ID        string    `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"not null;default:now()" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null;default:now()" json:"updated_at"`
+
+	WebhookID    string  `gorm:"type:uuid;index;not null" json:"webhook_id"`
+	Event        string  `gorm:"size:64;not null" json:"event"`
+	Payload      JSON    `gorm:"type:jsonb;not null" json:"payload"`
+	RedeliveryOf *string `gorm:"type:uuid" json:"redelivery_of,omitempty"`
+
+	Status        string     `gorm:"size:16;not null;default:'pending';index" json:"status"` // pending, succeeded, failed
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt *time.Time `gorm:"index" json:"next_attempt_at,omitempty"`
+	LastAttemptAt *time.Time `json:"last_attempt_at,omitempty"`
+	ResponseCode  *int       `json:"response_code,omitempty"`
+	ResponseBody  string     `json:"response_body,omitempty"` // tronquée
+	Error         string     `json:"error,omitempty"`
+	DurationMs    int64      `json:"duration_ms"`
+}
+
+// OutboxEvent est un évènement de domaine écrit dans la transaction de la modification
+// puis publié vers le broker par le relais (au moins une fois, dans l'ordre des id pour un même agrégat).
+type OutboxEvent struct {
+	ID        uint64    `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"not null;default:now()" json:"created_at"`
+
+	Type          string  `gorm:"size:64;not null" json:"type"`           // ex: build.finished
+	AggregateType string  `gorm:"size:32;not null" json:"aggregate_type"` // ex: build
+	AggregateID   string  `gorm:"size:64;not null;index" json:"aggregate_id"`
+	ProjectID     *string `gorm:"type:uuid;index" json:"project_id,omitempty"`
+	Payload       JSON    `gorm:"type:jsonb;not null" json:"payload"`
+
+	PublishedAt *time.Time `gorm:"index" json:"published_at,omitempty"`
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// APIToken est un token d'API personnel (CI, workers) utilisable à la place d'un JWT Keycloak.
+type APIToken struct {
+	ID        string    `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"not null;default:now()" json:"created_at"`
+
+	UserID    string `gorm:"index;not null" json:"user_id"` // Keycloak sub du propriétaire
+	Name      string `gorm:"not null" json:"name"`
+	Prefix    string `gorm:"size:16;not null" json:"prefix"` // début du token, pour l'identifier
+	TokenHash string `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	Scopes    string `gorm:"not null;default:''" json:"scopes"`  // séparés par des espaces, comme le claim scope
+	Groups    JSON   `gorm:"type:jsonb" json:"groups,omitempty"` // claim groups du créateur, figé à la création
+
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Worker est un worker de build, authentifié par un token enregistré ou par un client
+// Keycloak (client credentials). Il n'agit que sur les builds qu'il a pris en charge.
+type Worker struct {
+	ID        string    `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"not null;default:now()" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null;default:now()" json:"updated_at"`
+
+	Name        string  `gorm:"not null" json:"name"`
+	CreatedBy   *string `json:"created_by,omitempty"`                   // Keycloak sub, null si auto-enregistré
+	ClientID    *string `gorm:"uniqueIndex" json:"client_id,omitempty"` // client Keycloak (azp)
+	TokenHash   *string `gorm:"size:64;uniqueIndex" json:"-"`
+	TokenPrefix *string `gorm:"size:16" json:"token_prefix,omitempty"`
+
+	// claim groups du créateur à la création : le worker ne prend que les builds de ses projets
+	CreatorGroups JSON `gorm:"type:jsonb" json:"-"`
+
+	Platforms  string     `gorm:"size:128;not null;default:''" json:"platforms"` // ex: "ANDROID,IOS"
+	Version    string     `gorm:"size:64;not null;default:''" json:"version,omitempty"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ImportJob suit un import en masse de dépôts GitHub exécuté en arrière-plan
+type ImportJob struct {
+	ID        string    `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"not null;default:now()" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null;default:now()" json:"updated_at"`
+
+	UserID     string     `gorm:"index;not null" json:"user_id"` // Keycloak sub
+	GroupID    *string    `json:"group_id,omitempty"`
+	Owner      string     `gorm:"not null" json:"owner"`                                  // org ou utilisateur GitHub
+	Status     string     `gorm:"index;size:16;not null;default:'pending'" json:"status"` // pending, running, done, failed
+	Error      *string    `json:"error,omitempty"`
+	Total      int        `gorm:"not null;default:0" json:"total"`
+	Imported   int        `gorm:"not null;default:0" json:"imported"`
+	Skipped    int        `gorm:"not null;default:0" json:"skipped"`
+	Failed     int        `gorm:"not null;default:0" json:"failed"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	Items []ImportJobItem `gorm:"foreignKey:JobID" json:"items,omitempty"`
+}
+
+// ImportJobItem est le résultat de l'import d'un dépôt
+type ImportJobItem struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	JobID     string  `gorm:"type:uuid;index;not null" json:"job_id"`
+	FullName  string  `gorm:"not null" json:"full_name"`
+	Status    string  `gorm:"size:16;not null" json:"status"` // imported, skipped, failed
+	ProjectID *string `gorm:"type:uuid" json:"project_id,omitempty"`
+	Message   string  `json:"message,omitempty"`
+}
+
+// RateLimitBucket est un seau de jetons partagé du limiteur de débit (backend postgres)
+type RateLimitBucket struct {
+	Key       string    `gorm:"primaryKey"` // user:<sub>, token:<id>, worker:<id>, ip:<adresse> ou auth:<adresse>
+	Tokens    float64   `gorm:"type:double precision;not null"`
+	UpdatedAt time.Time `gorm:"not null;index"`
+}
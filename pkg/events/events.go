@@ -0,0 +1,52 @@
+// Package events publie les évènements de domaine enregistrés dans l'outbox vers un broker.
+package events
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Message est un évènement de domaine prêt à être publié.
+type Message struct {
+	ID            string // identifiant unique (déduplication côté consommateur)
+	Type          string // ex: build.created
+	AggregateType string // ex: build
+	AggregateID   string // clé d'ordonnancement : les évènements d'un agrégat sont publiés dans l'ordre
+	OccurredAt    time.Time
+	Payload       []byte // JSON
+}
+
+// Broker publie des messages. Publish ne retourne qu'une fois le message accepté par le broker.
+type Broker interface {
+	Publish(ctx context.Context, m Message) error
+	Close() error
+}
+
+// Options configure les adaptateurs de New.
+type Options struct {
+	NATSURL       string
+	SubjectPrefix string // NATS: le sujet est <prefix>.<type>
+	KafkaBrokers  string // liste "host:9092,host2:9092"
+	KafkaTopic    string
+}
+
+// New construit le broker nommé (nats, kafka ou memory).
+func New(kind string, o Options) (Broker, error) {
+	switch kind {
+	case "nats":
+		return NewNATS(o.NATSURL, o.SubjectPrefix)
+	case "kafka":
+		var brokers []string
+		for _, b := range strings.Split(o.KafkaBrokers, ",") {
+			if b = strings.TrimSpace(b); b != "" {
+				brokers = append(brokers, b)
+			}
+		}
+		return NewKafka(brokers, o.KafkaTopic)
+	case "memory":
+		return NewMemory(), nil
+	}
+	return nil, fmt.Errorf("unknown event broker %q (expected nats, kafka or memory)", kind)
+}
@@ -0,0 +1,50 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Kafka publie sur un topic avec l'id d'agrégat comme clé : les évènements d'un
+// agrégat tombent dans la même partition et gardent leur ordre.
+type Kafka struct {
+	w *kafka.Writer
+}
+
+// NewKafka crée un producteur synchrone (acks de tous les réplicas).
+func NewKafka(brokers []string, topic string) (*Kafka, error) {
+	if len(brokers) == 0 {
+		return nil, errors.New("kafka: no broker configured")
+	}
+	if topic == "" {
+		topic = "flotio.events"
+	}
+	return &Kafka{w: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		MaxAttempts:  3,
+		BatchTimeout: 10 * time.Millisecond,
+	}}, nil
+}
+
+// Publish implémente Broker.
+func (k *Kafka) Publish(ctx context.Context, m Message) error {
+	return k.w.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(m.AggregateID),
+		Value: m.Payload,
+		Time:  m.OccurredAt,
+		Headers: []kafka.Header{
+			{Key: "id", Value: []byte(m.ID)},
+			{Key: "type", Value: []byte(m.Type)},
+			{Key: "aggregate_type", Value: []byte(m.AggregateType)},
+		},
+	})
+}
+
+// Close implémente Broker.
+func (k *Kafka) Close() error { return k.w.Close() }
@@ -0,0 +1,40 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory garde les messages publiés en mémoire (tests et développement).
+type Memory struct {
+	mu       sync.Mutex
+	messages []Message
+	// Fail, si défini, est appelé avant chaque publication et peut la faire échouer.
+	Fail func(Message) error
+}
+
+// NewMemory crée un broker en mémoire.
+func NewMemory() *Memory { return &Memory{} }
+
+// Publish implémente Broker.
+func (m *Memory) Publish(_ context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Fail != nil {
+		if err := m.Fail(msg); err != nil {
+			return err
+		}
+	}
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages retourne une copie des messages publiés, dans l'ordre.
+func (m *Memory) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+// Close implémente Broker.
+func (m *Memory) Close() error { return nil }
@@ -0,0 +1,61 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATS publie sur <prefix>.<type> via JetStream quand un stream couvre le sujet
+// (accusé de réception du serveur), sinon en NATS core avec un flush.
+type NATS struct {
+	conn   *nats.Conn
+	js     nats.JetStreamContext
+	prefix string
+}
+
+// NewNATS se connecte au serveur NATS.
+func NewNATS(url, prefix string) (*NATS, error) {
+	if url == "" {
+		url = nats.DefaultURL
+	}
+	if prefix == "" {
+		prefix = "flotio"
+	}
+	conn, err := nats.Connect(url, nats.Name("project-service"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, err
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &NATS{conn: conn, js: js, prefix: prefix}, nil
+}
+
+// Publish implémente Broker.
+func (n *NATS) Publish(ctx context.Context, m Message) error {
+	msg := nats.NewMsg(n.prefix + "." + m.Type)
+	msg.Data = m.Payload
+	msg.Header.Set(nats.MsgIdHdr, m.ID) // déduplication JetStream
+	msg.Header.Set("Aggregate-Type", m.AggregateType)
+	msg.Header.Set("Aggregate-Id", m.AggregateID)
+	msg.Header.Set("Occurred-At", m.OccurredAt.UTC().Format(time.RFC3339Nano))
+	if _, err := n.js.PublishMsg(msg, nats.Context(ctx)); err == nil {
+		return nil
+	} else if !errors.Is(err, nats.ErrNoStreamResponse) {
+		return err
+	}
+	if err := n.conn.PublishMsg(msg); err != nil {
+		return err
+	}
+	return n.conn.FlushWithContext(ctx)
+}
+
+// Close implémente Broker.
+func (n *NATS) Close() error {
+	return n.conn.Drain()
+}
@@ -0,0 +1,207 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/flotio-dev/project-service/pkg/db"
+	"gorm.io/gorm"
+)
+
+// relayLockKey est l'advisory lock qui garantit un seul relais actif entre les réplicas,
+// condition de l'ordre de publication par agrégat.
+const relayLockKey = 0x666c6f74_6f757462 // "flotoutb"
+
+// envelope est le corps JSON publié pour chaque évènement.
+type envelope struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   string          `json:"aggregate_id"`
+	ProjectID     *string         `json:"project_id,omitempty"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// Relay publie les évènements de l'outbox non encore publiés.
+type Relay struct {
+	DB        *gorm.DB
+	Broker    Broker
+	BatchSize int           // défaut 100
+	Retention time.Duration // durée de conservation des évènements publiés (défaut 7 jours)
+}
+
+// Run relaie l'outbox toutes les interval jusqu'à l'annulation de ctx.
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	lastPurge := time.Time{}
+	for {
+		if _, err := r.RelayOnce(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("outbox: %v", err)
+		}
+		if time.Since(lastPurge) > time.Hour {
+			if err := r.purge(ctx); err != nil {
+				log.Printf("outbox: purge: %v", err)
+			}
+			lastPurge = time.Now()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// RelayOnce parcourt l'outbox par lots et retourne le nombre d'évènements publiés.
+// Un échec bloque les évènements suivants du même agrégat jusqu'au prochain passage ;
+// les lots suivants excluent cet agrégat, et ceux des autres agrégats sont publiés normalement.
+// Un lot sans aucune publication dont plusieurs agrégats ont échoué signale un broker
+// indisponible : le passage s'arrête et reprend à l'intervalle suivant.
+//
+// Les id sont attribués à l'insertion et non au commit : un évènement d'id plus petit peut devenir
+// visible après un id plus grand, et il est alors publié au passage suivant. L'ordre n'est donc
+// garanti que par agrégat, parce que les émetteurs écrivent l'évènement après avoir modifié la
+// ligne de l'agrégat : le verrou de ligne, tenu jusqu'au commit, sérialise les transactions sur
+// cet agrégat et leurs id de l'outbox. Aucun ordre global entre agrégats n'est garanti.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	p := pass{blocked: map[aggregate]bool{}}
+	for !p.done {
+		if err := ctx.Err(); err != nil {
+			return p.published, err
+		}
+		err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var locked bool
+			if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", relayLockKey).Scan(&locked).Error; err != nil {
+				return err
+			}
+			if !locked {
+				p.done = true
+				return nil
+			}
+			return p.batch(ctx, r.Broker, gormOutbox{tx}, r.batchSize())
+		})
+		if err != nil {
+			return p.published, err
+		}
+	}
+	return p.published, nil
+}
+
+// aggregate identifie un agrégat, clé d'ordonnancement des évènements.
+type aggregate struct{ Type, ID string }
+
+// outbox accède aux évènements en attente pendant un lot.
+type outbox interface {
+	// pending retourne au plus limit évènements non publiés d'id supérieur à after,
+	// hors agrégats exclus, par id croissant.
+	pending(after uint64, exclude []aggregate, limit int) ([]db.OutboxEvent, error)
+	failed(ev db.OutboxEvent, err error) error
+	markPublished(ids []uint64) error
+}
+
+// pass est l'état d'un passage sur l'outbox, conservé d'un lot à l'autre.
+type pass struct {
+	after     uint64 // dernier id lu
+	blocked   map[aggregate]bool
+	published int
+	done      bool
+}
+
+// batch publie le lot suivant du passage.
+func (p *pass) batch(ctx context.Context, broker Broker, store outbox, limit int) error {
+	exclude := make([]aggregate, 0, len(p.blocked))
+	for a := range p.blocked {
+		exclude = append(exclude, a)
+	}
+	evs, err := store.pending(p.after, exclude, limit)
+	if err != nil {
+		return err
+	}
+	var published []uint64
+	failures := 0
+	for _, ev := range evs {
+		p.after = ev.ID
+		key := aggregate{ev.AggregateType, ev.AggregateID}
+		if p.blocked[key] {
+			continue
+		}
+		if err := broker.Publish(ctx, message(ev)); err != nil {
+			p.blocked[key] = true
+			failures++
+			if err := store.failed(ev, err); err != nil {
+				return err
+			}
+			continue
+		}
+		published = append(published, ev.ID)
+	}
+	p.done = len(evs) < limit || (len(published) == 0 && failures > 1)
+	if len(published) == 0 {
+		return nil
+	}
+	if err := store.markPublished(published); err != nil {
+		return err
+	}
+	p.published += len(published)
+	return nil
+}
+
+// gormOutbox est l'outbox Postgres, dans la transaction qui tient le verrou du relais.
+type gormOutbox struct{ tx *gorm.DB }
+
+func (o gormOutbox) pending(after uint64, exclude []aggregate, limit int) ([]db.OutboxEvent, error) {
+	q := o.tx.Where("published_at IS NULL AND id > ?", after)
+	if len(exclude) > 0 {
+		keys := make([][]any, len(exclude))
+		for i, a := range exclude {
+			keys[i] = []any{a.Type, a.ID}
+		}
+		q = q.Where("(aggregate_type, aggregate_id) NOT IN ?", keys)
+	}
+	var evs []db.OutboxEvent
+	err := q.Order("id ASC").Limit(limit).Find(&evs).Error
+	return evs, err
+}
+
+func (o gormOutbox) failed(ev db.OutboxEvent, err error) error {
+	return o.tx.Model(&ev).Updates(map[string]any{"attempts": ev.Attempts + 1, "last_error": err.Error()}).Error
+}
+
+func (o gormOutbox) markPublished(ids []uint64) error {
+	return o.tx.Model(&db.OutboxEvent{}).Where("id IN ?", ids).Update("published_at", time.Now()).Error
+}
+
+func (r *Relay) purge(ctx context.Context) error {
+	retention := r.Retention
+	if retention <= 0 {
+		retention = 7 * 24 * time.Hour
+	}
+	return r.DB.WithContext(ctx).Where("published_at < ?", time.Now().Add(-retention)).Delete(&db.OutboxEvent{}).Error
+}
+
+func (r *Relay) batchSize() int {
+	if r.BatchSize <= 0 {
+		return 100
+	}
+	return r.BatchSize
+}
+
+// message convertit une ligne de l'outbox en message publiable.
+func message(ev db.OutboxEvent) Message {
+	id := strconv.FormatUint(ev.ID, 10)
+	payload, _ := json.Marshal(envelope{
+		ID:            id,
+		Type:          ev.Type,
+		AggregateType: ev.AggregateType,
+		AggregateID:   ev.AggregateID,
+		ProjectID:     ev.ProjectID,
+		OccurredAt:    ev.CreatedAt,
+		Data:          json.RawMessage(ev.Payload),
+	})
+	return Message{ID: id, Type: ev.Type, AggregateType: ev.AggregateType, AggregateID: ev.AggregateID, OccurredAt: ev.CreatedAt, Payload: payload}
+}
@@ -0,0 +1,163 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/flotio-dev/project-service/pkg/db"
+)
+
+// fakeOutbox est une outbox en mémoire qui reproduit les requêtes de gormOutbox.
+type fakeOutbox struct {
+	events []db.OutboxEvent
+	reads  int
+}
+
+func (o *fakeOutbox) add(aggID string, n int) {
+	for range n {
+		o.events = append(o.events, db.OutboxEvent{ID: uint64(len(o.events) + 1), Type: "build.updated", AggregateType: "build", AggregateID: aggID, Payload: db.JSON(`{}`)})
+	}
+}
+
+func (o *fakeOutbox) pending(after uint64, exclude []aggregate, limit int) ([]db.OutboxEvent, error) {
+	o.reads++
+	var out []db.OutboxEvent
+	for _, ev := range o.events {
+		if ev.PublishedAt == nil && ev.ID > after && !slices.Contains(exclude, aggregate{ev.AggregateType, ev.AggregateID}) && len(out) < limit {
+			out = append(out, ev)
+		}
+	}
+	return out, nil
+}
+
+func (o *fakeOutbox) failed(ev db.OutboxEvent, err error) error {
+	o.events[ev.ID-1].Attempts++
+	o.events[ev.ID-1].LastError = err.Error()
+	return nil
+}
+
+func (o *fakeOutbox) markPublished(ids []uint64) error {
+	now := time.Now()
+	for _, id := range ids {
+		o.events[id-1].PublishedAt = &now
+	}
+	return nil
+}
+
+// relayPass joue un passage complet comme RelayOnce.
+func relayPass(t *testing.T, broker Broker, store outbox, limit int) int {
+	t.Helper()
+	p := pass{blocked: map[aggregate]bool{}}
+	for i := 0; !p.done; i++ {
+		if i > 1000 {
+			t.Fatal("pass does not terminate")
+		}
+		if err := p.batch(context.Background(), broker, store, limit); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return p.published
+}
+
+func failAggregate(ids ...string) func(Message) error {
+	return func(m Message) error {
+		if slices.Contains(ids, m.AggregateID) {
+			return errors.New("broker unavailable")
+		}
+		return nil
+	}
+}
+
+func TestRelayPass(t *testing.T) {
+	tests := []struct {
+		name      string
+		events    map[string]int // agrégat -> nombre d'évènements, ajoutés dans l'ordre de order
+		order     []string
+		limit     int
+		fail      []string // agrégats dont la publication échoue
+		published int
+		maxReads  int
+	}{
+		{"everything published in one pass", map[string]int{"a": 3, "b": 2}, []string{"a", "b"}, 2, nil, 5, 3},
+		{"blocked aggregate does not starve others", map[string]int{"a": 10, "b": 3}, []string{"a", "b"}, 4, []string{"a"}, 3, 3},
+		{"broker down stops the pass", map[string]int{"a": 1, "b": 1, "c": 1, "d": 1}, []string{"a", "b", "c", "d"}, 2, []string{"a", "b", "c", "d"}, 0, 1},
+		{"empty outbox", nil, nil, 10, nil, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeOutbox{}
+			for _, id := range tt.order {
+				store.add(id, tt.events[id])
+			}
+			broker := NewMemory()
+			broker.Fail = failAggregate(tt.fail...)
+			if got := relayPass(t, broker, store, tt.limit); got != tt.published {
+				t.Errorf("published %d, want %d", got, tt.published)
+			}
+			if store.reads > tt.maxReads {
+				t.Errorf("%d reads, want at most %d", store.reads, tt.maxReads)
+			}
+			for _, m := range broker.Messages() {
+				if slices.Contains(tt.fail, m.AggregateID) {
+					t.Errorf("message of failing aggregate %s published", m.AggregateID)
+				}
+			}
+		})
+	}
+}
+
+func TestRelayRetryKeepsOrder(t *testing.T) {
+	store := &fakeOutbox{}
+	store.add("a", 2)
+	store.add("b", 1)
+	store.add("a", 2)
+	broker := NewMemory()
+	down := true
+	broker.Fail = func(m Message) error {
+		if down && m.AggregateID == "a" {
+			return errors.New("timeout")
+		}
+		return nil
+	}
+
+	if got := relayPass(t, broker, store, 10); got != 1 {
+		t.Fatalf("first pass published %d, want 1", got)
+	}
+	if ev := store.events[0]; ev.Attempts != 1 || ev.LastError != "timeout" || ev.PublishedAt != nil {
+		t.Errorf("failed event = %+v", ev)
+	}
+	// les évènements suivants de a n'ont pas été tentés
+	if store.events[1].Attempts != 0 {
+		t.Error("event after a failure was attempted")
+	}
+
+	down = false
+	if got := relayPass(t, broker, store, 10); got != 4 {
+		t.Fatalf("second pass published %d, want 4", got)
+	}
+	var ids []string
+	for _, m := range broker.Messages() {
+		if m.AggregateID == "a" {
+			ids = append(ids, m.ID)
+		}
+	}
+	if want := []string{"1", "2", "4", "5"}; !slices.Equal(ids, want) {
+		t.Errorf("aggregate a published as %v, want %v", ids, want)
+	}
+	if got := relayPass(t, broker, store, 10); got != 0 {
+		t.Errorf("third pass published %d, want 0", got)
+	}
+}
+
+func TestMessage(t *testing.T) {
+	project := "p1"
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := message(db.OutboxEvent{ID: 42, CreatedAt: at, Type: "build.created", AggregateType: "build", AggregateID: "b1", ProjectID: &project, Payload: db.JSON(`{"n":1}`)})
+	want := `{"id":"42","type":"build.created","aggregate_type":"build","aggregate_id":"b1","project_id":"p1","occurred_at":"2026-01-01T00:00:00Z","data":{"n":1}}`
+	if m.ID != "42" || m.AggregateID != "b1" || string(m.Payload) != want {
+		t.Errorf("message = %+v, payload %s", m, m.Payload)
+	}
+}
@@ -0,0 +1,100 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DefaultBaseURL est l'URL de l'API REST GitHub.
+const DefaultBaseURL = "https://api.github.com"
+
+// Client est un client minimal de l'API REST GitHub authentifié par token.
+type Client struct {
+	Token   string
+	BaseURL string
+	HTTP    *http.Client
+}
+
+// NewClient construit un client GitHub avec un timeout raisonnable.
+func NewClient(token string) *Client {
+	return &Client{Token: token, BaseURL: DefaultBaseURL, HTTP: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Error est retournée quand GitHub répond avec un status non 2xx.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("github: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("github: status %d: %s", e.StatusCode, e.Message)
+}
+
+// do exécute une requête JSON et décode la réponse dans out (si non nil).
+func (c *Client) do(ctx context.Context, method, path string, in, out any) (*http.Response, error) {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		var e struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(io.LimitReader(res.Body, 64<<10)).Decode(&e)
+		return res, &Error{StatusCode: res.StatusCode, Message: e.Message}
+	}
+	if out != nil {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// CreateIssueComment poste un commentaire sur une issue ou une pull request et retourne son id.
+func (c *Client) CreateIssueComment(ctx context.Context, fullName string, number int, body string) (int64, error) {
+	var out struct {
+		ID int64 `json:"id"`
+	}
+	path := fmt.Sprintf("/repos/%s/issues/%d/comments", fullName, number)
+	if _, err := c.do(ctx, http.MethodPost, path, map[string]string{"body": body}, &out); err != nil {
+		return 0, err
+	}
+	return out.ID, nil
+}
+
+// UpdateIssueComment remplace le contenu d'un commentaire existant.
+func (c *Client) UpdateIssueComment(ctx context.Context, fullName string, commentID int64, body string) error {
+	path := fmt.Sprintf("/repos/%s/issues/comments/%d", fullName, commentID)
+	_, err := c.do(ctx, http.MethodPatch, path, map[string]string{"body": body}, nil)
+	return err
+}
@@ -0,0 +1,159 @@
+package github
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Repo est un dépôt tel que retourné par les endpoints de listing et de recherche.
+type Repo struct {
+	ID            int64     `json:"id"`
+	Name          string    `json:"name"`
+	FullName      string    `json:"full_name"`
+	HTMLURL       string    `json:"html_url"`
+	Description   string    `json:"description"`
+	Private       bool      `json:"private"`
+	Fork          bool      `json:"fork"`
+	Archived      bool      `json:"archived"`
+	Language      string    `json:"language"`
+	DefaultBranch string    `json:"default_branch"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Owner         struct {
+		Login string `json:"login"`
+		Type  string `json:"type"` // User, Organization
+	} `json:"owner"`
+}
+
+// maxListPages borne le nombre de pages parcourues par ListAll (100 dépôts par page).
+const maxListPages = 50
+
+// GetRepo récupère un dépôt par son nom complet owner/repo.
+func (c *Client) GetRepo(ctx context.Context, fullName string) (Repo, error) {
+	var r Repo
+	_, err := c.do(ctx, http.MethodGet, "/repos/"+fullName, nil, &r)
+	return r, err
+}
+
+// CurrentLogin retourne le login de l'utilisateur propriétaire du token.
+func (c *Client) CurrentLogin(ctx context.Context) (string, error) {
+	var u struct {
+		Login string `json:"login"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, "/user", nil, &u); err != nil {
+		return "", err
+	}
+	return u.Login, nil
+}
+
+// ListPage récupère une page d'un endpoint de listing de dépôts et indique s'il existe une page suivante.
+func (c *Client) ListPage(ctx context.Context, path string, query url.Values, page, perPage int) ([]Repo, bool, error) {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Set("page", fmt.Sprint(page))
+	q.Set("per_page", fmt.Sprint(perPage))
+	var repos []Repo
+	res, err := c.do(ctx, http.MethodGet, path+"?"+q.Encode(), nil, &repos)
+	if err != nil {
+		return nil, false, err
+	}
+	return repos, hasNextPage(res.Header.Get("Link")), nil
+}
+
+// ListAll parcourt toutes les pages d'un endpoint de listing de dépôts.
+func (c *Client) ListAll(ctx context.Context, path string, query url.Values) ([]Repo, error) {
+	var all []Repo
+	for page := 1; page <= maxListPages; page++ {
+		repos, next, err := c.ListPage(ctx, path, query, page, 100)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, repos...)
+		if !next {
+			break
+		}
+	}
+	return all, nil
+}
+
+// OwnerReposPath choisit l'endpoint listant les dépôts d'un owner.
+// Pour l'utilisateur du token, /user/repos inclut aussi les dépôts privés.
+func (c *Client) OwnerReposPath(ctx context.Context, owner, ownerType string) (string, url.Values, error) {
+	if ownerType == "org" {
+		return "/orgs/" + url.PathEscape(owner) + "/repos", url.Values{"type": {"all"}}, nil
+	}
+	login, err := c.CurrentLogin(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	if owner == "" || strings.EqualFold(owner, login) {
+		return "/user/repos", url.Values{"affiliation": {"owner"}}, nil
+	}
+	return "/users/" + url.PathEscape(owner) + "/repos", url.Values{"type": {"owner"}}, nil
+}
+
+// hasNextPage lit l'en-tête Link de pagination GitHub.
+func hasNextPage(link string) bool {
+	for _, part := range strings.Split(link, ",") {
+		if strings.Contains(part, `rel="next"`) {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchRepos interroge la recherche de dépôts GitHub et retourne le nombre total de résultats.
+func (c *Client) SearchRepos(ctx context.Context, query string, page, perPage int) ([]Repo, int, bool, error) {
+	q := url.Values{}
+	q.Set("q", query)
+	q.Set("page", fmt.Sprint(page))
+	q.Set("per_page", fmt.Sprint(perPage))
+	var out struct {
+		TotalCount int    `json:"total_count"`
+		Items      []Repo `json:"items"`
+	}
+	res, err := c.do(ctx, http.MethodGet, "/search/repositories?"+q.Encode(), nil, &out)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return out.Items, out.TotalCount, hasNextPage(res.Header.Get("Link")), nil
+}
+
+// GetFileContents lit un fichier d'un dépôt à une révision donnée (sha, branche ou tag).
+func (c *Client) GetFileContents(ctx context.Context, fullName, path, ref string) ([]byte, error) {
+	var out struct {
+		Type     string `json:"type"`
+		Encoding string `json:"encoding"`
+		Content  string `json:"content"`
+	}
+	p := "/repos/" + fullName + "/contents/" + strings.TrimPrefix(path, "/")
+	if ref != "" {
+		p += "?ref=" + url.QueryEscape(ref)
+	}
+	if _, err := c.do(ctx, http.MethodGet, p, nil, &out); err != nil {
+		return nil, err
+	}
+	if out.Type != "file" || out.Encoding != "base64" {
+		return nil, fmt.Errorf("github: %s is not a regular file", path)
+	}
+	return base64.StdEncoding.DecodeString(strings.ReplaceAll(out.Content, "\n", ""))
+}
+
+// GetBranchHead retourne le SHA du dernier commit d'une branche.
+func (c *Client) GetBranchHead(ctx context.Context, fullName, branch string) (string, error) {
+	var out struct {
+		Commit struct {
+			SHA string `json:"sha"`
+		} `json:"commit"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, "/repos/"+fullName+"/branches/"+url.PathEscape(branch), nil, &out); err != nil {
+		return "", err
+	}
+	return out.Commit.SHA, nil
+}
@@ -0,0 +1,103 @@
+package github
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// En-têtes envoyés par GitHub avec chaque webhook.
+const (
+	HeaderEvent     = "X-GitHub-Event"
+	HeaderDelivery  = "X-GitHub-Delivery"
+	HeaderSignature = "X-Hub-Signature-256"
+)
+
+// VerifySignature vérifie la signature HMAC-SHA256 ("sha256=<hex>") d'un payload.
+func VerifySignature(secret, payload []byte, header string) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok || len(secret) == 0 {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// Repository est la représentation d'un dépôt dans les payloads GitHub.
+type Repository struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	FullName string `json:"full_name"`
+	HTMLURL  string `json:"html_url"`
+}
+
+// PullRequestEvent est le payload de l'évènement "pull_request".
+type PullRequestEvent struct {
+	Action      string     `json:"action"` // opened, synchronize, reopened, closed, ...
+	Number      int        `json:"number"`
+	Repository  Repository `json:"repository"`
+	PullRequest struct {
+		Number  int    `json:"number"`
+		Title   string `json:"title"`
+		HTMLURL string `json:"html_url"`
+		Merged  bool   `json:"merged"`
+		Head    struct {
+			Ref  string      `json:"ref"`
+			SHA  string      `json:"sha"`
+			Repo *Repository `json:"repo"` // null si le fork a été supprimé
+		} `json:"head"`
+	} `json:"pull_request"`
+}
+
+// FromFork indique que la branche de la PR vient d'un autre dépôt (fork, éventuellement supprimé).
+func (e PullRequestEvent) FromFork() bool {
+	head := e.PullRequest.Head.Repo
+	return head == nil || head.ID != e.Repository.ID
+}
+
+// PushEvent est le payload de l'évènement "push".
+type PushEvent struct {
+	Ref        string     `json:"ref"` // refs/heads/main
+	Before     string     `json:"before"`
+	After      string     `json:"after"`
+	Created    bool       `json:"created"`
+	Deleted    bool       `json:"deleted"`
+	Forced     bool       `json:"forced"`
+	Repository Repository `json:"repository"`
+	Commits    []struct {
+		ID       string   `json:"id"`
+		Added    []string `json:"added"`
+		Removed  []string `json:"removed"`
+		Modified []string `json:"modified"`
+	} `json:"commits"`
+}
+
+// MaxPushCommits est le nombre maximal de commits détaillés dans un évènement push :
+// au-delà, GitHub tronque la liste.
+const MaxPushCommits = 20
+
+// Truncated indique que la liste des commits peut être incomplète (ChangedFiles aussi).
+func (e PushEvent) Truncated() bool { return len(e.Commits) >= MaxPushCommits }
+
+// ChangedFiles retourne l'ensemble des fichiers touchés par les commits du push.
+func (e PushEvent) ChangedFiles() []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, c := range e.Commits {
+		for _, list := range [][]string{c.Added, c.Removed, c.Modified} {
+			for _, f := range list {
+				if !seen[f] {
+					seen[f] = true
+					out = append(out, f)
+				}
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,63 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPushEventChangedFiles(t *testing.T) {
+	var ev PushEvent
+	err := json.Unmarshal([]byte(`{"commits":[
+		{"added":["a.go"],"modified":["b.go"]},
+		{"removed":["a.go"],"modified":["c.go"]}
+	]}`), &ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ev.ChangedFiles(), []string{"a.go", "b.go", "c.go"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if ev.Truncated() {
+		t.Fatal("2 commits is not truncated")
+	}
+}
+
+func TestPushEventTruncated(t *testing.T) {
+	commits := make([]string, MaxPushCommits)
+	for i := range commits {
+		commits[i] = fmt.Sprintf(`{"id":"%d","modified":["f%d"]}`, i, i)
+	}
+	var ev PushEvent
+	if err := json.Unmarshal([]byte(`{"commits":[`+strings.Join(commits, ",")+`]}`), &ev); err != nil {
+		t.Fatal(err)
+	}
+	if !ev.Truncated() {
+		t.Fatalf("%d commits may be truncated by GitHub", MaxPushCommits)
+	}
+}
+
+func TestPullRequestEventFromFork(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    bool
+	}{
+		{"same repository", `{"repository":{"id":1},"pull_request":{"head":{"repo":{"id":1}}}}`, false},
+		{"fork", `{"repository":{"id":1},"pull_request":{"head":{"repo":{"id":2}}}}`, true},
+		{"deleted fork", `{"repository":{"id":1},"pull_request":{"head":{"repo":null}}}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ev PullRequestEvent
+			if err := json.Unmarshal([]byte(tt.payload), &ev); err != nil {
+				t.Fatal(err)
+			}
+			if got := ev.FromFork(); got != tt.want {
+				t.Errorf("FromFork() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package httpx
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 )
@@ -9,6 +10,7 @@ import (
 type ErrorResponse struct {
 	Error       string `json:"error"`
 	Description string `json:"description,omitempty"`
+	Details     any    `json:"details,omitempty"`
 }
 
 type SuccessResponse[T any] struct {
@@ -31,6 +33,10 @@ func OK[T any](w http.ResponseWriter, v T) { writeJSON(w, http.StatusOK, Success
 func Created[T any](w http.ResponseWriter, v T) {
 	writeJSON(w, http.StatusCreated, SuccessResponse[T]{Data: v})
 }
+
+// JSON écrit v tel quel, sans enveloppe data (documents normalisés : JWKS, découverte OIDC).
+func JSON(w http.ResponseWriter, v any) { writeJSON(w, http.StatusOK, v) }
+
 func NoContent(w http.ResponseWriter) { writeJSON(w, http.StatusNoContent, nil) }
 
 // 3xx convenience (rare as JSON)
@@ -49,6 +55,12 @@ func BadRequest(w http.ResponseWriter, msg string) {
 func Unauthorized(w http.ResponseWriter, msg string) {
 	writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized", Description: msg})
 }
+
+// TokenRejected répond 401 avec un code d'erreur précis et l'en-tête WWW-Authenticate (RFC 6750).
+func TokenRejected(w http.ResponseWriter, code, msg string) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="invalid_token", error_description=%q`, msg))
+	writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: code, Description: msg})
+}
 func Forbidden(w http.ResponseWriter, msg string) {
 	writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "forbidden", Description: msg})
 }
@@ -58,8 +70,20 @@ func NotFound(w http.ResponseWriter, msg string) {
 func Conflict(w http.ResponseWriter, msg string) {
 	writeJSON(w, http.StatusConflict, ErrorResponse{Error: "conflict", Description: msg})
 }
+func Gone(w http.ResponseWriter, msg string) {
+	writeJSON(w, http.StatusGone, ErrorResponse{Error: "gone", Description: msg})
+}
+func TooManyRequests(w http.ResponseWriter, msg string) {
+	writeJSON(w, http.StatusTooManyRequests, ErrorResponse{Error: "rate_limited", Description: msg})
+}
+func UnprocessableEntity(w http.ResponseWriter, msg string, details any) {
+	writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{Error: "validation_failed", Description: msg, Details: details})
+}
 
 // 5xx
 func InternalError(w http.ResponseWriter, msg string) {
 	writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "internal_error", Description: msg})
 }
+func ServiceUnavailable(w http.ResponseWriter, msg string) {
+	writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: "service_unavailable", Description: msg})
+}
@@ -1,14 +1,21 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
 
 	"github.com/flotio-dev/project-service/pkg/auth"
 	"github.com/flotio-dev/project-service/pkg/httpx"
+	"github.com/golang-jwt/jwt/v4"
 )
 
-// RequireAuth vérifie le token Bearer via Keycloak JWKS et injecte sub/claims dans le contexte.
-func RequireAuth(p *auth.JWKSProvider, audience string) func(http.Handler) http.Handler {
+// APITokenValidator valide un token d'API et retourne des claims équivalentes à celles d'un JWT (sub, scope...).
+type APITokenValidator func(ctx context.Context, token string) (jwt.MapClaims, error)
+
+// RequireAuth vérifie le token Bearer (JWT Keycloak via JWKS, ou token d'API si apiTokens est fourni)
+// et injecte sub/claims dans le contexte. Les JWT doivent respecter policy.
+func RequireAuth(p *auth.JWKSProvider, policy auth.TokenPolicy, apiTokens APITokenValidator) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			tokenStr := auth.BearerFromHeader(r)
@@ -16,7 +23,22 @@ func RequireAuth(p *auth.JWKSProvider, audience string) func(http.Handler) http.
 				httpx.Unauthorized(w, "missing bearer token")
 				return
 			}
-			_, claims, err := p.ValidateToken(tokenStr, audience)
+			var claims jwt.MapClaims
+			var err error
+			switch {
+			case auth.IsAPIToken(tokenStr) && apiTokens != nil:
+				claims, err = apiTokens(r.Context(), tokenStr)
+				r = WithValue(r, ctxKeyAPIToken, true)
+			case p != nil:
+				_, claims, err = p.ValidateToken(r.Context(), tokenStr, policy)
+			default:
+				httpx.Unauthorized(w, "invalid token")
+				return
+			}
+			if te := (*auth.TokenError)(nil); errors.As(err, &te) {
+				httpx.TokenRejected(w, te.Code, te.Message)
+				return
+			}
 			if err != nil {
 				httpx.Unauthorized(w, err.Error())
 				return
@@ -25,7 +47,44 @@ func RequireAuth(p *auth.JWKSProvider, audience string) func(http.Handler) http.
 			r = WithValue(r, ctxKeyToken, tokenStr)
 			r = WithValue(r, ctxKeyClaims, claims)
 			r = WithValue(r, ctxKeySub, sub)
+			r = WithValue(r, ctxKeyScopes, auth.ScopesFromClaims(claims, policy.RoleClients(claims)...))
 			next.ServeHTTP(w, r)
 		})
 	}
 }
+
+// RequireScope n'exécute next que si l'appelant authentifié dispose du scope.
+func RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !HasScope(r, scope) {
+			httpx.Forbidden(w, "missing scope "+scope)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// HasScope indique si l'appelant dispose du scope (toujours faux sans authentification).
+func HasScope(r *http.Request, scope string) bool {
+	granted, ok := GetValue[[]string](r, ctxKeyScopes)
+	return ok && auth.HasScope(granted, scope)
+}
+
+// FromAPIToken indique si les claims de l'appelant viennent d'un token d'API ou de worker
+// validé en base, et non d'un JWT dont l'émetteur choisit les claims.
+func FromAPIToken(r *http.Request) bool {
+	v, _ := GetValue[bool](r, ctxKeyAPIToken)
+	return v
+}
+
+// WithWorker marque l'appelant comme le worker de build workerID, avec les scopes donnés.
+func WithWorker(r *http.Request, workerID string, scopes []string) *http.Request {
+	r = WithValue(r, ctxKeyWorker, workerID)
+	return WithValue(r, ctxKeyScopes, scopes)
+}
+
+// WorkerID retourne l'id du worker appelant, s'il s'agit d'un worker.
+func WorkerID(r *http.Request) (string, bool) {
+	id, ok := GetValue[string](r, ctxKeyWorker)
+	return id, ok && id != ""
+}
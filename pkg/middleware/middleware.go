@@ -22,6 +22,11 @@ const (
 	ctxKeyToken  contextKey = "token"
 	ctxKeyClaims contextKey = "claims"
 	ctxKeySub    contextKey = "sub"
+	ctxKeyScopes contextKey = "scopes"
+	ctxKeyWorker contextKey = "worker"
+	// ctxKeyAPIToken marque les claims produites par le validateur de tokens d'API (base),
+	// et non lues dans un JWT : elles seules peuvent désigner un worker ou un token d'API.
+	ctxKeyAPIToken contextKey = "api_token"
 )
 
 // WithValue ajoute une valeur dans le contexte.
@@ -43,23 +48,33 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		// response status capture
-		rw := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		rw := NewStatusRecorder(w)
 		next.ServeHTTP(rw, r)
 		dur := time.Since(start)
-		log.Printf("%s %s %s status=%d dur=%s", r.Method, r.RequestURI, r.RemoteAddr, rw.status, dur)
+		log.Printf("%s %s %s status=%d dur=%s", r.Method, r.RequestURI, r.RemoteAddr, rw.Status, dur)
 	})
 }
 
-// statusRecorder capture le status écrit par le handler
-type statusRecorder struct {
+// StatusRecorder capture le status écrit par le handler
+type StatusRecorder struct {
 	http.ResponseWriter
-	status int
+	Status int
 }
 
-func (sr *statusRecorder) WriteHeader(code int) {
-	sr.status = code
+// NewStatusRecorder enveloppe w ; Status vaut 200 tant que le handler n'a rien écrit.
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	return &StatusRecorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (sr *StatusRecorder) WriteHeader(code int) {
+	sr.Status = code
 	sr.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap expose le ResponseWriter d'origine à http.ResponseController (deadlines, flush).
+func (sr *StatusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
 // wrappers pour testabilité (remplacent time.Now/Since si besoin)
 // no wrappers needed; using time.Now and time.Since directly
@@ -0,0 +1,108 @@
+// Package pathfilter décide si un ensemble de fichiers modifiés concerne un projet d'un monorepo.
+package pathfilter
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Filter sélectionne les fichiers situés sous Root et correspondant à l'un des Patterns.
+// Les patterns sont relatifs à Root et acceptent *, ? et ** (n'importe quel nombre de dossiers).
+// Sans pattern, tout fichier sous Root correspond.
+type Filter struct {
+	Root     string
+	Patterns []string
+}
+
+// CleanRoot normalise un dossier racine ("./apps/mobile/" -> "apps/mobile", "/" -> "").
+func CleanRoot(root string) string {
+	root = strings.Trim(path.Clean("/"+strings.TrimSpace(root)), "/")
+	return root
+}
+
+// Split découpe une liste "a/**,b/*.dart" en patterns non vides.
+func Split(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Validate vérifie que chaque pattern peut être compilé.
+func Validate(patterns []string) error {
+	for _, p := range patterns {
+		if _, err := compile(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Match indique si au moins un des fichiers (chemins relatifs à la racine du dépôt) est concerné.
+func (f Filter) Match(files []string) bool {
+	root := CleanRoot(f.Root)
+	res := make([]*regexp.Regexp, 0, len(f.Patterns))
+	for _, p := range f.Patterns {
+		re, err := compile(p)
+		if err != nil {
+			continue
+		}
+		res = append(res, re)
+	}
+	for _, file := range files {
+		rel := strings.TrimPrefix(file, "/")
+		if root != "" {
+			if !strings.HasPrefix(rel, root+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(rel, root+"/")
+		}
+		if len(f.Patterns) == 0 {
+			return true
+		}
+		for _, re := range res {
+			if re.MatchString(rel) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compile traduit un glob en expression régulière ancrée.
+func compile(pattern string) (*regexp.Regexp, error) {
+	p := []rune(strings.TrimPrefix(strings.TrimSpace(pattern), "/"))
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case c == '*' && i+1 < len(p) && p[i+1] == '*':
+			i++
+			if i+1 < len(p) && p[i+1] == '/' {
+				i++
+				sb.WriteString("(?:.*/)?") // "**/" : zéro ou plusieurs dossiers
+			} else {
+				sb.WriteString(".*")
+			}
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	// un dossier ("src/" ou "src") couvre tout son contenu
+	if len(p) > 0 && p[len(p)-1] == '/' {
+		sb.WriteString(".*")
+	} else {
+		sb.WriteString("(?:/.*)?")
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
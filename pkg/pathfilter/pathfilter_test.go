@@ -0,0 +1,87 @@
+package pathfilter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCleanRoot(t *testing.T) {
+	tests := map[string]string{
+		"":               "",
+		"/":              "",
+		".":              "",
+		"./apps/mobile/": "apps/mobile",
+		" apps//web ":    "apps/web",
+		"../apps":        "apps",
+	}
+	for in, want := range tests {
+		if got := CleanRoot(in); got != want {
+			t.Errorf("CleanRoot(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	got := Split(" a/** ,, b/*.dart,")
+	if want := []string{"a/**", "b/*.dart"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if Split("") != nil {
+		t.Fatal("empty list expected")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		file   string
+		want   bool
+	}{
+		{"no filter", Filter{}, "any/file.go", true},
+		{"root only", Filter{Root: "apps/mobile"}, "apps/mobile/lib/main.dart", true},
+		{"outside root", Filter{Root: "apps/mobile"}, "apps/web/index.ts", false},
+		{"root prefix is not a folder", Filter{Root: "apps/mobile"}, "apps/mobile2/main.dart", false},
+		{"leading slash in file", Filter{Root: "apps"}, "/apps/x", true},
+		{"star stays in folder", Filter{Patterns: []string{"lib/*.dart"}}, "lib/a/b.dart", false},
+		{"star", Filter{Patterns: []string{"lib/*.dart"}}, "lib/main.dart", true},
+		{"question mark", Filter{Patterns: []string{"v?.txt"}}, "v1.txt", true},
+		{"question mark not slash", Filter{Patterns: []string{"a?b"}}, "a/b", false},
+		{"double star any depth", Filter{Patterns: []string{"**/*.dart"}}, "lib/a/b.dart", true},
+		{"double star zero folders", Filter{Patterns: []string{"**/*.dart"}}, "main.dart", true},
+		{"double star in middle", Filter{Patterns: []string{"lib/**/x.go"}}, "lib/x.go", true},
+		{"trailing double star", Filter{Patterns: []string{"assets/**"}}, "assets/img/a.png", true},
+		{"folder covers content", Filter{Patterns: []string{"src"}}, "src/a/b.c", true},
+		{"folder with slash", Filter{Patterns: []string{"src/"}}, "src/a", true},
+		{"folder prefix is not a folder", Filter{Patterns: []string{"src"}}, "srcs/a", false},
+		{"regexp metacharacters are literal", Filter{Patterns: []string{"a+b(1).txt"}}, "a+b(1).txt", true},
+		{"dot is literal", Filter{Patterns: []string{"a.txt"}}, "abtxt", false},
+		{"non-ASCII literal", Filter{Patterns: []string{"docs/été/*.md"}}, "docs/été/notes.md", true},
+		{"non-ASCII with wildcard", Filter{Patterns: []string{"资源/?.png"}}, "资源/图.png", true},
+		{"patterns relative to root", Filter{Root: "apps/mobile", Patterns: []string{"lib/**"}}, "apps/mobile/lib/a.dart", true},
+		{"pattern outside root", Filter{Root: "apps/mobile", Patterns: []string{"lib/**"}}, "lib/a.dart", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match([]string{tt.file}); got != tt.want {
+				t.Fatalf("Match(%q) = %v, want %v", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchAnyFile(t *testing.T) {
+	f := Filter{Root: "apps/web"}
+	if !f.Match([]string{"README.md", "apps/web/index.ts"}) {
+		t.Fatal("one matching file should be enough")
+	}
+	if f.Match(nil) {
+		t.Fatal("no file, no match")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate([]string{"a/**", "*.go", "[x"}); err != nil {
+		t.Fatalf("brackets are literal, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory garde les seaux en mémoire : limite propre à chaque réplica.
+type Memory struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	rate    Rate
+}
+
+// NewMemory crée un limiteur en mémoire.
+func NewMemory() *Memory {
+	return &Memory{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+// Allow implémente Limiter.
+func (m *Memory) Allow(_ context.Context, key string, cost int, rate Rate) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.sweep(now)
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rate.Burst), updated: now}
+		m.buckets[key] = b
+	}
+	b.rate = rate
+	var allowed bool
+	b.tokens, allowed = rate.take(b.tokens, now.Sub(b.updated), cost)
+	b.updated = now
+	return rate.result(b.tokens, allowed, cost), nil
+}
+
+// sweep oublie, au plus une fois par minute, les seaux de nouveau pleins.
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	m.lastSweep = now
+	for k, b := range m.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*b.rate.PerSecond >= float64(b.rate.Burst) {
+			delete(m.buckets, k)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// purgeInterval espace les purges des seaux inactifs.
+const purgeInterval = 10 * time.Minute
+
+// bucketRow est une ligne de rate_limit_buckets, lue avec l'horloge de la base.
+type bucketRow struct {
+	Tokens    float64
+	UpdatedAt time.Time
+	Now       time.Time
+}
+
+// Postgres partage les seaux entre réplicas (table rate_limit_buckets). Chaque demande
+// verrouille la ligne du seau, la recharge avec l'horloge de la base et la réécrit.
+type Postgres struct {
+	db        *gorm.DB
+	lastPurge atomic.Int64
+}
+
+// NewPostgres crée un limiteur partagé.
+func NewPostgres(db *gorm.DB) *Postgres { return &Postgres{db: db} }
+
+// Allow implémente Limiter.
+func (p *Postgres) Allow(ctx context.Context, key string, cost int, rate Rate) (Result, error) {
+	if cost == 0 {
+		return p.peek(ctx, key, rate)
+	}
+	var (
+		tokens  float64
+		allowed bool
+	)
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// un nouveau seau est plein
+		err := tx.Exec(`INSERT INTO rate_limit_buckets (key, tokens, updated_at) VALUES (?, ?, now())
+			ON CONFLICT (key) DO NOTHING`, key, float64(rate.Burst)).Error
+		if err != nil {
+			return err
+		}
+		var row bucketRow
+		err = tx.Table("rate_limit_buckets").Select("tokens, updated_at, now() AS now").
+			Where("key = ?", key).Clauses(clause.Locking{Strength: "UPDATE"}).Take(&row).Error
+		if err != nil {
+			return err
+		}
+		tokens, allowed = rate.take(row.Tokens, row.Now.Sub(row.UpdatedAt), cost)
+		return tx.Table("rate_limit_buckets").Where("key = ?", key).
+			Updates(map[string]any{"tokens": tokens, "updated_at": row.Now}).Error
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	p.purge()
+	return rate.result(tokens, allowed, cost), nil
+}
+
+// peek lit le seau sans le verrouiller ni l'écrire : une vérification sans débit ne doit
+// pas coûter une écriture par requête. Un seau absent est plein.
+func (p *Postgres) peek(ctx context.Context, key string, rate Rate) (Result, error) {
+	var rows []bucketRow
+	err := p.db.WithContext(ctx).Table("rate_limit_buckets").Select("tokens, updated_at, now() AS now").
+		Where("key = ?", key).Limit(1).Find(&rows).Error
+	if err != nil {
+		return Result{}, err
+	}
+	if len(rows) == 0 {
+		return rate.result(float64(rate.Burst), true, 0), nil
+	}
+	tokens, allowed := rate.take(rows[0].Tokens, rows[0].Now.Sub(rows[0].UpdatedAt), 0)
+	return rate.result(tokens, allowed, 0), nil
+}
+
+// purge supprime en arrière-plan les seaux inactifs depuis une heure.
+func (p *Postgres) purge() {
+	now := time.Now().UnixNano()
+	last := p.lastPurge.Load()
+	if now-last < int64(purgeInterval) || !p.lastPurge.CompareAndSwap(last, now) {
+		return
+	}
+	go p.db.Exec(`DELETE FROM rate_limit_buckets WHERE updated_at < now() - interval '1 hour'`)
+}
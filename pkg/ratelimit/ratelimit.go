@@ -0,0 +1,75 @@
+// Package ratelimit implémente un limiteur à seaux de jetons (token bucket), en mémoire
+// ou partagé entre réplicas via Postgres.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Rate décrit un seau : Burst jetons au plus, remplis à raison de PerSecond jetons par seconde.
+type Rate struct {
+	Burst     int
+	PerSecond float64
+}
+
+// PerMinute construit un Rate de n requêtes par minute avec une rafale de burst.
+func PerMinute(n, burst int) Rate { return Rate{Burst: burst, PerSecond: float64(n) / 60} }
+
+// Result est l'état du seau après une demande.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // avant que le seau soit de nouveau plein
+	RetryAfter time.Duration // avant que la demande refusée puisse passer (0 si acceptée)
+}
+
+// Limiter consomme cost jetons du seau key. Un cost de 0 vérifie sans débiter qu'il reste
+// au moins un jeton.
+type Limiter interface {
+	Allow(ctx context.Context, key string, cost int, rate Rate) (Result, error)
+}
+
+// New crée le limiteur du backend demandé : memory ou postgres.
+func New(backend string, db *gorm.DB) (Limiter, error) {
+	switch backend {
+	case "memory":
+		return NewMemory(), nil
+	case "postgres":
+		return NewPostgres(db), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend %q (expected memory or postgres)", backend)
+	}
+}
+
+// take recharge un seau qui contenait tokens jetons il y a elapsed, puis tente d'en débiter
+// cost. Retourne les jetons restants et si la demande est acceptée ; partagé par les backends.
+func (r Rate) take(tokens float64, elapsed time.Duration, cost int) (float64, bool) {
+	tokens = min(float64(r.Burst), tokens+max(elapsed.Seconds(), 0)*r.PerSecond)
+	if tokens < float64(max(cost, 1)) {
+		return tokens, false
+	}
+	return tokens - float64(cost), true
+}
+
+// result calcule les en-têtes à partir des jetons restants.
+func (r Rate) result(tokens float64, allowed bool, cost int) Result {
+	res := Result{Allowed: allowed, Limit: r.Burst, Remaining: max(int(math.Floor(tokens)), 0)}
+	if r.PerSecond <= 0 {
+		return res
+	}
+	res.Reset = seconds((float64(r.Burst) - tokens) / r.PerSecond)
+	if !allowed {
+		res.RetryAfter = seconds((float64(max(cost, 1)) - tokens) / r.PerSecond)
+	}
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(max(s, 0))) * time.Second
+}
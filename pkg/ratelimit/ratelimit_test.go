@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTake(t *testing.T) {
+	rate := Rate{Burst: 10, PerSecond: 2}
+	tests := []struct {
+		name        string
+		tokens      float64
+		elapsed     time.Duration
+		cost        int
+		wantTokens  float64
+		wantAllowed bool
+	}{
+		{"full bucket", 10, 0, 1, 9, true},
+		{"refill is capped at burst", 5, time.Hour, 2, 8, true},
+		{"partial refill", 0, 1500 * time.Millisecond, 3, 0, true},
+		{"not enough tokens", 1, 500 * time.Millisecond, 3, 2, false},
+		{"exact cost empties the bucket", 2, 0, 2, 0, true},
+		{"cost above burst is never allowed", 10, time.Hour, 11, 10, false},
+		{"clock going backwards does not drain", 4, -time.Minute, 1, 3, true},
+		{"peek on a non-empty bucket", 1, 0, 0, 1, true},
+		{"peek on an empty bucket", 0.5, 0, 0, 0.5, false},
+		{"peek refills", 0, time.Second, 0, 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, allowed := rate.take(tt.tokens, tt.elapsed, tt.cost)
+			if math.Abs(tokens-tt.wantTokens) > 1e-9 || allowed != tt.wantAllowed {
+				t.Errorf("take(%v, %s, %d) = %v, %v; want %v, %v", tt.tokens, tt.elapsed, tt.cost, tokens, allowed, tt.wantTokens, tt.wantAllowed)
+			}
+		})
+	}
+}
+
+func TestResult(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    Rate
+		tokens  float64
+		allowed bool
+		cost    int
+		want    Result
+	}{
+		{"allowed", Rate{Burst: 10, PerSecond: 1}, 6.5, true, 1,
+			Result{Allowed: true, Limit: 10, Remaining: 6, Reset: 4 * time.Second}},
+		{"refused", Rate{Burst: 10, PerSecond: 0.5}, 1, false, 3,
+			Result{Limit: 10, Remaining: 1, Reset: 18 * time.Second, RetryAfter: 4 * time.Second}},
+		{"refused peek waits for one token", Rate{Burst: 10, PerSecond: 1}, 0.2, false, 0,
+			Result{Limit: 10, Remaining: 0, Reset: 10 * time.Second, RetryAfter: time.Second}},
+		{"no refill", Rate{Burst: 5}, 0, false, 1,
+			Result{Limit: 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rate.result(tt.tokens, tt.allowed, tt.cost); got != tt.want {
+				t.Errorf("result = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemory(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	rate := PerMinute(60, 3) // 1 jeton par seconde
+	ctx := context.Background()
+
+	steps := []struct {
+		at      time.Duration
+		key     string
+		cost    int
+		allowed bool
+		remain  int
+	}{
+		{0, "a", 2, true, 1},
+		{0, "a", 2, false, 1},
+		{0, "b", 3, true, 0}, // seaux indépendants
+		{0, "a", 0, true, 1},
+		{0, "a", 1, true, 0},
+		{0, "a", 0, false, 0},
+		{2 * time.Second, "a", 2, true, 0},
+		{time.Hour, "a", 1, true, 2},
+	}
+	for i, st := range steps {
+		now = start.Add(st.at)
+		res, err := m.Allow(ctx, st.key, st.cost, rate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Allowed != st.allowed || res.Remaining != st.remain {
+			t.Errorf("step %d: allowed=%v remaining=%d, want %v %d", i, res.Allowed, res.Remaining, st.allowed, st.remain)
+		}
+	}
+
+	// le balayage oublie les seaux redevenus pleins
+	now = start.Add(2 * time.Hour)
+	if _, err := m.Allow(ctx, "c", 1, rate); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.buckets["a"]; ok {
+		t.Error("full bucket a not swept")
+	}
+	if _, ok := m.buckets["c"]; !ok {
+		t.Error("active bucket c swept")
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, backend := range []string{"memory", "postgres"} {
+		if _, err := New(backend, nil); err != nil {
+			t.Errorf("New(%q): %v", backend, err)
+		}
+	}
+	if _, err := New("redis", nil); err == nil {
+		t.Error("unknown backend accepted")
+	}
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Local stocke les objets sous un dossier du système de fichiers.
+// Les uploads multipart en cours sont conservés dans <root>/.uploads/<uploadID>/.
+type Local struct {
+	Root string
+}
+
+// NewLocal crée le dossier racine si besoin.
+func NewLocal(root string) (*Local, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, err
+	}
+	return &Local{Root: root}, nil
+}
+
+func (l *Local) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || strings.HasPrefix(clean, "/.uploads") {
+		return "", fmt.Errorf("storage: invalid key %q", key)
+	}
+	return filepath.Join(l.Root, clean), nil
+}
+
+func (l *Local) uploadDir(uploadID string) (string, error) {
+	if _, err := hex.DecodeString(uploadID); err != nil || uploadID == "" {
+		return "", ErrNotFound
+	}
+	return filepath.Join(l.Root, ".uploads", uploadID), nil
+}
+
+// Put implémente Store.
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	_, err = writeFile(p, r)
+	return err
+}
+
+// Open implémente Store.
+func (l *Local) Open(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, ErrNotFound
+	} else if err != nil {
+		return nil, 0, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, st.Size(), nil
+}
+
+// Delete implémente Store.
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// CreateMultipart implémente Store.
+func (l *Local) CreateMultipart(ctx context.Context, key, contentType string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b)
+	dir, _ := l.uploadDir(id)
+	return id, os.MkdirAll(dir, 0o750)
+}
+
+// UploadPart implémente Store.
+func (l *Local) UploadPart(ctx context.Context, key, uploadID string, number int, r io.Reader, size int64) (string, error) {
+	dir, err := l.uploadDir(uploadID)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return "", ErrNotFound
+	}
+	h := md5.New()
+	if _, err := writeFile(filepath.Join(dir, fmt.Sprintf("%05d", number)), io.TeeReader(r, h)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CompleteMultipart implémente Store.
+func (l *Local) CompleteMultipart(ctx context.Context, key, uploadID string, parts []Part) error {
+	dir, err := l.uploadDir(uploadID)
+	if err != nil {
+		return err
+	}
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".part-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	for _, part := range parts {
+		f, err := os.Open(filepath.Join(dir, fmt.Sprintf("%05d", part.Number)))
+		if errors.Is(err, os.ErrNotExist) {
+			tmp.Close()
+			return fmt.Errorf("storage: part %d not uploaded", part.Number)
+		} else if err != nil {
+			tmp.Close()
+			return err
+		}
+		_, err = io.Copy(tmp, f)
+		f.Close()
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// AbortMultipart implémente Store.
+func (l *Local) AbortMultipart(ctx context.Context, key, uploadID string) error {
+	dir, err := l.uploadDir(uploadID)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// writeFile écrit r dans p via un fichier temporaire renommé à la fin.
+func writeFile(p string, r io.Reader) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	n, err := io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		return n, err
+	}
+	if err := tmp.Close(); err != nil {
+		return n, err
+	}
+	return n, os.Rename(tmp.Name(), p)
+}
@@ -0,0 +1,272 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3 stocke les objets dans un bucket S3 ou compatible (MinIO, Garage, R2...).
+// Les requêtes sont signées en AWS Signature V4 avec un payload non signé.
+type S3 struct {
+	Endpoint  string // ex: https://s3.eu-west-3.amazonaws.com ou http://localhost:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool // http://host/bucket/key au lieu de http://bucket.host/key (MinIO)
+	HTTP      *http.Client
+}
+
+// NewS3 construit un backend S3 avec un client HTTP sans timeout global (les objets peuvent être gros).
+func NewS3(endpoint, region, bucket, accessKey, secretKey string, pathStyle bool) *S3 {
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &S3{
+		Endpoint:  strings.TrimRight(endpoint, "/"),
+		Region:    region,
+		Bucket:    bucket,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		PathStyle: pathStyle,
+		HTTP:      &http.Client{Transport: &http.Transport{ResponseHeaderTimeout: 30 * time.Second}},
+	}
+}
+
+// s3Error est le corps XML d'une erreur S3.
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// Put implémente Store.
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	res, err := s.do(ctx, http.MethodPut, key, nil, r, size, contentType)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+// Open implémente Store.
+func (s *S3) Open(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	res, err := s.do(ctx, http.MethodGet, key, nil, nil, 0, "")
+	if err != nil {
+		return nil, 0, err
+	}
+	return res.Body, res.ContentLength, nil
+}
+
+// Delete implémente Store.
+func (s *S3) Delete(ctx context.Context, key string) error {
+	res, err := s.do(ctx, http.MethodDelete, key, nil, nil, 0, "")
+	if err == ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+// CreateMultipart implémente Store.
+func (s *S3) CreateMultipart(ctx context.Context, key, contentType string) (string, error) {
+	res, err := s.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, 0, contentType)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	var out struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(res.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	return out.UploadID, nil
+}
+
+// UploadPart implémente Store. S3 impose 5 Mio minimum pour chaque partie sauf la dernière.
+func (s *S3) UploadPart(ctx context.Context, key, uploadID string, number int, r io.Reader, size int64) (string, error) {
+	q := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+	res, err := s.do(ctx, http.MethodPut, key, q, r, size, "")
+	if err != nil {
+		return "", err
+	}
+	res.Body.Close()
+	return strings.Trim(res.Header.Get("ETag"), `"`), nil
+}
+
+// CompleteMultipart implémente Store.
+func (s *S3) CompleteMultipart(ctx context.Context, key, uploadID string, parts []Part) error {
+	type xmlPart struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	body := struct {
+		XMLName xml.Name  `xml:"CompleteMultipartUpload"`
+		Parts   []xmlPart `xml:"Part"`
+	}{}
+	for _, p := range parts {
+		body.Parts = append(body.Parts, xmlPart{PartNumber: p.Number, ETag: `"` + strings.Trim(p.ETag, `"`) + `"`})
+	}
+	b, err := xml.Marshal(body)
+	if err != nil {
+		return err
+	}
+	res, err := s.do(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, bytes.NewReader(b), int64(len(b)), "application/xml")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	// S3 peut répondre 200 avec une erreur dans le corps
+	data, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if bytes.Contains(data, []byte("<Error>")) {
+		var e s3Error
+		_ = xml.Unmarshal(data, &e)
+		return fmt.Errorf("storage: s3 complete multipart: %s: %s", e.Code, e.Message)
+	}
+	return nil
+}
+
+// AbortMultipart implémente Store.
+func (s *S3) AbortMultipart(ctx context.Context, key, uploadID string) error {
+	res, err := s.do(ctx, http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+// do signe et exécute une requête sur un objet; les réponses non 2xx sont converties en erreur.
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	base, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	host := base.Host
+	escaped := "/" + awsEscape(strings.TrimPrefix(key, "/"), false)
+	if s.PathStyle {
+		escaped = "/" + awsEscape(s.Bucket, true) + escaped
+	} else {
+		host = s.Bucket + "." + host
+	}
+	rawQuery := canonicalQuery(query)
+	unescaped, err := url.PathUnescape(escaped)
+	if err != nil {
+		return nil, err
+	}
+	u := &url.URL{Scheme: base.Scheme, Host: host, Path: unescaped, RawPath: escaped, RawQuery: rawQuery}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s.sign(req, host, escaped, rawQuery, time.Now().UTC())
+
+	res, err := s.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return res, nil
+	}
+	defer res.Body.Close()
+	var e s3Error
+	_ = xml.NewDecoder(io.LimitReader(res.Body, 64<<10)).Decode(&e)
+	if res.StatusCode == http.StatusNotFound || e.Code == "NoSuchKey" || e.Code == "NoSuchUpload" {
+		return nil, ErrNotFound
+	}
+	return nil, fmt.Errorf("storage: s3 %s %s: %s %s: %s", method, key, res.Status, e.Code, e.Message)
+}
+
+// sign ajoute les en-têtes AWS Signature V4.
+func (s *S3) sign(req *http.Request, host, escapedPath, rawQuery string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	const payloadHash = "UNSIGNED-PAYLOAD"
+	req.Host = host
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	headers := "host:" + host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n"
+	canonical := strings.Join([]string{req.Method, escapedPath, rawQuery, headers, strings.Join(signed, ";"), payloadHash}, "\n")
+
+	scope := day + "/" + s.Region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	k := hmacSHA256([]byte("AWS4"+s.SecretKey), day)
+	k = hmacSHA256(k, s.Region)
+	k = hmacSHA256(k, "s3")
+	k = hmacSHA256(k, "aws4_request")
+	sig := hex.EncodeToString(hmacSHA256(k, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, strings.Join(signed, ";"), sig))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+// canonicalQuery encode les paramètres triés comme l'exige SigV4.
+func canonicalQuery(q url.Values) string {
+	if len(q) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, awsEscape(k, true)+"="+awsEscape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape encode selon RFC 3986 (caractères non réservés conservés), "/" inclus si encodeSlash.
+func awsEscape(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			sb.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion    = "eu-west-3"
+)
+
+// fakeS3 est un serveur S3 minimal qui revérifie la signature V4 de chaque requête.
+type fakeS3 struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func newFakeS3(t *testing.T) (*S3, *fakeS3) {
+	f := &fakeS3{t: t, objects: map[string][]byte{}, types: map[string]string{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	s := NewS3(srv.URL, testRegion, "artifacts", testAccessKey, testSecretKey, true)
+	return s, f
+}
+
+// verify recalcule la signature attendue à partir de la requête reçue.
+func (f *fakeS3) verify(r *http.Request) error {
+	auth := r.Header.Get("Authorization")
+	const prefix = "AWS4-HMAC-SHA256 "
+	if !strings.HasPrefix(auth, prefix) {
+		return errors.New("missing sigv4 authorization")
+	}
+	fields := map[string]string{}
+	for _, kv := range strings.Split(strings.TrimPrefix(auth, prefix), ", ") {
+		k, v, _ := strings.Cut(kv, "=")
+		fields[k] = v
+	}
+	amzDate := r.Header.Get("X-Amz-Date")
+	if len(amzDate) != len("20060102T150405Z") {
+		return errors.New("bad x-amz-date")
+	}
+	scope := amzDate[:8] + "/" + testRegion + "/s3/aws4_request"
+	if fields["Credential"] != testAccessKey+"/"+scope {
+		return errors.New("bad credential scope: " + fields["Credential"])
+	}
+	signed := strings.Split(fields["SignedHeaders"], ";")
+	if !sort.StringsAreSorted(signed) {
+		return errors.New("signed headers not sorted")
+	}
+	var headers strings.Builder
+	for _, h := range signed {
+		v := r.Header.Get(h)
+		if h == "host" {
+			v = r.Host
+		}
+		headers.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+	canonical := strings.Join([]string{
+		r.Method, r.URL.EscapedPath(), r.URL.RawQuery, headers.String(),
+		fields["SignedHeaders"], r.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+	k := hmacSHA256([]byte("AWS4"+testSecretKey), amzDate[:8])
+	k = hmacSHA256(k, testRegion)
+	k = hmacSHA256(k, "s3")
+	k = hmacSHA256(k, "aws4_request")
+	if want := hex.EncodeToString(hmacSHA256(k, toSign)); fields["Signature"] != want {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := f.verify(r); err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, "<Error><Code>SignatureDoesNotMatch</Code><Message>"+err.Error()+"</Message></Error>")
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := r.URL.Path
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+		f.types[key] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, "<Error><Code>NoSuchKey</Code><Message>missing</Message></Error>")
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3PutOpenDelete(t *testing.T) {
+	ctx := context.Background()
+	keys := []string{
+		"builds/b1/a1/app.apk",
+		"builds/b1/a2/My App (1).ipa",
+		"builds/b1/a3/été+ünïcode.dmg",
+	}
+	for _, key := range keys {
+		t.Run(key, func(t *testing.T) {
+			s, f := newFakeS3(t)
+			data := []byte("payload of " + key)
+			if err := s.Put(ctx, key, bytes.NewReader(data), int64(len(data)), "application/octet-stream"); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			if got := f.types["/artifacts/"+key]; got != "application/octet-stream" {
+				t.Errorf("stored content type = %q", got)
+			}
+
+			rc, size, err := s.Open(ctx, key)
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			got, _ := io.ReadAll(rc)
+			rc.Close()
+			if !bytes.Equal(got, data) || size != int64(len(data)) {
+				t.Errorf("Open = %q (%d), want %q", got, size, data)
+			}
+
+			if err := s.Delete(ctx, key); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if _, _, err := s.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Open after delete: err = %v, want ErrNotFound", err)
+			}
+			// supprimer un objet absent n'est pas une erreur
+			if err := s.Delete(ctx, key); err != nil {
+				t.Errorf("Delete missing: %v", err)
+			}
+		})
+	}
+}
+
+func TestS3RejectedSignature(t *testing.T) {
+	s, _ := newFakeS3(t)
+	s.SecretKey = "wrong"
+	err := s.Put(context.Background(), "k", strings.NewReader("x"), 1, "")
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Fatalf("Put with wrong key: err = %v", err)
+	}
+}
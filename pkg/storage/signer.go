@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// URLSigner signe des URLs de téléchargement à durée limitée (HMAC-SHA256 de la ressource et de l'expiration).
+type URLSigner struct {
+	Key []byte
+}
+
+// Sign retourne la signature hexadécimale de resource valable jusqu'à expires.
+func (s URLSigner) Sign(resource string, expires time.Time) string {
+	m := hmac.New(sha256.New, s.Key)
+	m.Write([]byte(resource + "\n" + strconv.FormatInt(expires.Unix(), 10)))
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// Verify contrôle une signature et son expiration (timestamp unix).
+func (s URLSigner) Verify(resource string, expires int64, signature string, now time.Time) bool {
+	if len(s.Key) == 0 || now.Unix() > expires {
+		return false
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	want, _ := hex.DecodeString(s.Sign(resource, time.Unix(expires, 0)))
+	return hmac.Equal(got, want)
+}
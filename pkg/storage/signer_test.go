@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestURLSigner(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	exp := now.Add(10 * time.Minute)
+	s := URLSigner{Key: []byte("secret")}
+	sig := s.Sign("artifact:a1", exp)
+
+	tests := []struct {
+		name     string
+		signer   URLSigner
+		resource string
+		expires  int64
+		sig      string
+		now      time.Time
+		want     bool
+	}{
+		{"valid", s, "artifact:a1", exp.Unix(), sig, now, true},
+		{"valid at expiry", s, "artifact:a1", exp.Unix(), sig, exp, true},
+		{"expired", s, "artifact:a1", exp.Unix(), sig, exp.Add(time.Second), false},
+		{"extended expiry", s, "artifact:a1", exp.Add(time.Hour).Unix(), sig, now, false},
+		{"other resource", s, "artifact:a2", exp.Unix(), sig, now, false},
+		{"tampered signature", s, "artifact:a1", exp.Unix(), "00" + sig[2:], now, false},
+		{"truncated signature", s, "artifact:a1", exp.Unix(), sig[:32], now, false},
+		{"not hex", s, "artifact:a1", exp.Unix(), "zz" + sig[2:], now, false},
+		{"empty signature", s, "artifact:a1", exp.Unix(), "", now, false},
+		{"other key", URLSigner{Key: []byte("other")}, "artifact:a1", exp.Unix(), sig, now, false},
+		{"no key", URLSigner{}, "artifact:a1", exp.Unix(), URLSigner{}.Sign("artifact:a1", exp), now, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.signer.Verify(tt.resource, tt.expires, tt.sig, tt.now); got != tt.want {
+				t.Errorf("Verify = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
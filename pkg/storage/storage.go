@@ -0,0 +1,37 @@
+// Package storage stocke les artefacts de build (système de fichiers local ou S3 compatible).
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound est retournée quand l'objet ou l'upload demandé n'existe pas.
+var ErrNotFound = errors.New("storage: not found")
+
+// Part identifie une partie d'un upload multipart terminée.
+type Part struct {
+	Number int    `json:"number"`
+	ETag   string `json:"etag"`
+}
+
+// Store est implémenté par chaque backend de stockage.
+// Les clés sont des chemins relatifs séparés par "/" (ex: builds/<id>/<artifact>/app.apk).
+type Store interface {
+	// Put écrit un objet en une fois.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open ouvre un objet en lecture et retourne sa taille.
+	Open(ctx context.Context, key string) (io.ReadCloser, int64, error)
+	// Delete supprime un objet; supprimer un objet absent n'est pas une erreur.
+	Delete(ctx context.Context, key string) error
+
+	// CreateMultipart démarre un upload en plusieurs parties et retourne son identifiant.
+	CreateMultipart(ctx context.Context, key, contentType string) (string, error)
+	// UploadPart écrit la partie number (à partir de 1) et retourne son ETag.
+	UploadPart(ctx context.Context, key, uploadID string, number int, r io.Reader, size int64) (string, error)
+	// CompleteMultipart assemble les parties dans l'ordre donné.
+	CompleteMultipart(ctx context.Context, key, uploadID string, parts []Part) error
+	// AbortMultipart abandonne un upload et libère ses parties.
+	AbortMultipart(ctx context.Context, key, uploadID string) error
+}
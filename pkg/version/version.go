@@ -0,0 +1,147 @@
+// Package version compare des versions d'application (semver avec numéro de build optionnel).
+package version
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version est une version "1.2.3-beta.1+45" : le numéro de build (après "+", ou versionCode
+// Android / CFBundleVersion iOS) départage deux versions sémantiques égales.
+type Version struct {
+	Major, Minor, Patch int
+	Pre                 []string
+	Build               int
+	HasBuild            bool
+}
+
+// Parse lit une version semver tolérante ("v1.2", "1.2.3+45", "1.2.3-rc.1").
+func Parse(s string) (Version, error) {
+	var v Version
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if s == "" {
+		return v, fmt.Errorf("empty version")
+	}
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		b, err := strconv.Atoi(s[i+1:])
+		if err != nil || b < 0 {
+			return v, fmt.Errorf("invalid build number %q", s[i+1:])
+		}
+		v.Build, v.HasBuild = b, true
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		v.Pre = strings.Split(s[i+1:], ".")
+		for _, id := range v.Pre {
+			if id == "" {
+				return v, fmt.Errorf("invalid pre-release %q", s[i+1:])
+			}
+		}
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf("invalid version %q", s)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q", s)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+// WithBuild retourne v avec le numéro de build donné s'il n'en a pas déjà un.
+func (v Version) WithBuild(code string) Version {
+	if v.HasBuild {
+		return v
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(code)); err == nil && n >= 0 {
+		v.Build, v.HasBuild = n, true
+	}
+	return v
+}
+
+// String formate la version.
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Pre) > 0 {
+		s += "-" + strings.Join(v.Pre, ".")
+	}
+	if v.HasBuild {
+		s += "+" + strconv.Itoa(v.Build)
+	}
+	return s
+}
+
+// Compare retourne -1, 0 ou 1. Les numéros de build ne sont comparés que si les deux en ont.
+func Compare(a, b Version) int {
+	for _, d := range [][2]int{{a.Major, b.Major}, {a.Minor, b.Minor}, {a.Patch, b.Patch}} {
+		if c := cmpInt(d[0], d[1]); c != 0 {
+			return c
+		}
+	}
+	if c := comparePre(a.Pre, b.Pre); c != 0 {
+		return c
+	}
+	if a.HasBuild && b.HasBuild {
+		return cmpInt(a.Build, b.Build)
+	}
+	return 0
+}
+
+// comparePre applique les règles de précédence semver sur les pré-versions.
+func comparePre(a, b []string) int {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		na, okA := numeric(a[i])
+		nb, okB := numeric(b[i])
+		switch {
+		case okA && okB:
+			if c := cmpInt(na, nb); c != 0 {
+				return c
+			}
+		case okA:
+			return -1
+		case okB:
+			return 1
+		default:
+			if c := strings.Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return cmpInt(len(a), len(b))
+}
+
+// numeric lit un identifiant de pré-version composé uniquement de chiffres ("-1" est alphanumérique).
+func numeric(s string) (int, bool) {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+	n, err := strconv.Atoi(s)
+	return n, err == nil
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
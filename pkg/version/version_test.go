@@ -0,0 +1,129 @@
+package version
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"1.2.3", "1.2.3", false},
+		{"v1.2", "1.2.0", false},
+		{" 7 ", "7.0.0", false},
+		{"1.2.3+45", "1.2.3+45", false},
+		{"1.2.3-rc.1", "1.2.3-rc.1", false},
+		{"1.2.3-beta.2+7", "1.2.3-beta.2+7", false},
+		{"1.0.0-x-y", "1.0.0-x-y", false},
+		{"", "", true},
+		{"v", "", true},
+		{"1.2.3.4", "", true},
+		{"1..3", "", true},
+		{"1.-2.3", "", true},
+		{"a.b.c", "", true},
+		{"1.2.3+", "", true},
+		{"1.2.3+abc", "", true},
+		{"1.2.3+-1", "", true},
+		{"1.2.3-", "", true},
+		{"1.2.3-rc..1", "", true},
+		{"+5", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			v, err := Parse(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if err == nil && v.String() != tt.want {
+				t.Errorf("Parse(%q) = %s, want %s", tt.in, v, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"1.2.3", "1.2.4", -1},
+		{"1.10.0", "1.9.0", 1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.0", "1.0.0", 0},
+		// une pré-version précède la version finale
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0.0", "1.0.0-rc.1", 1},
+		// précédence semver des identifiants
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-alpha.beta", "1.0.0-beta", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-beta.11", "1.0.0-rc.1", -1},
+		{"1.0.0-1", "1.0.0-alpha", -1},
+		{"1.0.0--1", "1.0.0-1", 1},
+		// le numéro de build ne départage que si les deux en ont un
+		{"1.0.0+1", "1.0.0+2", -1},
+		{"1.0.0+10", "1.0.0+9", 1},
+		{"1.0.0+10", "1.0.0", 0},
+		{"1.0.1+1", "1.0.0+99", 1},
+		{"1.0.0-rc.1+99", "1.0.0+1", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"_vs_"+tt.b, func(t *testing.T) {
+			a, err := Parse(tt.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := Parse(tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Compare(a, b); got != tt.want {
+				t.Errorf("Compare(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+			if got := Compare(b, a); got != -tt.want {
+				t.Errorf("Compare(%s, %s) = %d, want %d", tt.b, tt.a, got, -tt.want)
+			}
+		})
+	}
+}
+
+func TestSortOrder(t *testing.T) {
+	in := []string{"1.0.0", "1.0.0-rc.1", "1.0.0-alpha", "0.9.9+3", "1.0.0-beta.11", "1.0.0-beta.2", "0.9.9+12"}
+	want := []string{"0.9.9+3", "0.9.9+12", "1.0.0-alpha", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0"}
+	vs := make([]Version, len(in))
+	for i, s := range in {
+		vs[i], _ = Parse(s)
+	}
+	slices.SortStableFunc(vs, Compare)
+	for i, v := range vs {
+		if v.String() != want[i] {
+			t.Fatalf("sorted[%d] = %s, want %s", i, v, want[i])
+		}
+	}
+}
+
+func TestWithBuild(t *testing.T) {
+	tests := []struct {
+		in, code, want string
+	}{
+		{"1.2.3", "45", "1.2.3+45"},
+		{"1.2.3", " 7 ", "1.2.3+7"},
+		{"1.2.3+9", "45", "1.2.3+9"},
+		{"1.2.3", "", "1.2.3"},
+		{"1.2.3", "abc", "1.2.3"},
+		{"1.2.3", "-4", "1.2.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in+"_"+tt.code, func(t *testing.T) {
+			v, _ := Parse(tt.in)
+			if got := v.WithBuild(tt.code).String(); got != tt.want {
+				t.Errorf("WithBuild(%q) = %s, want %s", tt.code, got, tt.want)
+			}
+		})
+	}
+}